@@ -60,3 +60,18 @@ Create the name of the service account to use
 {{- default "default" .Values.serviceAccount.name }}
 {{- end }}
 {{- end }}
+
+{{/*
+Forecast cache env (FORECAST_CACHE, REDIS_URL)
+*/}}
+{{- define "weather-bot.forecastCacheEnv" -}}
+- name: FORECAST_CACHE
+  value: {{ .Values.forecastCache.backend | quote }}
+{{- if eq .Values.forecastCache.backend "redis" }}
+- name: REDIS_URL
+  valueFrom:
+    secretKeyRef:
+      name: {{ .Values.forecastCache.redisUrlSecret.name }}
+      key: {{ .Values.forecastCache.redisUrlSecret.key }}
+{{- end }}
+{{- end }}
@@ -1,15 +1,28 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"expvar"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/cache"
 	"github.com/Isshinfunada/weather-bot/internal/interfaces/controller"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/notifier"
 	"github.com/Isshinfunada/weather-bot/internal/interfaces/repository"
 	"github.com/Isshinfunada/weather-bot/internal/usecase"
+	"github.com/Isshinfunada/weather-bot/internal/utils"
+	"github.com/go-redis/redis/v8"
 	"github.com/labstack/echo/v4"
 	_ "github.com/lib/pq"
 	"github.com/pressly/goose"
@@ -30,6 +43,12 @@ func run() error {
 			return runMigrations()
 		case "seed":
 			return runSeedsMigrations()
+		case "poll-warnings":
+			return runWarningPoller()
+		case "replay":
+			return runReplay(os.Args[2:])
+		case "prefetch":
+			return runPrefetch()
 		}
 	}
 
@@ -51,12 +70,64 @@ func runApp() error {
 
 	userRepo := repository.NewUserRepository(db)
 	areaRepo := repository.NewAreaRepository(db)
-	weatherRuleRepo := repository.NewWeatherRuleRepository(db)
+	ruleCacheTTL := defaultWeatherRuleCacheTTL
+	if v := os.Getenv("WEATHER_RULE_CACHE_TTL"); v != "" {
+		ruleCacheTTL, err = time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid WEATHER_RULE_CACHE_TTL: %q", v)
+		}
+	}
+	// 通知ルールは起動時に全件読み込み、以降はメモリから引く
+	weatherRuleRepo := repository.NewCachedWeatherRuleRepository(repository.NewWeatherRuleRepository(db), ruleCacheTTL)
+	if err := weatherRuleRepo.Refresh(context.Background()); err != nil {
+		return err
+	}
+	userRuleRepo := repository.NewUserWeatherRuleRepository(db)
 	notificationRepo := repository.NewNotificationRepository(db)
+	deliveryRepo := repository.NewDeliveryRepository(db)
+	snapshotRepo := repository.NewForecastSnapshotRepository(db)
+	warningRepo := repository.NewWarningRepository(db)
+
+	jmaConfig, err := loadJMAConfig()
+	if err != nil {
+		return err
+	}
+	jmaClient := jma.NewClient(jmaConfig)
+	logNotifier := notifier.NewLogNotifier()
+
+	forecastCache, err := loadForecastCache()
+	if err != nil {
+		return err
+	}
+
+	batchConfig, err := loadBatchConfig()
+	if err != nil {
+		return err
+	}
+
+	dispatchInterval := defaultNotificationDispatchInterval
+	if v := os.Getenv("NOTIFICATION_DISPATCH_INTERVAL"); v != "" {
+		dispatchInterval, err = time.ParseDuration(v)
+		if err != nil || dispatchInterval <= 0 {
+			return fmt.Errorf("invalid NOTIFICATION_DISPATCH_INTERVAL: %q", v)
+		}
+	}
 
 	areaUC := usecase.NewAreaUseCase(areaRepo)
 	userUC := usecase.NewUserUseCase(userRepo)
-	weatherUC := usecase.NewWeatherUsecase(weatherRuleRepo, notificationRepo, userRepo, areaUC)
+	weatherUC := usecase.NewWeatherUsecase(weatherRuleRepo, userRuleRepo, notificationRepo, deliveryRepo, userRepo, areaUC, jmaClient, forecastCache, snapshotRepo, logNotifier, batchConfig)
+	weatherRuleUC := usecase.NewWeatherRuleUsecase(weatherRuleRepo)
+	warningUC := usecase.NewWarningUsecase(areaRepo, userRepo, warningRepo, notificationRepo, jmaClient, logNotifier)
+
+	// 送れなかった通知をバックグラウンドで再送する
+	go runNotificationDispatcher(context.Background(), usecase.NewNotificationDispatcher(notificationRepo, userRepo, logNotifier), dispatchInterval)
+
+	// 未知の天気コードの件数などは、公開の API とは別のポートで確認する
+	debugAddr := os.Getenv("DEBUG_ADDR")
+	if debugAddr == "" {
+		debugAddr = defaultDebugAddr
+	}
+	go runDebugServer(debugAddr)
 
 	// Echoサーバーの設定
 	e := echo.New()
@@ -65,14 +136,324 @@ func runApp() error {
 	e.GET("/", func(c echo.Context) error {
 		return c.String(http.StatusOK, "Hello, World!")
 	})
-
-	controller.RegisterRoutes(e, userUC, areaUC, weatherUC)
+	controller.RegisterRoutes(e, userUC, areaUC, weatherUC, warningUC, weatherRuleUC)
 
 	// Echoサーバーの起動
 	e.Logger.Fatal(e.Start(":8080"))
 	return nil
 }
 
+// loadJMAConfig は環境変数から JMA クライアントの設定を読み込む
+// 未設定の項目は jma パッケージのデフォルト値が使われる
+func loadJMAConfig() (jma.Config, error) {
+	cfg := jma.Config{
+		BaseURL:   os.Getenv("JMA_BASE_URL"),
+		UserAgent: os.Getenv("JMA_USER_AGENT"),
+	}
+	if v := os.Getenv("JMA_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid JMA_TIMEOUT: %w", err)
+		}
+		cfg.Timeout = timeout
+	}
+	if v := os.Getenv("JMA_MAX_ATTEMPTS"); v != "" {
+		attempts, err := strconv.Atoi(v)
+		if err != nil || attempts <= 0 {
+			return cfg, fmt.Errorf("invalid JMA_MAX_ATTEMPTS: %q", v)
+		}
+		cfg.MaxAttempts = attempts
+	}
+	if v := os.Getenv("JMA_BREAKER_THRESHOLD"); v != "" {
+		threshold, err := strconv.Atoi(v)
+		if err != nil || threshold <= 0 {
+			return cfg, fmt.Errorf("invalid JMA_BREAKER_THRESHOLD: %q", v)
+		}
+		cfg.BreakerThreshold = threshold
+	}
+	if v := os.Getenv("JMA_BREAKER_COOLDOWN"); v != "" {
+		cooldown, err := time.ParseDuration(v)
+		if err != nil || cooldown <= 0 {
+			return cfg, fmt.Errorf("invalid JMA_BREAKER_COOLDOWN: %q", v)
+		}
+		cfg.BreakerCooldown = cooldown
+	}
+	return cfg, nil
+}
+
+// loadForecastCache は環境変数 FORECAST_CACHE から予報のキャッシュを作る
+// memory（デフォルト）はプロセス内、redis は REDIS_URL の Redis をレプリカ間で共有し、none ならキャッシュしない
+func loadForecastCache() (cache.ForecastCache, error) {
+	ttl := cache.DefaultForecastTTL
+	if v := os.Getenv("FORECAST_CACHE_TTL"); v != "" {
+		var err error
+		ttl, err = time.ParseDuration(v)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid FORECAST_CACHE_TTL: %q", v)
+		}
+	}
+
+	switch backend := os.Getenv("FORECAST_CACHE"); backend {
+	case "", "memory":
+		return cache.NewMemoryForecastCache(cache.DefaultMemoryCacheSize, ttl), nil
+	case "redis":
+		opts, err := redis.ParseURL(os.Getenv("REDIS_URL"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+		}
+		return cache.NewRedisForecastCache(redis.NewClient(opts), ttl), nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("invalid FORECAST_CACHE: %q", backend)
+	}
+}
+
+// loadBatchConfig は環境変数からバッチ処理の並列数、ユーザーごとのタイムアウト、失敗率の閾値を読み込む
+// 未設定の項目は usecase パッケージのデフォルト値が使われる
+func loadBatchConfig() (usecase.BatchConfig, error) {
+	var cfg usecase.BatchConfig
+	if v := os.Getenv("WEATHER_BATCH_WORKERS"); v != "" {
+		workers, err := strconv.Atoi(v)
+		if err != nil || workers <= 0 {
+			return cfg, fmt.Errorf("invalid WEATHER_BATCH_WORKERS: %q", v)
+		}
+		cfg.Workers = workers
+	}
+	if v := os.Getenv("WEATHER_USER_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil || timeout <= 0 {
+			return cfg, fmt.Errorf("invalid WEATHER_USER_TIMEOUT: %q", v)
+		}
+		cfg.UserTimeout = timeout
+	}
+	if v := os.Getenv("WEATHER_BATCH_MAX_FAILURE_RATE"); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate <= 0 || rate > 1 {
+			return cfg, fmt.Errorf("invalid WEATHER_BATCH_MAX_FAILURE_RATE: %q", v)
+		}
+		cfg.MaxFailureRate = rate
+	}
+	return cfg, nil
+}
+
+// defaultWeatherRuleCacheTTL は WEATHER_RULE_CACHE_TTL 未設定時に通知ルールを読み込み直す間隔
+const defaultWeatherRuleCacheTTL = 10 * time.Minute
+
+// defaultWarningPollInterval は WARNING_POLL_INTERVAL 未設定時のポーリング間隔
+const defaultWarningPollInterval = 5 * time.Minute
+
+// defaultNotificationDispatchInterval は NOTIFICATION_DISPATCH_INTERVAL 未設定時に送れなかった通知を再送する間隔
+const defaultNotificationDispatchInterval = 30 * time.Second
+
+// defaultDebugAddr は DEBUG_ADDR 未設定時に /debug/vars を公開するアドレス
+// メモリの統計や起動時の引数も含むので、Pod の外からは届かない localhost にする
+const defaultDebugAddr = "localhost:6060"
+
+// runDebugServer は addr で /debug/vars を公開する。公開の API とは別に起動し、認証はかけない
+func runDebugServer(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("[ERROR] debug server stopped: %v\n", err)
+	}
+}
+
+// runNotificationDispatcher は ctx が終わるまで interval ごとに pending の通知を送る
+func runNotificationDispatcher(ctx context.Context, dispatcher usecase.NotificationDispatcher, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		result, err := dispatcher.DispatchPending(ctx)
+		if err != nil {
+			log.Printf("[ERROR] failed to dispatch notifications: %v\n", err)
+		}
+		if result != nil && result.Claimed > 0 {
+			log.Printf("Dispatched notifications: sent=%d retrying=%d failed=%d\n", result.Sent, result.Retrying, result.Failed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runWarningPoller は警報・注意報を定期的に取得し、更新があればユーザーの通知時刻に関係なく通知する
+// SIGINT / SIGTERM で終了する
+func runWarningPoller() error {
+	dbURL := os.Getenv("DB_URL")
+	if dbURL == "" {
+		return fmt.Errorf("DB_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	interval := defaultWarningPollInterval
+	if v := os.Getenv("WARNING_POLL_INTERVAL"); v != "" {
+		interval, err = time.ParseDuration(v)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("invalid WARNING_POLL_INTERVAL: %q", v)
+		}
+	}
+
+	jmaConfig, err := loadJMAConfig()
+	if err != nil {
+		return err
+	}
+
+	warningUC := usecase.NewWarningUsecase(
+		repository.NewAreaRepository(db),
+		repository.NewUserRepository(db),
+		repository.NewWarningRepository(db),
+		repository.NewNotificationRepository(db),
+		jma.NewClient(jmaConfig),
+		notifier.NewLogNotifier(),
+	)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Polling warnings every %s\n", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := warningUC.PollWarnings(ctx); err != nil {
+			log.Printf("[ERROR] %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Warning poller stopped.")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// runPrefetch は有効なユーザーがいる全オフィスの予報を取得し、予報のキャッシュとスナップショットに保存する
+// 通知の前に CronJob で動かし、通知の処理がキャッシュの予報を使えるようにする
+// プロセス内のキャッシュは他のプロセスと共有せず、取得しても通知の処理に使われないので FORECAST_CACHE=redis を必須にする
+func runPrefetch() error {
+	if backend := os.Getenv("FORECAST_CACHE"); backend != "redis" {
+		return fmt.Errorf("prefetch requires FORECAST_CACHE=redis, got %q", backend)
+	}
+
+	dbURL := os.Getenv("DB_URL")
+	if dbURL == "" {
+		return fmt.Errorf("DB_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	jmaConfig, err := loadJMAConfig()
+	if err != nil {
+		return err
+	}
+	batchConfig, err := loadBatchConfig()
+	if err != nil {
+		return err
+	}
+	forecastCache, err := loadForecastCache()
+	if err != nil {
+		return err
+	}
+
+	prefetcher := usecase.NewForecastPrefetcher(
+		repository.NewAreaRepository(db),
+		jma.NewClient(jmaConfig),
+		forecastCache,
+		repository.NewForecastSnapshotRepository(db),
+		batchConfig,
+	)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	result, err := prefetcher.Prefetch(ctx)
+	if err != nil {
+		return err
+	}
+	for officeID, reason := range result.Failures {
+		log.Printf("[ERROR] office %s: %s\n", officeID, reason)
+	}
+	log.Printf("Prefetched forecasts for %d of %d offices in %dms\n", result.Fetched, result.Offices, result.DurationMs)
+	return result.Err()
+}
+
+// runReplay は保存済みの予報を今のルールとパーサーで判定し直し、元の判定との差分を JSON で出力する
+// 例: weather-bot replay -from 2024-06-01 -to 2024-07-01
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fromParam := fs.String("from", "", "start date (inclusive), 2006-01-02")
+	toParam := fs.String("to", "", "end date (exclusive), 2006-01-02")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	from, err := time.ParseInLocation("2006-01-02", *fromParam, utils.JST)
+	if err != nil {
+		return fmt.Errorf("invalid -from: %q", *fromParam)
+	}
+	to, err := time.ParseInLocation("2006-01-02", *toParam, utils.JST)
+	if err != nil {
+		return fmt.Errorf("invalid -to: %q", *toParam)
+	}
+	if !from.Before(to) {
+		return fmt.Errorf("-from must be before -to")
+	}
+
+	dbURL := os.Getenv("DB_URL")
+	if dbURL == "" {
+		return fmt.Errorf("DB_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	// 判定し直すだけなので、予報の取得と通知は使わない
+	weatherUC := usecase.NewWeatherUsecase(
+		repository.NewCachedWeatherRuleRepository(repository.NewWeatherRuleRepository(db), 0),
+		repository.NewUserWeatherRuleRepository(db),
+		repository.NewNotificationRepository(db),
+		repository.NewDeliveryRepository(db),
+		repository.NewUserRepository(db),
+		usecase.NewAreaUseCase(repository.NewAreaRepository(db)),
+		nil,
+		nil,
+		repository.NewForecastSnapshotRepository(db),
+		nil,
+		usecase.BatchConfig{},
+	)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report, err := weatherUC.ReplayEvaluations(ctx, from, to)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("failed to write replay report: %w", err)
+	}
+	log.Printf("Replayed %d evaluations: %d changed, %d skipped\n", report.Replayed, report.Changed, report.Skipped)
+	return nil
+}
+
 func runMigrations() error {
 	dbURL := os.Getenv("DB_URL")
 	if dbURL == "" {
@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE forecast_snapshots (
+    id SERIAL PRIMARY KEY,
+    office_id VARCHAR(10) NOT NULL REFERENCES area_offices(id),
+    report_datetime TIMESTAMP NOT NULL,
+    data JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (office_id, report_datetime)
+);
+
+-- 既存の行は weather_data を持ったまま、新しい行はスナップショットを参照する
+ALTER TABLE notification_history
+    ADD COLUMN forecast_snapshot_id INTEGER REFERENCES forecast_snapshots(id);
+
+CREATE INDEX idx_notification_history_forecast_snapshot_id
+    ON notification_history (forecast_snapshot_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_notification_history_forecast_snapshot_id;
+ALTER TABLE notification_history DROP COLUMN forecast_snapshot_id;
+DROP TABLE forecast_snapshots;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- NULL の場合は降水確率による通知を行わない
+ALTER TABLE users
+    ADD COLUMN pop_threshold INTEGER CHECK (pop_threshold BETWEEN 0 AND 100);
+
+-- 閾値を超えた降水確率ブロック
+ALTER TABLE notification_history
+    ADD COLUMN matched_pops JSONB;
+
+-- +goose Down
+ALTER TABLE notification_history DROP COLUMN matched_pops;
+ALTER TABLE users DROP COLUMN pop_threshold;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- 雨を気にする時間帯 例: [{"start":"07:00","end":"09:00"},{"start":"18:00","end":"20:00"}]
+-- 空配列なら終日を対象にする
+ALTER TABLE users
+    ADD COLUMN time_windows JSONB NOT NULL DEFAULT '[]';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN time_windows;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- today: 当日 / tomorrow: 翌日 / auto: 通知時刻が17時以降なら翌日
+ALTER TABLE users
+    ADD COLUMN target_day VARCHAR(10) NOT NULL DEFAULT 'today'
+    CHECK (target_day IN ('today', 'tomorrow', 'auto'));
+
+-- 判定に使った予報の対象日
+ALTER TABLE notification_history
+    ADD COLUMN target_date DATE;
+
+-- +goose Down
+ALTER TABLE notification_history DROP COLUMN target_date;
+ALTER TABLE users DROP COLUMN target_day;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- 日曜夕方に週間予報を通知するか
+ALTER TABLE users
+    ADD COLUMN weekly_notify BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- daily: 日次の通知判定 / weekly: 週間予報
+ALTER TABLE notification_history
+    ADD COLUMN notification_type VARCHAR(20) NOT NULL DEFAULT 'daily';
+
+-- +goose Down
+ALTER TABLE notification_history DROP COLUMN notification_type;
+ALTER TABLE users DROP COLUMN weekly_notify;
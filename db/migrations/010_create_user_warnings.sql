@@ -0,0 +1,25 @@
+-- +goose Up
+-- ユーザーに通知した警報・注意報。発表から解除までを1つのエピソードとして扱う
+CREATE TABLE user_warnings (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    area_code VARCHAR(10) NOT NULL REFERENCES area_class20(id),
+    warning_code VARCHAR(4) NOT NULL,
+    report_datetime TIMESTAMP NOT NULL,
+    notified_at TIMESTAMP NOT NULL,
+    cleared_at TIMESTAMP
+);
+
+-- 解除されるまで同じ警報・注意報は1回だけ通知する
+CREATE UNIQUE INDEX idx_user_warnings_active
+    ON user_warnings (user_id, warning_code)
+    WHERE cleared_at IS NULL;
+
+-- 警報・注意報の通知で発表されたコード
+ALTER TABLE notification_history
+    ADD COLUMN warning_codes TEXT[];
+
+-- +goose Down
+ALTER TABLE notification_history DROP COLUMN warning_codes;
+DROP INDEX IF EXISTS idx_user_warnings_active;
+DROP TABLE user_warnings;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- 警報・注意報の poller が最後に処理したオフィスごとの発表時刻
+CREATE TABLE warning_report_states (
+    office_id VARCHAR(10) PRIMARY KEY REFERENCES area_offices(id),
+    report_datetime TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE warning_report_states;
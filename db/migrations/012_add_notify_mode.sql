@@ -0,0 +1,17 @@
+-- +goose Up
+-- always: 毎回通知 / on_change: 前回の判定から変わったときだけ通知
+ALTER TABLE users
+    ADD COLUMN notify_mode VARCHAR(10) NOT NULL DEFAULT 'always'
+    CHECK (notify_mode IN ('always', 'on_change'));
+
+-- on_change で雨の予報が無くなったときにも通知する
+ALTER TABLE users
+    ADD COLUMN notify_all_clear BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX idx_notification_history_user_id_notification_time
+    ON notification_history (user_id, notification_time);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_notification_history_user_id_notification_time;
+ALTER TABLE users DROP COLUMN notify_all_clear;
+ALTER TABLE users DROP COLUMN notify_mode;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- weather_notification_rules の is_notify_trigger をユーザーごとに上書きする
+-- 天気コード単位か、カテゴリ（天気コードの先頭の数字）単位のどちらか一方を指定する
+CREATE TABLE user_weather_rules (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    weather_code VARCHAR(10),
+    category VARCHAR(10) CHECK (category IN ('sunny', 'cloudy', 'rain', 'snow')),
+    is_notify_trigger BOOLEAN NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((weather_code IS NULL) <> (category IS NULL))
+);
+
+CREATE UNIQUE INDEX idx_user_weather_rules_code
+    ON user_weather_rules (user_id, weather_code)
+    WHERE weather_code IS NOT NULL;
+
+CREATE UNIQUE INDEX idx_user_weather_rules_category
+    ON user_weather_rules (user_id, category)
+    WHERE category IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_user_weather_rules_category;
+DROP INDEX IF EXISTS idx_user_weather_rules_code;
+DROP TABLE user_weather_rules;
@@ -0,0 +1,38 @@
+-- +goose Up
+-- 天気コードの現象を構造化した属性。値は seeds/003 で説明文から設定する
+ALTER TABLE weather_notification_rules
+    ADD COLUMN precipitation VARCHAR(10) NOT NULL DEFAULT 'none'
+    CHECK (precipitation IN ('none', 'rain', 'snow', 'mixed'));
+
+ALTER TABLE weather_notification_rules
+    ADD COLUMN intensity VARCHAR(10) NOT NULL DEFAULT 'none'
+    CHECK (intensity IN ('none', 'light', 'moderate', 'heavy'));
+
+ALTER TABLE weather_notification_rules
+    ADD COLUMN has_thunder BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE weather_notification_rules
+    ADD COLUMN has_fog BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- 大きいほど深刻。1日に複数のコードがあるときに通知で名前を出すコードを選ぶ
+ALTER TABLE weather_notification_rules
+    ADD COLUMN severity SMALLINT NOT NULL DEFAULT 0;
+
+-- ユーザーが購読できるカテゴリに雷と霧を加える
+ALTER TABLE user_weather_rules DROP CONSTRAINT user_weather_rules_category_check;
+ALTER TABLE user_weather_rules
+    ADD CONSTRAINT user_weather_rules_category_check
+    CHECK (category IN ('sunny', 'cloudy', 'rain', 'snow', 'thunder', 'fog'));
+
+-- +goose Down
+DELETE FROM user_weather_rules WHERE category IN ('thunder', 'fog');
+ALTER TABLE user_weather_rules DROP CONSTRAINT user_weather_rules_category_check;
+ALTER TABLE user_weather_rules
+    ADD CONSTRAINT user_weather_rules_category_check
+    CHECK (category IN ('sunny', 'cloudy', 'rain', 'snow'));
+
+ALTER TABLE weather_notification_rules DROP COLUMN severity;
+ALTER TABLE weather_notification_rules DROP COLUMN has_fog;
+ALTER TABLE weather_notification_rules DROP COLUMN has_thunder;
+ALTER TABLE weather_notification_rules DROP COLUMN intensity;
+ALTER TABLE weather_notification_rules DROP COLUMN precipitation;
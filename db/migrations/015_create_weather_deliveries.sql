@@ -0,0 +1,14 @@
+-- +goose Up
+-- 天気の通知をユーザー・対象日・通知種別ごとに1回だけ処理するための記録
+-- 時間帯が重なった実行や複数のレプリカで同じユーザーを処理しても、先に記録した実行だけが通知する
+CREATE TABLE weather_deliveries (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_date DATE NOT NULL,
+    notification_type VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, target_date, notification_type)
+);
+
+-- +goose Down
+DROP TABLE weather_deliveries;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- notification_history を通知の outbox として使う
+-- 判定と送る文面を pending で記録してから送信し、失敗した行は dispatcher が再送する
+ALTER TABLE notification_history
+    ADD COLUMN message TEXT,
+    ADD COLUMN delivery_status VARCHAR(20) NOT NULL DEFAULT 'none'
+        CHECK (delivery_status IN ('none', 'pending', 'sent', 'failed')),
+    ADD COLUMN delivery_attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN last_error TEXT,
+    ADD COLUMN next_attempt_at TIMESTAMP,
+    ADD COLUMN delivered_at TIMESTAMP;
+
+-- dispatcher が再送する行を探す
+CREATE INDEX idx_notification_history_pending
+    ON notification_history (next_attempt_at)
+    WHERE delivery_status = 'pending';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_notification_history_pending;
+ALTER TABLE notification_history
+    DROP COLUMN delivered_at,
+    DROP COLUMN next_attempt_at,
+    DROP COLUMN last_error,
+    DROP COLUMN delivery_attempts,
+    DROP COLUMN delivery_status,
+    DROP COLUMN message;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- 処理中として記録した時刻
+-- 通知の履歴を書く前にプロセスが落ちた記録は、時間が経てば後の実行で処理し直す
+ALTER TABLE weather_deliveries
+    ADD COLUMN claimed_at TIMESTAMP;
+UPDATE weather_deliveries SET claimed_at = created_at;
+ALTER TABLE weather_deliveries
+    ALTER COLUMN claimed_at SET NOT NULL;
+
+-- +goose Down
+ALTER TABLE weather_deliveries DROP COLUMN claimed_at;
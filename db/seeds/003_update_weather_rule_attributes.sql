@@ -0,0 +1,43 @@
+-- +goose Up
+-- 天気コードの説明文から現象の属性を設定する
+
+-- 降水の種類。雨と雪の両方を含むもの（雨か雪、みぞれなど）は mixed
+UPDATE weather_notification_rules SET precipitation = CASE
+    WHEN weather_description LIKE '%みぞれ%' THEN 'mixed'
+    WHEN weather_description LIKE '%雨%' AND weather_description LIKE '%雪%' THEN 'mixed'
+    WHEN weather_description LIKE '%雨%' THEN 'rain'
+    WHEN weather_description LIKE '%雪%' THEN 'snow'
+    ELSE 'none'
+END;
+
+-- 強さ。晴・曇がベースのコード（1xx, 2xx）の降水は弱い扱い
+UPDATE weather_notification_rules SET intensity = CASE
+    WHEN precipitation = 'none' THEN 'none'
+    WHEN weather_description LIKE '%大雨%'
+        OR weather_description LIKE '%大雪%'
+        OR weather_description LIKE '%強く降る%'
+        OR weather_description LIKE '%強い%'
+        OR weather_description LIKE '%暴風%' THEN 'heavy'
+    WHEN weather_code LIKE '1%' OR weather_code LIKE '2%' THEN 'light'
+    ELSE 'moderate'
+END;
+
+UPDATE weather_notification_rules SET
+    has_thunder = weather_description LIKE '%雷%',
+    has_fog = weather_description LIKE '%霧%';
+
+-- 深刻度: 強さ（弱 1 / 並 2 / 強 3）に雷を伴うと +1
+UPDATE weather_notification_rules SET severity = CASE intensity
+    WHEN 'light' THEN 1
+    WHEN 'moderate' THEN 2
+    WHEN 'heavy' THEN 3
+    ELSE 0
+END + CASE WHEN has_thunder THEN 1 ELSE 0 END;
+
+-- +goose Down
+UPDATE weather_notification_rules SET
+    precipitation = 'none',
+    intensity = 'none',
+    has_thunder = FALSE,
+    has_fog = FALSE,
+    severity = 0;
@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+// ForecastSnapshot は取得した JMA 予報 JSON を発表時刻単位で保存したもの
+type ForecastSnapshot struct {
+	ID             int
+	OfficeID       string
+	ReportDatetime time.Time
+	Data           []byte
+	CreatedAt      time.Time
+}
@@ -3,11 +3,45 @@ package entity
 import "time"
 
 type NotificationHistory struct {
-	ID               int
-	UserID           int
-	NotificationTime time.Time
-	IsNotifyTrigger  bool
-	WeatherCodes     []string
-	WeatherData      []byte
-	CreatedAt        time.Time
+	ID                 int
+	UserID             int
+	NotificationType   string // NotificationType* 定数
+	NotificationTime   time.Time
+	TargetDate         time.Time // 判定に使った予報の対象日
+	IsNotifyTrigger    bool
+	WeatherCodes       []string
+	MatchedPops        []PopBlock // 閾値を超えた降水確率ブロック
+	WarningCodes       []string   // 新たに発表された警報・注意報（warning のみ）
+	WeatherData        []byte     // スナップショット導入前の行のみ
+	ForecastSnapshotID *int       // forecast_snapshots.id
+	Message            string     // 送る文面。通知しない判定では空
+	DeliveryStatus     string     // DeliveryStatus* 定数
+	DeliveryAttempts   int
+	LastError          string     // 直近の送信の失敗理由
+	NextAttemptAt      *time.Time // pending の行を次に送る時刻
+	DeliveredAt        *time.Time
+	CreatedAt          time.Time
+}
+
+// 通知の種類
+const (
+	NotificationTypeDaily   = "daily"
+	NotificationTypeRecheck = "recheck" // on_change のユーザー向けの日中の再判定
+	NotificationTypeWeekly  = "weekly"  // 日曜夕方の週間予報
+	NotificationTypeWarning = "warning" // 警報・注意報の発表
+)
+
+// 通知の送信状態
+const (
+	DeliveryStatusNone    = "none"    // 送る必要が無い（導入前の行も含む）
+	DeliveryStatusPending = "pending" // 未送信か、再送待ち
+	DeliveryStatusSent    = "sent"
+	DeliveryStatusFailed  = "failed" // 再送の上限に達した
+)
+
+// PopBlock は降水確率の1ブロック
+type PopBlock struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Pop   int       `json:"pop"`
 }
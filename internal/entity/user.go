@@ -8,6 +8,31 @@ type User struct {
 	SelectedAreaID string
 	NotifyTime     time.Time
 	IsActive       bool
+	PopThreshold   *int         // 降水確率(%)の閾値。nil なら降水確率では通知しない
+	TimeWindows    []TimeWindow // 雨を気にする時間帯。空なら終日
+	TargetDay      string       // 予報の対象日の決め方（TargetDay* 定数）
+	WeeklyNotify   bool         // 日曜夕方に週間予報を通知する
+	NotifyMode     string       // 通知のしかた（NotifyMode* 定数）
+	NotifyAllClear bool         // on_change で雨の予報が無くなったときにも通知する
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 }
+
+// 予報の対象日の決め方
+const (
+	TargetDayToday    = "today"
+	TargetDayTomorrow = "tomorrow"
+	TargetDayAuto     = "auto" // 通知時刻が夕方以降なら翌日
+)
+
+// 通知のしかた
+const (
+	NotifyModeAlways   = "always"
+	NotifyModeOnChange = "on_change" // 前回の判定から変わったときだけ通知
+)
+
+// TimeWindow は通勤などで外にいる時間帯（"15:04" 形式、JST）
+type TimeWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
@@ -0,0 +1,15 @@
+package entity
+
+import "time"
+
+// UserWarning はユーザーに通知した警報・注意報
+// 発表から解除までを1つのエピソードとし、その間は再通知しない
+type UserWarning struct {
+	ID             int
+	UserID         int
+	AreaCode       string // area_class20.id
+	WarningCode    string
+	ReportDatetime time.Time // 通知のきっかけになった発表の時刻
+	NotifiedAt     time.Time
+	ClearedAt      *time.Time // 解除されるまで nil
+}
@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+// UserWeatherRule はユーザーごとの通知ルールの上書き
+// WeatherCode か Category のどちらか一方だけを持つ
+type UserWeatherRule struct {
+	ID              int
+	UserID          int
+	WeatherCode     string
+	Category        string // WeatherCategory* 定数
+	IsNotifyTrigger bool
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// ユーザーが購読できるカテゴリ。1つの天気コードが複数のカテゴリに当てはまる
+const (
+	WeatherCategorySunny   = "sunny"  // 晴がベース（1xx）
+	WeatherCategoryCloudy  = "cloudy" // 曇がベース（2xx）
+	WeatherCategoryRain    = "rain"
+	WeatherCategorySnow    = "snow"
+	WeatherCategoryThunder = "thunder"
+	WeatherCategoryFog     = "fog"
+)
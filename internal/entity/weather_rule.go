@@ -4,4 +4,52 @@ type WeatherRule struct {
 	WeatherCode        string
 	WeatherDescription string
 	IsNotifyTrigger    bool
+	Precipitation      string // Precipitation* 定数
+	Intensity          string // Intensity* 定数
+	HasThunder         bool
+	HasFog             bool
+	Severity           int // 大きいほど深刻
+}
+
+// 降水の種類
+const (
+	PrecipitationNone  = "none"
+	PrecipitationRain  = "rain"
+	PrecipitationSnow  = "snow"
+	PrecipitationMixed = "mixed" // 雨か雪、みぞれなど
+)
+
+// 降水の強さ
+const (
+	IntensityNone     = "none"
+	IntensityLight    = "light"
+	IntensityModerate = "moderate"
+	IntensityHeavy    = "heavy"
+)
+
+// Categories は天気コードが当てはまるカテゴリを返す
+// 晴・曇は天気コードの先頭の数字、雨・雪・雷・霧は現象の属性で決まる
+func (r *WeatherRule) Categories() []string {
+	var categories []string
+	if r.WeatherCode != "" {
+		switch r.WeatherCode[0] {
+		case '1':
+			categories = append(categories, WeatherCategorySunny)
+		case '2':
+			categories = append(categories, WeatherCategoryCloudy)
+		}
+	}
+	if r.Precipitation == PrecipitationRain || r.Precipitation == PrecipitationMixed {
+		categories = append(categories, WeatherCategoryRain)
+	}
+	if r.Precipitation == PrecipitationSnow || r.Precipitation == PrecipitationMixed {
+		categories = append(categories, WeatherCategorySnow)
+	}
+	if r.HasThunder {
+		categories = append(categories, WeatherCategoryThunder)
+	}
+	if r.HasFog {
+		categories = append(categories, WeatherCategoryFog)
+	}
+	return categories
 }
@@ -0,0 +1,8 @@
+package cache
+
+import "time"
+
+// NewMemoryForecastCacheWithClock は now を現在時刻として使う NewMemoryForecastCache
+func NewMemoryForecastCacheWithClock(size int, ttl time.Duration, now func() time.Time) ForecastCache {
+	return newMemoryForecastCache(size, ttl, now)
+}
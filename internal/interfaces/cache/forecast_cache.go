@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+	"github.com/Isshinfunada/weather-bot/internal/utils"
+)
+
+// DefaultForecastTTL は予報をキャッシュする期間の上限
+// 期間内でも、予報の次の発表時刻（05・11・17 時）を過ぎたら期限切れにする
+const DefaultForecastTTL = time.Hour
+
+// jmaIssueHours は JMA が府県天気予報を発表する時刻（JST）
+var jmaIssueHours = []int{5, 11, 17}
+
+// ForecastCache は JMA から取得した予報をオフィスごとに保持する
+// キーはオフィスの ID と予報の発表時刻で、同じオフィスでは最後に保存した予報を返す
+type ForecastCache interface {
+	// Get はオフィスの予報を返す。無いか期限が切れていれば nil
+	Get(ctx context.Context, officeID string) (*jma.Forecast, error)
+	// Set は予報を保存し、オフィスの予報として返すようにする
+	// 次の発表時刻を過ぎている予報は保存しない
+	Set(ctx context.Context, officeID string, forecast *jma.Forecast) error
+}
+
+// forecastKey は予報を保存するキー
+func forecastKey(officeID string, reportDatetime time.Time) string {
+	return fmt.Sprintf("forecast:%s:%s", officeID, reportDatetime.UTC().Format(time.RFC3339))
+}
+
+// expiresAt は now に保存する予報の期限を返す。ttl と予報の次の発表時刻の早い方
+// JMA の発表が遅れて古い予報を取得したときは now より前になる
+func expiresAt(forecast *jma.Forecast, now time.Time, ttl time.Duration) time.Time {
+	expires := now.Add(ttl)
+	if next := nextIssueTime(forecast.ReportDatetime()); next.Before(expires) {
+		return next
+	}
+	return expires
+}
+
+// nextIssueTime は t より後で最初の予報の発表時刻を返す
+func nextIssueTime(t time.Time) time.Time {
+	t = t.In(utils.JST)
+	for _, hour := range jmaIssueHours {
+		if issue := time.Date(t.Year(), t.Month(), t.Day(), hour, 0, 0, 0, utils.JST); issue.After(t) {
+			return issue
+		}
+	}
+	return time.Date(t.Year(), t.Month(), t.Day()+1, jmaIssueHours[0], 0, 0, 0, utils.JST)
+}
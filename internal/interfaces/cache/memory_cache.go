@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+)
+
+// DefaultMemoryCacheSize は全国のオフィス（約60）の予報が収まる件数
+const DefaultMemoryCacheSize = 128
+
+type memoryForecastCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // forecastKey → order の要素
+	latest  map[string]string        // オフィスの ID → 最後に保存した予報の forecastKey
+	order   *list.List               // 先頭ほど最近使った memoryEntry
+}
+
+type memoryEntry struct {
+	key       string
+	forecast  *jma.Forecast
+	expiresAt time.Time
+}
+
+// NewMemoryForecastCache はプロセス内に最大 size 件の予報を保持する ForecastCache を返す
+// 0 以下の値はデフォルト値を使う。レプリカ間では共有しない
+func NewMemoryForecastCache(size int, ttl time.Duration) ForecastCache {
+	return newMemoryForecastCache(size, ttl, time.Now)
+}
+
+func newMemoryForecastCache(size int, ttl time.Duration, now func() time.Time) ForecastCache {
+	if size <= 0 {
+		size = DefaultMemoryCacheSize
+	}
+	if ttl <= 0 {
+		ttl = DefaultForecastTTL
+	}
+	return &memoryForecastCache{
+		size:    size,
+		ttl:     ttl,
+		now:     now,
+		entries: make(map[string]*list.Element),
+		latest:  make(map[string]string),
+		order:   list.New(),
+	}
+}
+
+func (c *memoryForecastCache) Get(ctx context.Context, officeID string) (*jma.Forecast, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[c.latest[officeID]]
+	if !ok {
+		return nil, nil
+	}
+	entry := elem.Value.(*memoryEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.remove(elem)
+		return nil, nil
+	}
+	c.order.MoveToFront(elem)
+	return entry.forecast, nil
+}
+
+func (c *memoryForecastCache) Set(ctx context.Context, officeID string, forecast *jma.Forecast) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	expires := expiresAt(forecast, now, c.ttl)
+	if !expires.After(now) {
+		return nil
+	}
+	key := forecastKey(officeID, forecast.ReportDatetime())
+	// 304 の予報を保存しても次の取得で NotModified にならないようにする
+	stored := *forecast
+	stored.NotModified = false
+	entry := &memoryEntry{key: key, forecast: &stored, expiresAt: expires}
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+	} else {
+		c.entries[key] = c.order.PushFront(entry)
+	}
+	// 前の発表の予報はもう返さないので消す
+	if prev := c.latest[officeID]; prev != key {
+		if elem, ok := c.entries[prev]; ok {
+			c.remove(elem)
+		}
+	}
+	c.latest[officeID] = key
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+func (c *memoryForecastCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*memoryEntry).key)
+}
@@ -0,0 +1,159 @@
+package cache_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/cache"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+	"github.com/Isshinfunada/weather-bot/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fixtureReportDatetime = "2024-06-10T11:00:00+09:00"
+
+// loadFixtureForecast は発表時刻を直近の発表時刻に置き換えた予報を返す
+// 次の発表時刻を過ぎた予報はキャッシュしないため
+func loadFixtureForecast(t *testing.T) *jma.Forecast {
+	t.Helper()
+	return loadFixtureForecastAt(t, latestIssueTime(time.Now()))
+}
+
+func loadFixtureForecastAt(t *testing.T, reportDatetime time.Time) *jma.Forecast {
+	t.Helper()
+	body, err := os.ReadFile("../jma/testdata/forecast_130000.json")
+	require.NoError(t, err)
+	body = bytes.ReplaceAll(body, []byte(fixtureReportDatetime), []byte(reportDatetime.Format(time.RFC3339)))
+	forecast, err := jma.ParseForecast(body)
+	require.NoError(t, err)
+	return forecast
+}
+
+// latestIssueTime は now 以前で最後の予報の発表時刻（05・11・17 時）を返す
+func latestIssueTime(now time.Time) time.Time {
+	now = now.In(utils.JST)
+	for _, hour := range []int{17, 11, 5} {
+		if issue := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, utils.JST); !issue.After(now) {
+			return issue
+		}
+	}
+	return time.Date(now.Year(), now.Month(), now.Day()-1, 17, 0, 0, 0, utils.JST)
+}
+
+// testClock はテストで進める時計
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time          { return c.now }
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// newTestMemoryCache はフィクスチャの発表（2024-06-10 11時）の1時間後を現在時刻にした memory cache を返す
+func newTestMemoryCache(t *testing.T, size int, ttl time.Duration) (cache.ForecastCache, *testClock, *jma.Forecast) {
+	t.Helper()
+	forecast := loadFixtureForecastAt(t, time.Date(2024, 6, 10, 11, 0, 0, 0, utils.JST))
+	clock := &testClock{now: forecast.ReportDatetime().Add(time.Hour)}
+	return cache.NewMemoryForecastCacheWithClock(size, ttl, clock.Now), clock, forecast
+}
+
+func TestMemoryForecastCache(t *testing.T) {
+	ctx := context.Background()
+	c, _, forecast := newTestMemoryCache(t, 0, time.Hour)
+
+	got, err := c.Get(ctx, "130000")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	require.NoError(t, c.Set(ctx, "130000", forecast))
+	got, err = c.Get(ctx, "130000")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, forecast.ReportDatetime(), got.ReportDatetime())
+
+	// 他のオフィスの予報は返さない
+	got, err = c.Get(ctx, "140000")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestMemoryForecastCache_Expired(t *testing.T) {
+	ctx := context.Background()
+	c, clock, forecast := newTestMemoryCache(t, 0, 20*time.Minute)
+	require.NoError(t, c.Set(ctx, "130000", forecast))
+
+	clock.Advance(19 * time.Minute)
+	got, err := c.Get(ctx, "130000")
+	require.NoError(t, err)
+	assert.NotNil(t, got)
+
+	clock.Advance(time.Minute)
+	got, err = c.Get(ctx, "130000")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestMemoryForecastCache_ExpiresAtNextIssue(t *testing.T) {
+	ctx := context.Background()
+	c, clock, forecast := newTestMemoryCache(t, 0, 24*time.Hour)
+	require.NoError(t, c.Set(ctx, "130000", forecast))
+
+	// 11時の発表は、ttl の前でも次の 17 時の発表で期限が切れる
+	clock.now = time.Date(2024, 6, 10, 16, 59, 0, 0, utils.JST)
+	got, err := c.Get(ctx, "130000")
+	require.NoError(t, err)
+	assert.NotNil(t, got)
+
+	clock.now = time.Date(2024, 6, 10, 17, 0, 0, 0, utils.JST)
+	got, err = c.Get(ctx, "130000")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestMemoryForecastCache_SupersededReport(t *testing.T) {
+	ctx := context.Background()
+	c, _, forecast := newTestMemoryCache(t, 0, time.Hour)
+
+	// 次の発表時刻を過ぎた予報は、JMA の発表が遅れていても保存しない
+	superseded := loadFixtureForecastAt(t, forecast.ReportDatetime().Add(-6*time.Hour))
+	require.NoError(t, c.Set(ctx, "130000", superseded))
+	got, err := c.Get(ctx, "130000")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestMemoryForecastCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c, _, forecast := newTestMemoryCache(t, 2, time.Hour)
+
+	require.NoError(t, c.Set(ctx, "130000", forecast))
+	require.NoError(t, c.Set(ctx, "140000", forecast))
+	// 130000 を使ったので、次に追加したときは 140000 を消す
+	_, err := c.Get(ctx, "130000")
+	require.NoError(t, err)
+	require.NoError(t, c.Set(ctx, "270000", forecast))
+
+	for office, cached := range map[string]bool{"130000": true, "140000": false, "270000": true} {
+		got, err := c.Get(ctx, office)
+		require.NoError(t, err)
+		assert.Equal(t, cached, got != nil, office)
+	}
+}
+
+func TestMemoryForecastCache_ReturnsLatestReport(t *testing.T) {
+	ctx := context.Background()
+	c, _, older := newTestMemoryCache(t, 0, time.Hour)
+
+	newer := loadFixtureForecastAt(t, older.ReportDatetime().Add(6*time.Hour))
+	newer.NotModified = true
+
+	require.NoError(t, c.Set(ctx, "130000", older))
+	require.NoError(t, c.Set(ctx, "130000", newer))
+
+	got, err := c.Get(ctx, "130000")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, newer.ReportDatetime(), got.ReportDatetime())
+	assert.False(t, got.NotModified)
+}
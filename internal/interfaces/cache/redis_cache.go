@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+	"github.com/go-redis/redis/v8"
+)
+
+type redisForecastCache struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewRedisForecastCache は Redis に予報を保存する ForecastCache を返す
+// 同じ Redis を使うレプリカの間で、取得した予報を共有する
+func NewRedisForecastCache(client *redis.Client, ttl time.Duration) ForecastCache {
+	if ttl <= 0 {
+		ttl = DefaultForecastTTL
+	}
+	return &redisForecastCache{client: client, ttl: ttl}
+}
+
+// latestKey はオフィスの最後に保存した予報のキーを保存するキー
+func latestKey(officeID string) string {
+	return fmt.Sprintf("forecast:%s:latest", officeID)
+}
+
+func (c *redisForecastCache) Get(ctx context.Context, officeID string) (*jma.Forecast, error) {
+	key, err := c.client.Get(ctx, latestKey(officeID)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest forecast key for office %s: %w", officeID, err)
+	}
+
+	data, err := c.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached forecast %s: %w", key, err)
+	}
+	return jma.ParseForecast(data)
+}
+
+func (c *redisForecastCache) Set(ctx context.Context, officeID string, forecast *jma.Forecast) error {
+	now := time.Now()
+	ttl := expiresAt(forecast, now, c.ttl).Sub(now)
+	if ttl <= 0 {
+		return nil
+	}
+	// 予報と最後に保存した予報のキーは同じ期限にする
+	key := forecastKey(officeID, forecast.ReportDatetime())
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, forecast.Raw, ttl)
+		pipe.Set(ctx, latestKey(officeID), key, ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cache forecast %s: %w", key, err)
+	}
+	return nil
+}
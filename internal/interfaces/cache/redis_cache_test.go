@@ -0,0 +1,137 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/cache"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRedisCacheTest(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
+
+// forecastKey は予報を保存するキー
+func forecastKey(officeID string, forecast *jma.Forecast) string {
+	return "forecast:" + officeID + ":" + forecast.ReportDatetime().UTC().Format(time.RFC3339)
+}
+
+func TestRedisForecastCache(t *testing.T) {
+	ctx := context.Background()
+	mr, client := setupRedisCacheTest(t)
+	c := cache.NewRedisForecastCache(client, time.Hour)
+	forecast := loadFixtureForecast(t)
+
+	got, err := c.Get(ctx, "130000")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	require.NoError(t, c.Set(ctx, "130000", forecast))
+
+	// オフィスの ID と発表時刻をキーに、JMA の JSON をそのまま保存する
+	key := forecastKey("130000", forecast)
+	data, err := mr.Get(key)
+	require.NoError(t, err)
+	assert.Equal(t, string(forecast.Raw), data)
+	latest, err := mr.Get("forecast:130000:latest")
+	require.NoError(t, err)
+	assert.Equal(t, key, latest)
+	for _, k := range []string{key, "forecast:130000:latest"} {
+		assert.Positive(t, mr.TTL(k), k)
+		assert.LessOrEqual(t, mr.TTL(k), time.Hour, k)
+	}
+
+	got, err = c.Get(ctx, "130000")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, forecast.Reports, got.Reports)
+	assert.Equal(t, forecast.Raw, got.Raw)
+}
+
+func TestRedisForecastCache_SharedBetweenReplicas(t *testing.T) {
+	ctx := context.Background()
+	_, client := setupRedisCacheTest(t)
+	forecast := loadFixtureForecast(t)
+
+	require.NoError(t, cache.NewRedisForecastCache(client, time.Hour).Set(ctx, "130000", forecast))
+
+	got, err := cache.NewRedisForecastCache(client, time.Hour).Get(ctx, "130000")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, forecast.ReportDatetime(), got.ReportDatetime())
+}
+
+func TestRedisForecastCache_ReturnsLatestReport(t *testing.T) {
+	ctx := context.Background()
+	mr, client := setupRedisCacheTest(t)
+	c := cache.NewRedisForecastCache(client, time.Hour)
+
+	older := loadFixtureForecast(t)
+	newer := loadFixtureForecastAt(t, older.ReportDatetime().Add(6*time.Hour))
+	require.NoError(t, c.Set(ctx, "130000", older))
+	require.NoError(t, c.Set(ctx, "130000", newer))
+
+	// 発表時刻ごとに保存し、最後に保存した予報を指す
+	assert.True(t, mr.Exists(forecastKey("130000", older)))
+	latest, err := mr.Get("forecast:130000:latest")
+	require.NoError(t, err)
+	assert.Equal(t, forecastKey("130000", newer), latest)
+
+	got, err := c.Get(ctx, "130000")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, newer.ReportDatetime(), got.ReportDatetime())
+}
+
+func TestRedisForecastCache_Expired(t *testing.T) {
+	ctx := context.Background()
+	mr, client := setupRedisCacheTest(t)
+	c := cache.NewRedisForecastCache(client, time.Hour)
+	require.NoError(t, c.Set(ctx, "130000", loadFixtureForecast(t)))
+
+	mr.FastForward(time.Hour + time.Second)
+	got, err := c.Get(ctx, "130000")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestRedisForecastCache_ExpiresAtNextIssue(t *testing.T) {
+	ctx := context.Background()
+	mr, client := setupRedisCacheTest(t)
+	c := cache.NewRedisForecastCache(client, 24*time.Hour)
+
+	// 発表の間隔は最大で 17 時から翌 05 時の12時間なので、ttl より前に期限が切れる
+	forecast := loadFixtureForecast(t)
+	require.NoError(t, c.Set(ctx, "130000", forecast))
+	for _, k := range []string{forecastKey("130000", forecast), "forecast:130000:latest"} {
+		assert.Positive(t, mr.TTL(k), k)
+		assert.LessOrEqual(t, mr.TTL(k), 12*time.Hour, k)
+	}
+
+	// 次の発表時刻を過ぎた予報は保存しない
+	superseded := loadFixtureForecastAt(t, latestIssueTime(time.Now()).Add(-6*time.Hour))
+	require.NoError(t, c.Set(ctx, "140000", superseded))
+	assert.False(t, mr.Exists(forecastKey("140000", superseded)))
+	assert.False(t, mr.Exists("forecast:140000:latest"))
+}
+
+func TestRedisForecastCache_Unavailable(t *testing.T) {
+	ctx := context.Background()
+	mr, client := setupRedisCacheTest(t)
+	c := cache.NewRedisForecastCache(client, time.Hour)
+	mr.Close()
+
+	_, err := c.Get(ctx, "130000")
+	assert.Error(t, err)
+	assert.Error(t, c.Set(ctx, "130000", loadFixtureForecast(t)))
+}
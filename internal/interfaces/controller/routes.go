@@ -5,10 +5,12 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
-func RegisterRoutes(e *echo.Echo, userUC usecase.UserUsecase, areaUC usecase.AreaUseCase, weatherUC usecase.WeatherUsecase) {
+func RegisterRoutes(e *echo.Echo, userUC usecase.UserUsecase, areaUC usecase.AreaUseCase, weatherUC usecase.WeatherUsecase, warningUC usecase.WarningUsecase, weatherRuleUC usecase.WeatherRuleUsecase) {
 	userCtrl := NewUserController(userUC)
 	areaCtrl := NewAreaController(areaUC)
 	weatherCtrl := NewWeatherController(weatherUC)
+	warningCtrl := NewWarningController(warningUC)
+	weatherRuleCtrl := NewWeatherRuleController(weatherRuleUC)
 
 	// User
 	e.POST("/api/users", userCtrl.Create)                          //Create
@@ -16,10 +18,22 @@ func RegisterRoutes(e *echo.Echo, userUC usecase.UserUsecase, areaUC usecase.Are
 	e.GET("/api/users/line/:lineUserid", userCtrl.GetByLINEUserID) // Read(ByLINEID)
 	e.PUT("/api/users/:id", userCtrl.Update)                       // Update
 	e.DELETE("/api/users/:id", userCtrl.Delete)                    //Delete
+	e.GET("/api/users/:id/weekly", weatherCtrl.GetWeeklySummary)   // 週間予報
+	e.GET("/api/users/:id/rules", weatherCtrl.ListUserRules)       // 通知ルールの上書き一覧
+	e.PUT("/api/users/:id/rules", weatherCtrl.SetUserRule)         // 通知ルールの上書き
+	e.DELETE("/api/users/:id/rules", weatherCtrl.ResetUserRules)   // 通知ルールを共通に戻す
 
 	// Area
 	e.GET("/api/areas/:class20_id", areaCtrl.GetHierarchy) //Read
 
 	// Weather processing endpoint
 	e.GET("/api/process_weather", weatherCtrl.ProcessWeather)
+	e.GET("/api/recheck_weather", weatherCtrl.RecheckWeather)
+	e.GET("/api/process_warnings", warningCtrl.ProcessWarnings)
+
+	// Admin: 通知ルール
+	e.GET("/api/admin/weather_rules", weatherRuleCtrl.List)
+	e.POST("/api/admin/weather_rules", weatherRuleCtrl.Create)
+	e.PUT("/api/admin/weather_rules/:code", weatherRuleCtrl.Update)
+	e.DELETE("/api/admin/weather_rules/:code", weatherRuleCtrl.Delete)
 }
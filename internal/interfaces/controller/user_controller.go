@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -22,15 +23,74 @@ func NewUserController(uuc usecase.UserUsecase) *UserController {
 
 // CreateUserRequestはユーザー作成時のJSONリクエストボディ
 type CreateUserRequest struct {
-	LINEUserID     string `json:"lineUserId"`
-	SelectedAreaID string `json:"selectedAreaId"`
-	NotifyTime     string `json:"notifyTime"`
+	LINEUserID     string              `json:"lineUserId"`
+	SelectedAreaID string              `json:"selectedAreaId"`
+	NotifyTime     string              `json:"notifyTime"`
+	PopThreshold   *int                `json:"popThreshold"` // 未指定なら降水確率では通知しない
+	TimeWindows    []entity.TimeWindow `json:"timeWindows"`  // 判定に使う時間帯。降水確率の判定には popThreshold も必要
+	TargetDay      string              `json:"targetDay"`
+	WeeklyNotify   bool                `json:"weeklyNotify"`
+	NotifyMode     string              `json:"notifyMode"`
+	NotifyAllClear bool                `json:"notifyAllClear"`
 }
 
 type UpdateUserRequest struct {
-	SelectedAreaID string `json:"selectedAreaId"`
-	NotifyTime     string `json:"notifyTime"`
-	IsActive       bool   `json:"isActive"`
+	SelectedAreaID string              `json:"selectedAreaId"`
+	NotifyTime     string              `json:"notifyTime"`
+	IsActive       bool                `json:"isActive"`
+	PopThreshold   *int                `json:"popThreshold"` // 未指定なら降水確率では通知しない
+	TimeWindows    []entity.TimeWindow `json:"timeWindows"`  // 判定に使う時間帯。降水確率の判定には popThreshold も必要
+	TargetDay      string              `json:"targetDay"`
+	WeeklyNotify   bool                `json:"weeklyNotify"`
+	NotifyMode     string              `json:"notifyMode"`
+	NotifyAllClear bool                `json:"notifyAllClear"`
+}
+
+// 1ユーザーが設定できる時間帯の上限
+const maxTimeWindows = 5
+
+// validatePopThreshold は降水確率の閾値が 0〜100 の範囲か確認する（未指定は許可）
+func validatePopThreshold(threshold *int) bool {
+	return threshold == nil || (*threshold >= 0 && *threshold <= 100)
+}
+
+// validateTargetDay は対象日の指定が定義済みの値か確認する（未指定は当日扱い）
+func validateTargetDay(targetDay string) bool {
+	switch targetDay {
+	case "", entity.TargetDayToday, entity.TargetDayTomorrow, entity.TargetDayAuto:
+		return true
+	}
+	return false
+}
+
+// validateNotifyMode は通知のしかたが定義済みの値か確認する（未指定は毎回通知）
+func validateNotifyMode(notifyMode string) bool {
+	switch notifyMode {
+	case "", entity.NotifyModeAlways, entity.NotifyModeOnChange:
+		return true
+	}
+	return false
+}
+
+// validateTimeWindows は時間帯が "HH:MM" 形式で開始 < 終了になっているか確認する
+func validateTimeWindows(windows []entity.TimeWindow) error {
+	if len(windows) > maxTimeWindows {
+		return fmt.Errorf("too many time windows (max %d)", maxTimeWindows)
+	}
+	for _, w := range windows {
+		start, err := time.Parse("15:04", w.Start)
+		if err != nil {
+			return fmt.Errorf("invalid time window start: %q", w.Start)
+		}
+		end, err := time.Parse("15:04", w.End)
+		if err != nil {
+			return fmt.Errorf("invalid time window end: %q", w.End)
+		}
+		if !start.Before(end) {
+			return fmt.Errorf("time window start must be before end: %s-%s", w.Start, w.End)
+		}
+	}
+	return nil
 }
 
 // POST /api/users
@@ -45,10 +105,32 @@ func (ctrl *UserController) Create(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid notify time"})
 	}
 
+	if !validatePopThreshold(req.PopThreshold) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pop threshold"})
+	}
+
+	if err := validateTimeWindows(req.TimeWindows); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if !validateTargetDay(req.TargetDay) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid target day"})
+	}
+
+	if !validateNotifyMode(req.NotifyMode) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid notify mode"})
+	}
+
 	user := &entity.User{
 		LINEUserID:     req.LINEUserID,
 		SelectedAreaID: req.SelectedAreaID,
 		NotifyTime:     notifyTime,
+		PopThreshold:   req.PopThreshold,
+		TimeWindows:    req.TimeWindows,
+		TargetDay:      req.TargetDay,
+		WeeklyNotify:   req.WeeklyNotify,
+		NotifyMode:     req.NotifyMode,
+		NotifyAllClear: req.NotifyAllClear,
 	}
 
 	ctx := c.Request().Context()
@@ -110,11 +192,33 @@ func (ctrl *UserController) Update(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid notify time"})
 	}
 
+	if !validatePopThreshold(req.PopThreshold) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pop threshold"})
+	}
+
+	if err := validateTimeWindows(req.TimeWindows); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if !validateTargetDay(req.TargetDay) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid target day"})
+	}
+
+	if !validateNotifyMode(req.NotifyMode) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid notify mode"})
+	}
+
 	user := &entity.User{
 		ID:             userID,
 		SelectedAreaID: req.SelectedAreaID,
 		IsActive:       req.IsActive,
 		NotifyTime:     notifyTime,
+		PopThreshold:   req.PopThreshold,
+		TimeWindows:    req.TimeWindows,
+		TargetDay:      req.TargetDay,
+		WeeklyNotify:   req.WeeklyNotify,
+		NotifyMode:     req.NotifyMode,
+		NotifyAllClear: req.NotifyAllClear,
 	}
 
 	ctx := c.Request().Context()
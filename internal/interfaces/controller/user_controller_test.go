@@ -100,6 +100,118 @@ func TestUserController_Create_Success(t *testing.T) {
 	mockUC.AssertExpectations(t)
 }
 
+// Create エンドポイントのテスト（降水確率の閾値が範囲外）
+func TestUserController_Create_InvalidPopThreshold(t *testing.T) {
+	mockUC := new(MockUserUsecase)
+	userCtrl := controller.NewUserController(mockUC)
+
+	threshold := 120
+	reqBody := controller.CreateUserRequest{
+		LINEUserID:     "U123",
+		SelectedAreaID: "1",
+		NotifyTime:     "09:00",
+		PopThreshold:   &threshold,
+	}
+	bodyBytes, _ := json.Marshal(reqBody)
+
+	c, rec := newTestContext(http.MethodPost, "/api/users", bodyBytes)
+
+	if assert.NoError(t, userCtrl.Create(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var resp map[string]string
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		assert.Equal(t, "invalid pop threshold", resp["error"])
+	}
+	mockUC.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+// Update エンドポイントのテスト（不正な時間帯）
+func TestUserController_Update_InvalidTimeWindows(t *testing.T) {
+	cases := []struct {
+		name    string
+		windows []entity.TimeWindow
+	}{
+		{"invalid format", []entity.TimeWindow{{Start: "7時", End: "09:00"}}},
+		{"start after end", []entity.TimeWindow{{Start: "09:00", End: "07:00"}}},
+		{"too many windows", []entity.TimeWindow{
+			{Start: "01:00", End: "02:00"}, {Start: "03:00", End: "04:00"}, {Start: "05:00", End: "06:00"},
+			{Start: "07:00", End: "08:00"}, {Start: "09:00", End: "10:00"}, {Start: "11:00", End: "12:00"},
+		}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUC := new(MockUserUsecase)
+			userCtrl := controller.NewUserController(mockUC)
+
+			reqBody := controller.UpdateUserRequest{
+				SelectedAreaID: "2",
+				NotifyTime:     "06:30",
+				IsActive:       true,
+				TimeWindows:    tc.windows,
+			}
+			bodyBytes, _ := json.Marshal(reqBody)
+
+			c, rec := newTestContext(http.MethodPut, "/api/users/1", bodyBytes)
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+
+			if assert.NoError(t, userCtrl.Update(c)) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+			}
+			mockUC.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// Create エンドポイントのテスト（不正な対象日）
+func TestUserController_Create_InvalidTargetDay(t *testing.T) {
+	mockUC := new(MockUserUsecase)
+	userCtrl := controller.NewUserController(mockUC)
+
+	reqBody := controller.CreateUserRequest{
+		LINEUserID:     "U123",
+		SelectedAreaID: "1",
+		NotifyTime:     "21:00",
+		TargetDay:      "yesterday",
+	}
+	bodyBytes, _ := json.Marshal(reqBody)
+
+	c, rec := newTestContext(http.MethodPost, "/api/users", bodyBytes)
+
+	if assert.NoError(t, userCtrl.Create(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var resp map[string]string
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		assert.Equal(t, "invalid target day", resp["error"])
+	}
+	mockUC.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+// Create エンドポイントのテスト（不正な通知のしかた）
+func TestUserController_Create_InvalidNotifyMode(t *testing.T) {
+	mockUC := new(MockUserUsecase)
+	userCtrl := controller.NewUserController(mockUC)
+
+	reqBody := controller.CreateUserRequest{
+		LINEUserID:     "U123",
+		SelectedAreaID: "1",
+		NotifyTime:     "07:00",
+		NotifyMode:     "sometimes",
+	}
+	bodyBytes, _ := json.Marshal(reqBody)
+
+	c, rec := newTestContext(http.MethodPost, "/api/users", bodyBytes)
+
+	if assert.NoError(t, userCtrl.Create(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var resp map[string]string
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		assert.Equal(t, "invalid notify mode", resp["error"])
+	}
+	mockUC.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
 // GetByID エンドポイントのテスト（正常系）
 func TestUserController_GetByID_Success(t *testing.T) {
 	mockUC := new(MockUserUsecase)
@@ -172,10 +284,13 @@ func TestUserController_Update_Success(t *testing.T) {
 	mockUC := new(MockUserUsecase)
 	userCtrl := controller.NewUserController(mockUC)
 
+	threshold := 50
 	reqBody := controller.UpdateUserRequest{
 		SelectedAreaID: "2",
 		NotifyTime:     "10:00",
 		IsActive:       true,
+		PopThreshold:   &threshold,
+		TimeWindows:    []entity.TimeWindow{{Start: "07:00", End: "09:00"}, {Start: "18:00", End: "20:00"}},
 	}
 	bodyBytes, _ := json.Marshal(reqBody)
 
@@ -190,7 +305,9 @@ func TestUserController_Update_Success(t *testing.T) {
 	ctx := context.Background()
 
 	// UpdateUser は error を返さないケースを設定
-	mockUC.On("Update", ctx, mock.AnythingOfType("*entity.User")).Return(nil)
+	mockUC.On("Update", ctx, mock.MatchedBy(func(u *entity.User) bool {
+		return u.ID == 1 && u.PopThreshold != nil && *u.PopThreshold == 50 && len(u.TimeWindows) == 2
+	})).Return(nil)
 
 	if assert.NoError(t, userCtrl.Update(c)) {
 		assert.Equal(t, http.StatusOK, rec.Code)
@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/Isshinfunada/weather-bot/internal/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+type WarningController struct {
+	warningUC usecase.WarningUsecase
+}
+
+func NewWarningController(wuc usecase.WarningUsecase) *WarningController {
+	return &WarningController{warningUC: wuc}
+}
+
+// GET /api/process_warnings
+// office を指定するとそのオフィスだけ、無ければ有効なユーザーのいる全オフィスを処理する
+func (ctrl *WarningController) ProcessWarnings(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var err error
+	if officeID := c.QueryParam("office"); officeID != "" {
+		err = ctrl.warningUC.ProcessWarningsForOffice(ctx, officeID)
+	} else {
+		err = ctrl.warningUC.ProcessWarnings(ctx)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Warning processing completed"})
+}
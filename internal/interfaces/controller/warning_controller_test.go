@@ -0,0 +1,69 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/controller"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockWarningUsecase struct {
+	mock.Mock
+}
+
+func (m *MockWarningUsecase) ProcessWarnings(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockWarningUsecase) ProcessWarningsForOffice(ctx context.Context, officeID string) error {
+	args := m.Called(ctx, officeID)
+	return args.Error(0)
+}
+
+func (m *MockWarningUsecase) PollWarnings(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func setupWarningController(target string) (*controller.WarningController, *MockWarningUsecase, echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	rec := httptest.NewRecorder()
+	ctx := e.NewContext(req, rec)
+
+	mockWUC := new(MockWarningUsecase)
+	return controller.NewWarningController(mockWUC), mockWUC, ctx, rec
+}
+
+func TestProcessWarnings_AllOffices(t *testing.T) {
+	warningCtrl, mockWUC, ctx, rec := setupWarningController("/api/process_warnings")
+	mockWUC.On("ProcessWarnings", mock.Anything).Return(nil)
+
+	assert.NoError(t, warningCtrl.ProcessWarnings(ctx))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockWUC.AssertExpectations(t)
+}
+
+func TestProcessWarnings_SingleOffice(t *testing.T) {
+	warningCtrl, mockWUC, ctx, rec := setupWarningController("/api/process_warnings?office=130000")
+	mockWUC.On("ProcessWarningsForOffice", mock.Anything, "130000").Return(nil)
+
+	assert.NoError(t, warningCtrl.ProcessWarnings(ctx))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockWUC.AssertExpectations(t)
+}
+
+func TestProcessWarnings_Error(t *testing.T) {
+	warningCtrl, mockWUC, ctx, rec := setupWarningController("/api/process_warnings?office=130000")
+	mockWUC.On("ProcessWarningsForOffice", mock.Anything, "130000").Return(errors.New("unavailable"))
+
+	assert.NoError(t, warningCtrl.ProcessWarnings(ctx))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
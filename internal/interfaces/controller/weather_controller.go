@@ -1,9 +1,13 @@
 package controller
 
 import (
+	"fmt"
 	"net/http"
+	"regexp"
+	"strconv"
 	"time"
 
+	"github.com/Isshinfunada/weather-bot/internal/entity"
 	"github.com/Isshinfunada/weather-bot/internal/usecase"
 	"github.com/Isshinfunada/weather-bot/internal/utils"
 	"github.com/labstack/echo/v4"
@@ -51,10 +55,153 @@ func (ctrl *WeatherController) ProcessWeather(c echo.Context) error {
 		start = end.Add(-1 * time.Hour)
 	}
 
+	// dry_run=true なら判定だけを行い、通知も保存もしない
+	var opts usecase.ProcessOptions
+	if v := c.QueryParam("dry_run"); v != "" {
+		opts.DryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid dry_run"})
+		}
+	}
+
 	// usecaseを呼び出して指定時間帯の処理を実行
-	err = ctrl.weatherUC.ProcessWeatherForUsersInTimeRange(c.Request().Context(), start, end)
+	result, err := ctrl.weatherUC.ProcessWeatherForUsersInTimeRange(c.Request().Context(), start, end, opts)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	// 失敗したユーザーの割合が閾値を超えたら、スケジューラから失敗と分かるようにする
+	if result.Degraded {
+		return c.JSON(http.StatusInternalServerError, result)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// GET /api/users/:id/weekly
+func (ctrl *WeatherController) GetWeeklySummary(c echo.Context) error {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+	}
+
+	summary, err := ctrl.weatherUC.GetWeeklySummary(c.Request().Context(), userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
-	return c.JSON(http.StatusOK, map[string]string{"message": "Weather processing completed"})
+	if summary == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+	return c.JSON(http.StatusOK, summary)
+}
+
+// GET /api/recheck_weather
+// on_change のユーザーの予報を日中に再判定する
+func (ctrl *WeatherController) RecheckWeather(c echo.Context) error {
+	result, err := ctrl.weatherUC.RecheckWeatherForUsers(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	// /api/process_weather と同じく、失敗したユーザーの割合が閾値を超えたときだけ失敗にする
+	if result.Degraded {
+		return c.JSON(http.StatusInternalServerError, result)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// SetUserRuleRequest はユーザーの通知ルールを上書きするときのJSONリクエストボディ
+// weatherCode か category のどちらか一方を指定する
+type SetUserRuleRequest struct {
+	WeatherCode     string `json:"weatherCode"`
+	Category        string `json:"category"`
+	IsNotifyTrigger bool   `json:"isNotifyTrigger"`
+}
+
+var weatherCodePattern = regexp.MustCompile(`^\d{3}$`)
+
+// validateUserRuleTarget は上書きの対象が天気コードかカテゴリのどちらか一方で、形式が正しいか確認する
+func validateUserRuleTarget(weatherCode, category string) error {
+	if weatherCode != "" && category != "" {
+		return fmt.Errorf("specify either weather code or category")
+	}
+	if weatherCode != "" && !weatherCodePattern.MatchString(weatherCode) {
+		return fmt.Errorf("invalid weather code: %q", weatherCode)
+	}
+	switch category {
+	case "", entity.WeatherCategorySunny, entity.WeatherCategoryCloudy, entity.WeatherCategoryRain, entity.WeatherCategorySnow,
+		entity.WeatherCategoryThunder, entity.WeatherCategoryFog:
+		return nil
+	}
+	return fmt.Errorf("invalid category: %q", category)
+}
+
+// GET /api/users/:id/rules
+func (ctrl *WeatherController) ListUserRules(c echo.Context) error {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+	}
+
+	rules, err := ctrl.weatherUC.ListUserRules(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if rules == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+	return c.JSON(http.StatusOK, rules)
+}
+
+// PUT /api/users/:id/rules
+func (ctrl *WeatherController) SetUserRule(c echo.Context) error {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+	}
+
+	var req SetUserRuleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+	}
+	if req.WeatherCode == "" && req.Category == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "weather code or category is required"})
+	}
+	if err := validateUserRuleTarget(req.WeatherCode, req.Category); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	rules, err := ctrl.weatherUC.SetUserRule(c.Request().Context(), userID, usecase.UserRule{
+		WeatherCode:     req.WeatherCode,
+		Category:        req.Category,
+		IsNotifyTrigger: req.IsNotifyTrigger,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if rules == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+	return c.JSON(http.StatusOK, rules)
+}
+
+// DELETE /api/users/:id/rules
+// ?code= か ?category= を指定するとその上書きだけ、無ければ全ての上書きを削除する
+func (ctrl *WeatherController) ResetUserRules(c echo.Context) error {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+	}
+
+	weatherCode := c.QueryParam("code")
+	category := c.QueryParam("category")
+	if err := validateUserRuleTarget(weatherCode, category); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	rules, err := ctrl.weatherUC.ResetUserRules(c.Request().Context(), userID, weatherCode, category)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if rules == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+	return c.JSON(http.StatusOK, rules)
 }
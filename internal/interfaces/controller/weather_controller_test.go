@@ -3,6 +3,7 @@ package controller_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/Isshinfunada/weather-bot/internal/entity"
 	"github.com/Isshinfunada/weather-bot/internal/interfaces/controller"
+	"github.com/Isshinfunada/weather-bot/internal/usecase"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -22,14 +24,68 @@ type MockWeatherUsecase struct {
 	mock.Mock
 }
 
-func (m *MockWeatherUsecase) ProcessWeatherForUsersInTimeRange(ctx context.Context, start, end time.Time) error {
-	args := m.Called(ctx, start, end)
-	return args.Error(0)
+func (m *MockWeatherUsecase) ProcessWeatherForUsersInTimeRange(ctx context.Context, start, end time.Time, opts usecase.ProcessOptions) (*usecase.BatchResult, error) {
+	args := m.Called(ctx, start, end, opts)
+	if r := args.Get(0); r != nil {
+		return r.(*usecase.BatchResult), args.Error(1)
+	}
+	return nil, args.Error(1)
 }
 
-func (m *MockWeatherUsecase) ProcessWeatherForUser(ctx context.Context, user *entity.User) error {
-	args := m.Called(ctx, user)
-	return args.Error(0)
+func (m *MockWeatherUsecase) ProcessWeatherForUser(ctx context.Context, user *entity.User, opts usecase.ProcessOptions) (*usecase.UserDecision, error) {
+	args := m.Called(ctx, user, opts)
+	if d := args.Get(0); d != nil {
+		return d.(*usecase.UserDecision), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWeatherUsecase) GetWeeklySummary(ctx context.Context, userID int) (*usecase.WeeklySummary, error) {
+	args := m.Called(ctx, userID)
+	if s := args.Get(0); s != nil {
+		return s.(*usecase.WeeklySummary), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWeatherUsecase) RecheckWeatherForUsers(ctx context.Context) (*usecase.BatchResult, error) {
+	args := m.Called(ctx)
+	if r := args.Get(0); r != nil {
+		return r.(*usecase.BatchResult), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWeatherUsecase) ReplayEvaluations(ctx context.Context, from, to time.Time) (*usecase.ReplayReport, error) {
+	args := m.Called(ctx, from, to)
+	if r := args.Get(0); r != nil {
+		return r.(*usecase.ReplayReport), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWeatherUsecase) ListUserRules(ctx context.Context, userID int) (*usecase.UserRules, error) {
+	args := m.Called(ctx, userID)
+	if r := args.Get(0); r != nil {
+		return r.(*usecase.UserRules), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWeatherUsecase) SetUserRule(ctx context.Context, userID int, rule usecase.UserRule) (*usecase.UserRules, error) {
+	args := m.Called(ctx, userID, rule)
+	if r := args.Get(0); r != nil {
+		return r.(*usecase.UserRules), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWeatherUsecase) ResetUserRules(ctx context.Context, userID int, weatherCode, category string) (*usecase.UserRules, error) {
+	args := m.Called(ctx, userID, weatherCode, category)
+	if r := args.Get(0); r != nil {
+		return r.(*usecase.UserRules), args.Error(1)
+	}
+	return nil, args.Error(1)
 }
 
 // テスト対象のコントローラーを初期化する関数
@@ -64,15 +120,19 @@ func TestProcessWeather_WithValidQueryParams(t *testing.T) {
 	expectedEnd := time.Date(now.Year(), now.Month(), now.Day(), expectedEndTime.Hour(), expectedEndTime.Minute(), 0, 0, utils.JST)
 
 	// モックの挙動を設定
-	mockWUC.On("ProcessWeatherForUsersInTimeRange", mock.Anything, expectedStart, expectedEnd).Return(nil)
+	mockWUC.On("ProcessWeatherForUsersInTimeRange", mock.Anything, expectedStart, expectedEnd, usecase.ProcessOptions{}).
+		Return(&usecase.BatchResult{Matched: 2, Evaluated: 2, Notified: 1, Skipped: 1, Failures: []*usecase.UserError{}}, nil)
 
 	// エンドポイント呼び出し
 	if assert.NoError(t, weatherCtrl.ProcessWeather(ctx)) {
 		assert.Equal(t, http.StatusOK, rec.Code)
-		var resp map[string]string
+		var resp map[string]interface{}
 		err := json.Unmarshal(rec.Body.Bytes(), &resp)
 		assert.NoError(t, err)
-		assert.Equal(t, "Weather processing completed", resp["message"])
+		assert.Equal(t, float64(2), resp["matched"])
+		assert.Equal(t, float64(1), resp["notified"])
+		assert.Equal(t, float64(1), resp["skipped"])
+		assert.Empty(t, resp["failures"])
 	}
 
 	mockWUC.AssertExpectations(t)
@@ -120,16 +180,299 @@ func TestProcessWeather_NoQueryParams(t *testing.T) {
 	// クエリパラメータなし：デフォルトの時間範囲を使用するケース
 	// モックが受け取る引数の具体的な開始・終了時刻は動的になるため、anyTimesやArgument matcherを使用
 
-	mockWUC.On("ProcessWeatherForUsersInTimeRange", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return(nil)
+	mockWUC.On("ProcessWeatherForUsersInTimeRange", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time"), usecase.ProcessOptions{}).
+		Return(&usecase.BatchResult{Failures: []*usecase.UserError{}}, nil)
 
 	// エンドポイント呼び出し
 	if assert.NoError(t, weatherCtrl.ProcessWeather(ctx)) {
 		assert.Equal(t, http.StatusOK, rec.Code)
+		var resp map[string]interface{}
+		err := json.Unmarshal(rec.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		assert.Equal(t, float64(0), resp["matched"])
+	}
+
+	mockWUC.AssertExpectations(t)
+}
+
+func TestProcessWeather_Degraded(t *testing.T) {
+	weatherCtrl, mockWUC, ctx, rec := setupWeatherController()
+
+	mockWUC.On("ProcessWeatherForUsersInTimeRange", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time"), usecase.ProcessOptions{}).
+		Return(&usecase.BatchResult{
+			Matched:     2,
+			Evaluated:   1,
+			Skipped:     1,
+			Failed:      1,
+			FailureRate: 0.5,
+			Degraded:    true,
+			Failures:    []*usecase.UserError{{UserID: 2, Err: errors.New("fetch failed")}},
+		}, nil)
+
+	if assert.NoError(t, weatherCtrl.ProcessWeather(ctx)) {
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		var resp struct {
+			Failed   int  `json:"failed"`
+			Degraded bool `json:"degraded"`
+			Failures []struct {
+				UserID int    `json:"userId"`
+				Reason string `json:"reason"`
+			} `json:"failures"`
+		}
+		err := json.Unmarshal(rec.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, resp.Failed)
+		assert.True(t, resp.Degraded)
+		if assert.Len(t, resp.Failures, 1) {
+			assert.Equal(t, 2, resp.Failures[0].UserID)
+			assert.Equal(t, "fetch failed", resp.Failures[0].Reason)
+		}
+	}
+}
+
+func TestProcessWeather_UsecaseError(t *testing.T) {
+	weatherCtrl, mockWUC, ctx, rec := setupWeatherController()
+
+	mockWUC.On("ProcessWeatherForUsersInTimeRange", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time"), usecase.ProcessOptions{}).
+		Return(nil, errors.New("db error"))
+
+	if assert.NoError(t, weatherCtrl.ProcessWeather(ctx)) {
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
 		var resp map[string]string
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		assert.Equal(t, "db error", resp["error"])
+	}
+}
+
+func TestProcessWeather_DryRun(t *testing.T) {
+	weatherCtrl, mockWUC, ctx, rec := setupWeatherController()
+
+	q := ctx.QueryParams()
+	q.Add("dry_run", "true")
+	ctx.Request().URL.RawQuery = q.Encode()
+
+	mockWUC.On("ProcessWeatherForUsersInTimeRange", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time"), usecase.ProcessOptions{DryRun: true}).
+		Return(&usecase.BatchResult{
+			Matched:   1,
+			Evaluated: 1,
+			Notified:  1,
+			Failures:  []*usecase.UserError{},
+			DryRun:    true,
+			Decisions: []*usecase.UserDecision{{UserID: 1, IsNotifyTrigger: true, Notify: true, WeatherCodes: []string{"300"}, MatchedCodes: []string{"300"}}},
+		}, nil)
+
+	if assert.NoError(t, weatherCtrl.ProcessWeather(ctx)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var resp struct {
+			DryRun    bool `json:"dryRun"`
+			Decisions []struct {
+				UserID       int      `json:"userId"`
+				Notify       bool     `json:"notify"`
+				MatchedCodes []string `json:"matchedCodes"`
+			} `json:"decisions"`
+		}
 		err := json.Unmarshal(rec.Body.Bytes(), &resp)
 		assert.NoError(t, err)
-		assert.Equal(t, "Weather processing completed", resp["message"])
+		assert.True(t, resp.DryRun)
+		if assert.Len(t, resp.Decisions, 1) {
+			assert.Equal(t, 1, resp.Decisions[0].UserID)
+			assert.True(t, resp.Decisions[0].Notify)
+			assert.Equal(t, []string{"300"}, resp.Decisions[0].MatchedCodes)
+		}
+	}
+
+	mockWUC.AssertExpectations(t)
+}
+
+func TestProcessWeather_InvalidDryRun(t *testing.T) {
+	weatherCtrl, _, ctx, rec := setupWeatherController()
+
+	q := ctx.QueryParams()
+	q.Add("dry_run", "maybe")
+	ctx.Request().URL.RawQuery = q.Encode()
+
+	err := weatherCtrl.ProcessWeather(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetWeeklySummary(t *testing.T) {
+	weatherCtrl, mockWUC, ctx, rec := setupWeatherController()
+	ctx.SetParamNames("id")
+	ctx.SetParamValues("1")
+
+	pop := 70
+	summary := &usecase.WeeklySummary{
+		UserID:   1,
+		AreaName: "東京地方",
+		Days: []usecase.WeeklySummaryDay{
+			{Date: "2024-06-15", WeatherCode: "300", Pop: &pop, Reliability: "C", IsNotifyTrigger: true},
+		},
 	}
+	mockWUC.On("GetWeeklySummary", mock.Anything, 1).Return(summary, nil)
+
+	if assert.NoError(t, weatherCtrl.GetWeeklySummary(ctx)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var resp usecase.WeeklySummary
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "東京地方", resp.AreaName)
+		assert.Len(t, resp.Days, 1)
+		assert.True(t, resp.Days[0].IsNotifyTrigger)
+	}
+	mockWUC.AssertExpectations(t)
+}
+
+func TestGetWeeklySummary_NotFound(t *testing.T) {
+	weatherCtrl, mockWUC, ctx, rec := setupWeatherController()
+	ctx.SetParamNames("id")
+	ctx.SetParamValues("99")
+
+	mockWUC.On("GetWeeklySummary", mock.Anything, 99).Return(nil, nil)
+
+	assert.NoError(t, weatherCtrl.GetWeeklySummary(ctx))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestGetWeeklySummary_InvalidID(t *testing.T) {
+	weatherCtrl, _, ctx, rec := setupWeatherController()
+	ctx.SetParamNames("id")
+	ctx.SetParamValues("abc")
+
+	assert.NoError(t, weatherCtrl.GetWeeklySummary(ctx))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestRecheckWeather(t *testing.T) {
+	weatherCtrl, mockWUC, ctx, rec := setupWeatherController()
+	// 閾値以下の失敗は 200 で結果を返す
+	mockWUC.On("RecheckWeatherForUsers", mock.Anything).Return(&usecase.BatchResult{
+		Matched:     20,
+		Evaluated:   19,
+		Skipped:     19,
+		Failed:      1,
+		FailureRate: 0.05,
+		Failures:    []*usecase.UserError{{UserID: 2, Err: errors.New("fetch failed")}},
+	}, nil)
+
+	if assert.NoError(t, weatherCtrl.RecheckWeather(ctx)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, float64(20), resp["matched"])
+		assert.Equal(t, float64(1), resp["failed"])
+	}
+	mockWUC.AssertExpectations(t)
+}
+
+func TestRecheckWeather_Degraded(t *testing.T) {
+	weatherCtrl, mockWUC, ctx, rec := setupWeatherController()
+	mockWUC.On("RecheckWeatherForUsers", mock.Anything).Return(&usecase.BatchResult{
+		Matched:     2,
+		Evaluated:   1,
+		Failed:      1,
+		FailureRate: 0.5,
+		Degraded:    true,
+		Failures:    []*usecase.UserError{{UserID: 2, Err: errors.New("fetch failed")}},
+	}, nil)
+
+	if assert.NoError(t, weatherCtrl.RecheckWeather(ctx)) {
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, true, resp["degraded"])
+	}
+}
+
+func TestRecheckWeather_UsecaseError(t *testing.T) {
+	weatherCtrl, mockWUC, ctx, rec := setupWeatherController()
+	mockWUC.On("RecheckWeatherForUsers", mock.Anything).Return(nil, errors.New("db error"))
 
+	if assert.NoError(t, weatherCtrl.RecheckWeather(ctx)) {
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	}
+}
+
+func TestListUserRules(t *testing.T) {
+	mockWUC := new(MockWeatherUsecase)
+	weatherCtrl := controller.NewWeatherController(mockWUC)
+	ctx, rec := newTestContext(http.MethodGet, "/api/users/1/rules", nil)
+	ctx.SetParamNames("id")
+	ctx.SetParamValues("1")
+
+	rules := &usecase.UserRules{UserID: 1, Overrides: []usecase.UserRule{
+		{Category: entity.WeatherCategorySnow, IsNotifyTrigger: true},
+	}}
+	mockWUC.On("ListUserRules", mock.Anything, 1).Return(rules, nil)
+
+	if assert.NoError(t, weatherCtrl.ListUserRules(ctx)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var resp usecase.UserRules
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, rules.Overrides, resp.Overrides)
+	}
+	mockWUC.AssertExpectations(t)
+}
+
+func TestSetUserRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{name: "weather code", body: `{"weatherCode":"200","isNotifyTrigger":true}`, wantCode: http.StatusOK},
+		{name: "category", body: `{"category":"snow","isNotifyTrigger":false}`, wantCode: http.StatusOK},
+		{name: "no target", body: `{"isNotifyTrigger":true}`, wantCode: http.StatusBadRequest},
+		{name: "both targets", body: `{"weatherCode":"200","category":"snow"}`, wantCode: http.StatusBadRequest},
+		{name: "invalid code", body: `{"weatherCode":"20a"}`, wantCode: http.StatusBadRequest},
+		{name: "thunder category", body: `{"category":"thunder","isNotifyTrigger":true}`, wantCode: http.StatusOK},
+		{name: "invalid category", body: `{"category":"hail"}`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWUC := new(MockWeatherUsecase)
+			weatherCtrl := controller.NewWeatherController(mockWUC)
+			ctx, rec := newTestContext(http.MethodPut, "/api/users/1/rules", []byte(tt.body))
+			ctx.SetParamNames("id")
+			ctx.SetParamValues("1")
+
+			mockWUC.On("SetUserRule", mock.Anything, 1, mock.AnythingOfType("usecase.UserRule")).
+				Return(&usecase.UserRules{UserID: 1}, nil)
+
+			assert.NoError(t, weatherCtrl.SetUserRule(ctx))
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode != http.StatusOK {
+				mockWUC.AssertNotCalled(t, "SetUserRule", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestSetUserRule_UserNotFound(t *testing.T) {
+	mockWUC := new(MockWeatherUsecase)
+	weatherCtrl := controller.NewWeatherController(mockWUC)
+	ctx, rec := newTestContext(http.MethodPut, "/api/users/99/rules", []byte(`{"weatherCode":"200","isNotifyTrigger":true}`))
+	ctx.SetParamNames("id")
+	ctx.SetParamValues("99")
+
+	mockWUC.On("SetUserRule", mock.Anything, 99, usecase.UserRule{WeatherCode: "200", IsNotifyTrigger: true}).Return(nil, nil)
+
+	assert.NoError(t, weatherCtrl.SetUserRule(ctx))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	mockWUC.AssertExpectations(t)
+}
+
+func TestResetUserRules(t *testing.T) {
+	mockWUC := new(MockWeatherUsecase)
+	weatherCtrl := controller.NewWeatherController(mockWUC)
+	ctx, rec := newTestContext(http.MethodDelete, "/api/users/1/rules?category=snow", nil)
+	ctx.SetParamNames("id")
+	ctx.SetParamValues("1")
+
+	mockWUC.On("ResetUserRules", mock.Anything, 1, "", "snow").Return(&usecase.UserRules{UserID: 1}, nil)
+
+	if assert.NoError(t, weatherCtrl.ResetUserRules(ctx)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
 	mockWUC.AssertExpectations(t)
 }
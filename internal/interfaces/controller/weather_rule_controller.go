@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+type WeatherRuleController struct {
+	weatherRuleUC usecase.WeatherRuleUsecase
+}
+
+func NewWeatherRuleController(wruc usecase.WeatherRuleUsecase) *WeatherRuleController {
+	return &WeatherRuleController{weatherRuleUC: wruc}
+}
+
+// WeatherRuleRequest は通知ルールの登録・更新時のJSONリクエストボディ
+// 更新時の weatherCode はパスの値を使う
+type WeatherRuleRequest struct {
+	WeatherCode        string `json:"weatherCode"`
+	WeatherDescription string `json:"weatherDescription"`
+	IsNotifyTrigger    bool   `json:"isNotifyTrigger"`
+	Precipitation      string `json:"precipitation"`
+	Intensity          string `json:"intensity"`
+	HasThunder         bool   `json:"hasThunder"`
+	HasFog             bool   `json:"hasFog"`
+	Severity           int    `json:"severity"`
+}
+
+// 深刻度の上限（強い降水 3 + 雷 1 に余裕を持たせる）
+const maxSeverity = 10
+
+// validateWeatherRule は天気コードが3桁の数字で、属性が定義済みの値か確認する（降水の種類・強さの未指定は「なし」扱い）
+func validateWeatherRule(req *WeatherRuleRequest) error {
+	if !weatherCodePattern.MatchString(req.WeatherCode) {
+		return fmt.Errorf("invalid weather code: %q", req.WeatherCode)
+	}
+	if req.WeatherDescription == "" {
+		return fmt.Errorf("weather description is required")
+	}
+	switch req.Precipitation {
+	case "", entity.PrecipitationNone, entity.PrecipitationRain, entity.PrecipitationSnow, entity.PrecipitationMixed:
+	default:
+		return fmt.Errorf("invalid precipitation: %q", req.Precipitation)
+	}
+	switch req.Intensity {
+	case "", entity.IntensityNone, entity.IntensityLight, entity.IntensityModerate, entity.IntensityHeavy:
+	default:
+		return fmt.Errorf("invalid intensity: %q", req.Intensity)
+	}
+	if req.Severity < 0 || req.Severity > maxSeverity {
+		return fmt.Errorf("severity must be between 0 and %d", maxSeverity)
+	}
+	return nil
+}
+
+func (req *WeatherRuleRequest) toEntity() *entity.WeatherRule {
+	return &entity.WeatherRule{
+		WeatherCode:        req.WeatherCode,
+		WeatherDescription: req.WeatherDescription,
+		IsNotifyTrigger:    req.IsNotifyTrigger,
+		Precipitation:      req.Precipitation,
+		Intensity:          req.Intensity,
+		HasThunder:         req.HasThunder,
+		HasFog:             req.HasFog,
+		Severity:           req.Severity,
+	}
+}
+
+// GET /api/admin/weather_rules
+func (ctrl *WeatherRuleController) List(c echo.Context) error {
+	rules, err := ctrl.weatherRuleUC.ListRules(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, rules)
+}
+
+// POST /api/admin/weather_rules
+func (ctrl *WeatherRuleController) Create(c echo.Context) error {
+	var req WeatherRuleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+	}
+	if err := validateWeatherRule(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	rule := req.toEntity()
+	created, err := ctrl.weatherRuleUC.CreateRule(c.Request().Context(), rule)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !created {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "weather rule already exists"})
+	}
+	return c.JSON(http.StatusCreated, rule)
+}
+
+// PUT /api/admin/weather_rules/:code
+func (ctrl *WeatherRuleController) Update(c echo.Context) error {
+	var req WeatherRuleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+	}
+	req.WeatherCode = c.Param("code")
+	if err := validateWeatherRule(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	rule := req.toEntity()
+	updated, err := ctrl.weatherRuleUC.UpdateRule(c.Request().Context(), rule)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !updated {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "weather rule not found"})
+	}
+	return c.JSON(http.StatusOK, rule)
+}
+
+// DELETE /api/admin/weather_rules/:code
+func (ctrl *WeatherRuleController) Delete(c echo.Context) error {
+	code := c.Param("code")
+	if !weatherCodePattern.MatchString(code) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid weather code: %q", code)})
+	}
+
+	deleted, err := ctrl.weatherRuleUC.DeleteRule(c.Request().Context(), code)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !deleted {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "weather rule not found"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "weather rule deleted"})
+}
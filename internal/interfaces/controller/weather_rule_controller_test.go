@@ -0,0 +1,151 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/controller"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockWeatherRuleUsecase struct {
+	mock.Mock
+}
+
+func (m *MockWeatherRuleUsecase) ListRules(ctx context.Context) ([]*entity.WeatherRule, error) {
+	args := m.Called(ctx)
+	if r := args.Get(0); r != nil {
+		return r.([]*entity.WeatherRule), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockWeatherRuleUsecase) CreateRule(ctx context.Context, rule *entity.WeatherRule) (bool, error) {
+	args := m.Called(ctx, rule)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWeatherRuleUsecase) UpdateRule(ctx context.Context, rule *entity.WeatherRule) (bool, error) {
+	args := m.Called(ctx, rule)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWeatherRuleUsecase) DeleteRule(ctx context.Context, weatherCode string) (bool, error) {
+	args := m.Called(ctx, weatherCode)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestWeatherRuleController_List(t *testing.T) {
+	mockUC := new(MockWeatherRuleUsecase)
+	ctrl := controller.NewWeatherRuleController(mockUC)
+	ctx, rec := newTestContext(http.MethodGet, "/api/admin/weather_rules", nil)
+
+	mockUC.On("ListRules", mock.Anything).Return([]*entity.WeatherRule{
+		{WeatherCode: "100", WeatherDescription: "晴"},
+		{WeatherCode: "300", WeatherDescription: "雨", IsNotifyTrigger: true},
+	}, nil)
+
+	if assert.NoError(t, ctrl.List(ctx)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var resp []entity.WeatherRule
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Len(t, resp, 2)
+	}
+	mockUC.AssertExpectations(t)
+}
+
+func TestWeatherRuleController_Create(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		created  bool
+		wantCode int
+	}{
+		{name: "created", body: `{"weatherCode":"209","weatherDescription":"霧","hasFog":true}`, created: true, wantCode: http.StatusCreated},
+		{name: "already exists", body: `{"weatherCode":"100","weatherDescription":"晴"}`, created: false, wantCode: http.StatusConflict},
+		{name: "invalid code", body: `{"weatherCode":"1000","weatherDescription":"晴"}`, wantCode: http.StatusBadRequest},
+		{name: "non numeric code", body: `{"weatherCode":"1a0","weatherDescription":"晴"}`, wantCode: http.StatusBadRequest},
+		{name: "no description", body: `{"weatherCode":"100"}`, wantCode: http.StatusBadRequest},
+		{name: "invalid precipitation", body: `{"weatherCode":"100","weatherDescription":"晴","precipitation":"hail"}`, wantCode: http.StatusBadRequest},
+		{name: "invalid intensity", body: `{"weatherCode":"300","weatherDescription":"雨","intensity":"extreme"}`, wantCode: http.StatusBadRequest},
+		{name: "invalid severity", body: `{"weatherCode":"300","weatherDescription":"雨","severity":-1}`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUC := new(MockWeatherRuleUsecase)
+			ctrl := controller.NewWeatherRuleController(mockUC)
+			ctx, rec := newTestContext(http.MethodPost, "/api/admin/weather_rules", []byte(tt.body))
+
+			mockUC.On("CreateRule", mock.Anything, mock.AnythingOfType("*entity.WeatherRule")).Return(tt.created, nil)
+
+			assert.NoError(t, ctrl.Create(ctx))
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantCode == http.StatusBadRequest {
+				mockUC.AssertNotCalled(t, "CreateRule", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestWeatherRuleController_Update(t *testing.T) {
+	mockUC := new(MockWeatherRuleUsecase)
+	ctrl := controller.NewWeatherRuleController(mockUC)
+	// ボディの weatherCode ではなくパスの値を使う
+	ctx, rec := newTestContext(http.MethodPut, "/api/admin/weather_rules/200", []byte(`{"weatherCode":"999","weatherDescription":"曇","isNotifyTrigger":true}`))
+	ctx.SetParamNames("code")
+	ctx.SetParamValues("200")
+
+	mockUC.On("UpdateRule", mock.Anything, mock.MatchedBy(func(r *entity.WeatherRule) bool {
+		return r.WeatherCode == "200" && r.IsNotifyTrigger
+	})).Return(true, nil)
+
+	assert.NoError(t, ctrl.Update(ctx))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockUC.AssertExpectations(t)
+}
+
+func TestWeatherRuleController_Update_NotFound(t *testing.T) {
+	mockUC := new(MockWeatherRuleUsecase)
+	ctrl := controller.NewWeatherRuleController(mockUC)
+	ctx, rec := newTestContext(http.MethodPut, "/api/admin/weather_rules/999", []byte(`{"weatherDescription":"不明"}`))
+	ctx.SetParamNames("code")
+	ctx.SetParamValues("999")
+
+	mockUC.On("UpdateRule", mock.Anything, mock.AnythingOfType("*entity.WeatherRule")).Return(false, nil)
+
+	assert.NoError(t, ctrl.Update(ctx))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestWeatherRuleController_Delete(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		deleted  bool
+		wantCode int
+	}{
+		{name: "deleted", code: "209", deleted: true, wantCode: http.StatusOK},
+		{name: "not found", code: "999", deleted: false, wantCode: http.StatusNotFound},
+		{name: "invalid code", code: "abc", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUC := new(MockWeatherRuleUsecase)
+			ctrl := controller.NewWeatherRuleController(mockUC)
+			ctx, rec := newTestContext(http.MethodDelete, "/api/admin/weather_rules/"+tt.code, nil)
+			ctx.SetParamNames("code")
+			ctx.SetParamValues(tt.code)
+
+			mockUC.On("DeleteRule", mock.Anything, tt.code).Return(tt.deleted, nil)
+
+			assert.NoError(t, ctrl.Delete(ctx))
+			assert.Equal(t, tt.wantCode, rec.Code)
+		})
+	}
+}
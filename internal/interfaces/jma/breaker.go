@@ -0,0 +1,100 @@
+package jma
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen は失敗が続いているホストへのリクエストを送らずに失敗させたことを表す
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+// circuitBreaker はホストごとの連続した失敗を数え、threshold 回続いたら cooldown の間リクエストを止める
+// cooldown が過ぎたら1件だけ試し、成功すれば元に戻す
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	failures int
+	openedAt time.Time // ゼロ値なら閉じている
+	probing  bool      // cooldown 後の試しのリクエストを送っている
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		hosts:     make(map[string]*hostState),
+	}
+}
+
+func (b *circuitBreaker) state(host string) *hostState {
+	s, ok := b.hosts[host]
+	if !ok {
+		s = &hostState{}
+		b.hosts[host] = s
+	}
+	return s
+}
+
+// allow は host にリクエストを送ってよいかを返す
+func (b *circuitBreaker) allow(host string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.state(host)
+	if s.openedAt.IsZero() {
+		return true
+	}
+	if s.probing || b.now().Sub(s.openedAt) < b.cooldown {
+		return false
+	}
+	s.probing = true
+	return true
+}
+
+func (b *circuitBreaker) success(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.state(host)
+	if !s.openedAt.IsZero() {
+		log.Printf("[INFO] JMA circuit breaker closed for %s\n", host)
+	}
+	*s = hostState{}
+}
+
+// abort は成否を判定できなかったリクエスト（呼び出し元のキャンセルなど）を数えずに終える
+func (b *circuitBreaker) abort(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state(host).probing = false
+}
+
+func (b *circuitBreaker) failure(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.state(host)
+	s.failures++
+	// 試しのリクエストが失敗したら、もう一度 cooldown の間止める
+	if s.probing || (s.openedAt.IsZero() && s.failures >= b.threshold) {
+		log.Printf("[WARN] JMA circuit breaker opened for %s after %d failures\n", host, s.failures)
+		s.openedAt = b.now()
+		s.probing = false
+	}
+}
@@ -0,0 +1,286 @@
+package jma
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBaseURL        = "https://www.jma.go.jp/bosai"
+	DefaultTimeout        = 10 * time.Second
+	DefaultUserAgent      = "weather-bot"
+	DefaultMaxAttempts    = 3
+	DefaultRetryBaseDelay = 200 * time.Millisecond
+	DefaultRetryMaxDelay  = 2 * time.Second
+)
+
+type ForecastFetcher interface {
+	FetchForecast(ctx context.Context, officeID string) (*Forecast, error)
+}
+
+// WarningFetcher は府県単位の警報・注意報を取得する
+type WarningFetcher interface {
+	FetchWarning(ctx context.Context, officeID string) (*WarningReport, error)
+}
+
+// Config は JMA クライアントの接続設定
+// ステージングや CI ではローカルのフィクスチャサーバーを BaseURL に指定する
+// 0 の項目はデフォルト値を使う
+type Config struct {
+	BaseURL    string
+	Timeout    time.Duration // 1回のリクエストのタイムアウト
+	UserAgent  string
+	HTTPClient *http.Client
+
+	MaxAttempts    int           // 再試行を含めたリクエストの回数
+	RetryBaseDelay time.Duration // 1回目の再試行までの間隔。以降は倍にし、揺らぎを加える
+	RetryMaxDelay  time.Duration
+
+	BreakerThreshold int           // ホストへのリクエストがこの回数続けて失敗したら止める
+	BreakerCooldown  time.Duration // 止めてから試しのリクエストを送るまでの時間
+}
+
+// Client は JMA の bosai API クライアント
+type Client struct {
+	baseURL        string
+	timeout        time.Duration
+	userAgent      string
+	httpClient     *http.Client
+	maxAttempts    int
+	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
+	breaker        *circuitBreaker
+
+	mu        sync.Mutex
+	documents map[string]*document // URL → 前回のレスポンス
+}
+
+// document は条件付き GET のために保持する前回のレスポンス
+type document struct {
+	etag         string
+	lastModified string
+	value        any // パースした結果
+}
+
+// response は1回の GET の結果。notModified なら body は空
+type response struct {
+	notModified bool
+	header      http.Header
+	body        []byte
+}
+
+func NewClient(cfg Config) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(cfg.BaseURL, "/"),
+		timeout:    cfg.Timeout,
+		userAgent:  cfg.UserAgent,
+		httpClient: cfg.HTTPClient,
+	}
+	if c.baseURL == "" {
+		c.baseURL = DefaultBaseURL
+	}
+	if c.timeout <= 0 {
+		c.timeout = DefaultTimeout
+	}
+	if c.userAgent == "" {
+		c.userAgent = DefaultUserAgent
+	}
+	if c.httpClient == nil {
+		c.httpClient = http.DefaultClient
+	}
+	c.maxAttempts = cfg.MaxAttempts
+	if c.maxAttempts <= 0 {
+		c.maxAttempts = DefaultMaxAttempts
+	}
+	c.retryBaseDelay = cfg.RetryBaseDelay
+	if c.retryBaseDelay <= 0 {
+		c.retryBaseDelay = DefaultRetryBaseDelay
+	}
+	c.retryMaxDelay = cfg.RetryMaxDelay
+	if c.retryMaxDelay <= 0 {
+		c.retryMaxDelay = DefaultRetryMaxDelay
+	}
+	threshold := cfg.BreakerThreshold
+	if threshold <= 0 {
+		threshold = DefaultBreakerThreshold
+	}
+	cooldown := cfg.BreakerCooldown
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+	c.breaker = newCircuitBreaker(threshold, cooldown)
+	c.documents = make(map[string]*document)
+	return c
+}
+
+// FetchForecast は area_offices の ID に対応する予報を取得してパースする
+// 前回から更新されていなければ、前回パースした予報を NotModified にして返す
+func (c *Client) FetchForecast(ctx context.Context, officeID string) (*Forecast, error) {
+	value, notModified, err := c.fetchDocument(ctx, fmt.Sprintf("%s/forecast/data/forecast/%s.json", c.baseURL, officeID), func(body []byte) (any, error) {
+		return ParseForecast(body)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// キャッシュした予報は他の呼び出しと共有するのでコピーして返す
+	forecast := *value.(*Forecast)
+	forecast.NotModified = notModified
+	return &forecast, nil
+}
+
+// FetchWarning は area_offices の ID に対応する警報・注意報を取得してパースする
+func (c *Client) FetchWarning(ctx context.Context, officeID string) (*WarningReport, error) {
+	value, _, err := c.fetchDocument(ctx, fmt.Sprintf("%s/warning/data/warning/%s.json", c.baseURL, officeID), func(body []byte) (any, error) {
+		return ParseWarning(body)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*WarningReport), nil
+}
+
+// fetchDocument は前回の ETag / Last-Modified を付けて条件付き GET し、parse した結果を返す
+// 304 なら前回 parse した結果を返し、notModified を true にする
+func (c *Client) fetchDocument(ctx context.Context, rawURL string, parse func(body []byte) (any, error)) (any, bool, error) {
+	c.mu.Lock()
+	prev := c.documents[rawURL]
+	c.mu.Unlock()
+
+	header := http.Header{}
+	if prev != nil {
+		if prev.etag != "" {
+			header.Set("If-None-Match", prev.etag)
+		}
+		if prev.lastModified != "" {
+			header.Set("If-Modified-Since", prev.lastModified)
+		}
+	}
+
+	resp, err := c.get(ctx, rawURL, header)
+	if err != nil {
+		return nil, false, err
+	}
+	if resp.notModified {
+		if prev == nil {
+			return nil, false, fmt.Errorf("unexpected status fetching %s: %d", rawURL, http.StatusNotModified)
+		}
+		return prev.value, true, nil
+	}
+
+	value, err := parse(resp.body)
+	if err != nil {
+		return nil, false, err
+	}
+	doc := &document{
+		etag:         resp.header.Get("ETag"),
+		lastModified: resp.header.Get("Last-Modified"),
+		value:        value,
+	}
+	c.mu.Lock()
+	if doc.etag != "" || doc.lastModified != "" {
+		c.documents[rawURL] = doc
+	} else {
+		delete(c.documents, rawURL)
+	}
+	c.mu.Unlock()
+	return value, false, nil
+}
+
+// get は header を付けて GET し、レスポンスを返す。304 は失敗にしない
+// 通信エラー・5xx・429 は間隔を空けて再試行し、失敗が続いたホストには送らずに ErrCircuitOpen を返す
+func (c *Client) get(ctx context.Context, rawURL string, header http.Header) (*response, error) {
+	host := hostOf(rawURL)
+
+	var lastErr error
+	for attempt := 0; attempt < c.maxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(c.retryDelay(attempt)):
+			case <-ctx.Done():
+				// 待っている間のキャンセルは JMA の失敗として数えず、呼び出し元に分かるよう ctx のエラーで返す
+				return nil, fmt.Errorf("failed to retry %s: %w: %w", rawURL, ctx.Err(), lastErr)
+			}
+		}
+		if !c.breaker.allow(host) {
+			return nil, fmt.Errorf("failed to fetch %s: %w", rawURL, ErrCircuitOpen)
+		}
+
+		resp, retryable, err := c.getOnce(ctx, rawURL, header)
+		switch {
+		case err == nil:
+			c.breaker.success(host)
+			return resp, nil
+		case ctx.Err() != nil:
+			// 呼び出し元のキャンセルは JMA の失敗として数えない
+			c.breaker.abort(host)
+			return nil, err
+		case !retryable:
+			// 404 などはホストが応答しているので、止める理由にはならない
+			c.breaker.success(host)
+			return nil, err
+		}
+		c.breaker.failure(host)
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// retryDelay は attempt 回目の再試行までの間隔を返す
+// 同時に失敗したリクエストが揃って再試行しないよう、上限までの指数的な間隔の半分から全部の間で揺らす
+func (c *Client) retryDelay(attempt int) time.Duration {
+	d := c.retryBaseDelay << (attempt - 1)
+	if d <= 0 || d > c.retryMaxDelay {
+		d = c.retryMaxDelay
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// getOnce は タイムアウトと User-Agent を付けて1回 GET する。失敗したときは再試行してよいかも返す
+func (c *Client) getOnce(ctx context.Context, rawURL string, header http.Header) (*response, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return &response{notModified: true, header: resp.Header}, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retryable, fmt.Errorf("unexpected status fetching %s: %d", rawURL, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to read response from %s: %w", rawURL, err)
+	}
+	return &response{header: resp.Header, body: body}, false, nil
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Host
+}
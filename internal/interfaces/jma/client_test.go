@@ -0,0 +1,320 @@
+package jma_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchForecast_Success(t *testing.T) {
+	body, err := os.ReadFile("testdata/forecast_130000.json")
+	require.NoError(t, err)
+
+	var gotPath, gotUA string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotUA = r.Header.Get("User-Agent")
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	client := jma.NewClient(jma.Config{
+		BaseURL:    srv.URL + "/bosai/",
+		UserAgent:  "weather-bot-test",
+		HTTPClient: srv.Client(),
+	})
+
+	forecast, err := client.FetchForecast(context.Background(), "130000")
+	require.NoError(t, err)
+	assert.Equal(t, "/bosai/forecast/data/forecast/130000.json", gotPath)
+	assert.Equal(t, "weather-bot-test", gotUA)
+	assert.Len(t, forecast.Reports, 2)
+	assert.Equal(t, body, forecast.Raw)
+}
+
+func TestFetchForecast_NonOKStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	client := jma.NewClient(jma.Config{BaseURL: srv.URL, HTTPClient: srv.Client()})
+
+	forecast, err := client.FetchForecast(context.Background(), "999999")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unexpected status")
+	assert.Nil(t, forecast)
+}
+
+func TestFetchForecast_Timeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 応答しない JMA を再現する
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	client := jma.NewClient(jma.Config{
+		BaseURL:    srv.URL,
+		Timeout:    50 * time.Millisecond,
+		HTTPClient: srv.Client(),
+	})
+
+	start := time.Now()
+	_, err := client.FetchForecast(context.Background(), "130000")
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+// newRetryClient は再試行の間隔を短くしたクライアントを作る
+func newRetryClient(srv *httptest.Server, cfg jma.Config) *jma.Client {
+	cfg.BaseURL = srv.URL
+	cfg.HTTPClient = srv.Client()
+	cfg.RetryBaseDelay = time.Millisecond
+	cfg.RetryMaxDelay = 5 * time.Millisecond
+	return jma.NewClient(cfg)
+}
+
+func TestFetchForecast_RetriesServerError(t *testing.T) {
+	body, err := os.ReadFile("testdata/forecast_130000.json")
+	require.NoError(t, err)
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 2回目までは JMA の一時的な障害を再現する
+		if calls.Add(1) <= 2 {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	client := newRetryClient(srv, jma.Config{})
+
+	forecast, err := client.FetchForecast(context.Background(), "130000")
+	require.NoError(t, err)
+	assert.Len(t, forecast.Reports, 2)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestFetchForecast_GivesUpAfterMaxAttempts(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		// エラーページを JSON としてパースしない
+		http.Error(w, "<html>unavailable</html>", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := newRetryClient(srv, jma.Config{MaxAttempts: 4})
+
+	_, err := client.FetchForecast(context.Background(), "130000")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unexpected status")
+	assert.Contains(t, err.Error(), "503")
+	assert.Equal(t, int32(4), calls.Load())
+}
+
+func TestFetchForecast_DoesNotRetryClientError(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	client := newRetryClient(srv, jma.Config{BreakerThreshold: 1})
+
+	for i := 0; i < 3; i++ {
+		_, err := client.FetchForecast(context.Background(), "999999")
+		require.Error(t, err)
+		// 404 はホストの障害ではないので止めない
+		assert.NotErrorIs(t, err, jma.ErrCircuitOpen)
+	}
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestFetchForecast_CircuitBreaker(t *testing.T) {
+	body, err := os.ReadFile("testdata/forecast_130000.json")
+	require.NoError(t, err)
+
+	var calls atomic.Int32
+	var healthy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if !healthy.Load() {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	client := newRetryClient(srv, jma.Config{
+		MaxAttempts:      2,
+		BreakerThreshold: 4,
+		BreakerCooldown:  time.Minute,
+	})
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	jma.SetBreakerClock(client, func() time.Time { return now })
+	ctx := context.Background()
+
+	// 2回の取得で4回続けて失敗し、止まる
+	for i := 0; i < 2; i++ {
+		_, err := client.FetchForecast(ctx, "130000")
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, jma.ErrCircuitOpen)
+	}
+	assert.Equal(t, int32(4), calls.Load())
+
+	// 止まっている間はリクエストを送らない
+	_, err = client.FetchWarning(ctx, "130000")
+	assert.ErrorIs(t, err, jma.ErrCircuitOpen)
+	assert.Equal(t, int32(4), calls.Load())
+
+	// cooldown の間は止めたまま
+	now = now.Add(59 * time.Second)
+	_, err = client.FetchForecast(ctx, "130000")
+	assert.ErrorIs(t, err, jma.ErrCircuitOpen)
+	assert.Equal(t, int32(4), calls.Load())
+
+	// cooldown の後は1件だけ試し、失敗すればまた止める
+	now = now.Add(time.Second)
+	_, err = client.FetchForecast(ctx, "130000")
+	assert.ErrorIs(t, err, jma.ErrCircuitOpen)
+	assert.Equal(t, int32(5), calls.Load())
+
+	// 回復していれば元に戻す
+	healthy.Store(true)
+	now = now.Add(time.Minute)
+	_, err = client.FetchForecast(ctx, "130000")
+	require.NoError(t, err)
+	_, err = client.FetchForecast(ctx, "130000")
+	require.NoError(t, err)
+	assert.Equal(t, int32(7), calls.Load())
+}
+
+func TestFetchForecast_CanceledDuringBackoff(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	client := jma.NewClient(jma.Config{
+		BaseURL:        srv.URL,
+		HTTPClient:     srv.Client(),
+		RetryBaseDelay: time.Minute,
+		RetryMaxDelay:  time.Minute,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.FetchForecast(ctx, "130000")
+	require.Error(t, err)
+	// 呼び出し元には ctx のエラーと直前の失敗の両方が分かる
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "unexpected status")
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestFetchForecast_ConditionalGet(t *testing.T) {
+	body, err := os.ReadFile("testdata/forecast_130000.json")
+	require.NoError(t, err)
+
+	const etag = `"v1"`
+	var gotIfNoneMatch []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotIfNoneMatch = append(gotIfNoneMatch, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	client := jma.NewClient(jma.Config{BaseURL: srv.URL, HTTPClient: srv.Client()})
+	ctx := context.Background()
+
+	first, err := client.FetchForecast(ctx, "130000")
+	require.NoError(t, err)
+	assert.False(t, first.NotModified)
+
+	// 2回目は ETag を送り、304 なら前回の予報を返す
+	second, err := client.FetchForecast(ctx, "130000")
+	require.NoError(t, err)
+	assert.True(t, second.NotModified)
+	assert.Equal(t, first.Reports, second.Reports)
+	assert.Equal(t, body, second.Raw)
+	assert.Equal(t, []string{"", etag}, gotIfNoneMatch)
+
+	// 別のオフィスは前回の ETag を送らない
+	_, err = client.FetchForecast(ctx, "140000")
+	require.NoError(t, err)
+	assert.Equal(t, "", gotIfNoneMatch[2])
+}
+
+func TestFetchForecast_IfModifiedSince(t *testing.T) {
+	body, err := os.ReadFile("testdata/forecast_130000.json")
+	require.NoError(t, err)
+
+	const lastModified = "Mon, 10 Jun 2024 02:00:00 GMT"
+	var updated atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !updated.Load() && r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", lastModified)
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	client := jma.NewClient(jma.Config{BaseURL: srv.URL, HTTPClient: srv.Client()})
+	ctx := context.Background()
+
+	_, err = client.FetchForecast(ctx, "130000")
+	require.NoError(t, err)
+	cached, err := client.FetchForecast(ctx, "130000")
+	require.NoError(t, err)
+	assert.True(t, cached.NotModified)
+
+	// 更新されていれば取得し直す
+	updated.Store(true)
+	fresh, err := client.FetchForecast(ctx, "130000")
+	require.NoError(t, err)
+	assert.False(t, fresh.NotModified)
+}
+
+func TestFetchForecast_UnexpectedNotModified(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer srv.Close()
+
+	client := jma.NewClient(jma.Config{BaseURL: srv.URL, HTTPClient: srv.Client()})
+
+	// キャッシュが無いのに 304 が返っても予報として扱わない
+	_, err := client.FetchForecast(context.Background(), "130000")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "304")
+}
@@ -0,0 +1,8 @@
+package jma
+
+import "time"
+
+// SetBreakerClock は c のサーキットブレーカーが now を現在時刻として使うようにする
+func SetBreakerClock(c *Client, now func() time.Time) {
+	c.breaker.now = now
+}
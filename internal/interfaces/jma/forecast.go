@@ -0,0 +1,271 @@
+package jma
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Forecast は forecast/{office}.json 全体を表す
+// 配列の1要素目が3日間の短期予報、2要素目が週間予報
+type Forecast struct {
+	Reports []Report
+	// 取得した JSON そのもの（履歴保存用）
+	Raw []byte
+	// 前回の取得から更新されておらず、前回パースした予報を返した
+	NotModified bool
+}
+
+type Report struct {
+	PublishingOffice string       `json:"publishingOffice"`
+	ReportDatetime   time.Time    `json:"reportDatetime"`
+	TimeSeries       []TimeSeries `json:"timeSeries"`
+}
+
+type TimeSeries struct {
+	TimeDefines []time.Time    `json:"timeDefines"`
+	Areas       []AreaForecast `json:"areas"`
+}
+
+// AreaForecast は timeSeries 内の1エリア分の予報
+// timeSeries によって埋まるフィールドが異なる
+type AreaForecast struct {
+	Area         Area     `json:"area"`
+	WeatherCodes []string `json:"weatherCodes,omitempty"`
+	Weathers     []string `json:"weathers,omitempty"`
+	Winds        []string `json:"winds,omitempty"`
+	Waves        []string `json:"waves,omitempty"`
+	Pops         []string `json:"pops,omitempty"`
+	Temps        []string `json:"temps,omitempty"`
+
+	// 週間予報のみ
+	Reliabilities []string `json:"reliabilities,omitempty"`
+	TempsMin      []string `json:"tempsMin,omitempty"`
+	TempsMinUpper []string `json:"tempsMinUpper,omitempty"`
+	TempsMinLower []string `json:"tempsMinLower,omitempty"`
+	TempsMax      []string `json:"tempsMax,omitempty"`
+	TempsMaxUpper []string `json:"tempsMaxUpper,omitempty"`
+	TempsMaxLower []string `json:"tempsMaxLower,omitempty"`
+}
+
+type Area struct {
+	Name string `json:"name"`
+	Code string `json:"code"`
+}
+
+// ParseForecast は JMA の予報 JSON をパースする
+func ParseForecast(body []byte) (*Forecast, error) {
+	var reports []Report
+	if err := json.Unmarshal(body, &reports); err != nil {
+		return nil, fmt.Errorf("failed to parse forecast JSON: %w", err)
+	}
+	return &Forecast{Reports: reports, Raw: body}, nil
+}
+
+// Short は3日間の短期予報を返す。無ければ nil
+func (f *Forecast) Short() *Report {
+	if f == nil || len(f.Reports) == 0 {
+		return nil
+	}
+	return &f.Reports[0]
+}
+
+// Weekly は週間予報を返す。無ければ nil
+func (f *Forecast) Weekly() *Report {
+	if f == nil || len(f.Reports) < 2 {
+		return nil
+	}
+	return &f.Reports[1]
+}
+
+// ReportDatetime は短期予報の発表時刻を返す
+func (f *Forecast) ReportDatetime() time.Time {
+	if r := f.Short(); r != nil {
+		return r.ReportDatetime
+	}
+	return time.Time{}
+}
+
+// WeatherCodes は短期予報から class10 エリアの対象日の天気コードを返す
+func (f *Forecast) WeatherCodes(class10ID string, date time.Time) []string {
+	var codes []string
+	for _, b := range f.WeatherCodeBlocks(class10ID, date) {
+		codes = append(codes, b.Code)
+	}
+	return codes
+}
+
+// CodeBlock は天気コードとその対象期間
+// 日単位の予報なので、期間は timeDefine からその日の終わりまで
+type CodeBlock struct {
+	Start time.Time
+	End   time.Time
+	Code  string
+}
+
+// WeatherCodeBlocks は短期予報から class10 エリアの対象日の天気コードを期間付きで返す
+func (f *Forecast) WeatherCodeBlocks(class10ID string, date time.Time) []CodeBlock {
+	r := f.Short()
+	if r == nil {
+		return nil
+	}
+
+	var blocks []CodeBlock
+	for _, ts := range r.TimeSeries {
+		area := ts.findArea(class10ID)
+		if area == nil || len(area.WeatherCodes) == 0 {
+			continue
+		}
+		for i, td := range ts.TimeDefines {
+			if i >= len(area.WeatherCodes) || !sameDate(td, date) {
+				continue
+			}
+			blocks = append(blocks, CodeBlock{Start: td, End: endOfDay(td), Code: area.WeatherCodes[i]})
+		}
+	}
+	return blocks
+}
+
+// Pop は降水確率の1ブロック（通常6時間）
+type Pop struct {
+	Start time.Time
+	End   time.Time
+	Value int
+}
+
+// defaultPopBlock は次の timeDefine が無い最後のブロックの長さ
+const defaultPopBlock = 6 * time.Hour
+
+// Pops は短期予報から class10 エリアの対象日の降水確率ブロックを返す
+// 発表済みで過ぎたブロックは空文字になっているため除外する
+func (f *Forecast) Pops(class10ID string, date time.Time) []Pop {
+	r := f.Short()
+	if r == nil {
+		return nil
+	}
+
+	var pops []Pop
+	for _, ts := range r.TimeSeries {
+		area := ts.findArea(class10ID)
+		if area == nil || len(area.Pops) == 0 {
+			continue
+		}
+		for i, td := range ts.TimeDefines {
+			if i >= len(area.Pops) || !sameDate(td, date) {
+				continue
+			}
+			value, err := strconv.Atoi(area.Pops[i])
+			if err != nil {
+				continue
+			}
+			end := td.Add(defaultPopBlock)
+			if i+1 < len(ts.TimeDefines) {
+				end = ts.TimeDefines[i+1]
+			}
+			pops = append(pops, Pop{Start: td, End: end, Value: value})
+		}
+	}
+	return pops
+}
+
+// WeeklyDay は週間予報の1日分。値が発表されていない項目は nil
+type WeeklyDay struct {
+	Date        time.Time
+	WeatherCode string
+	Pop         *int
+	Reliability string
+	TempMin     *int
+	TempMax     *int
+}
+
+// WeeklyForecast は週間予報から指定エリアの日ごとの予報を返す
+// 週間予報のエリアは class10 と一致しない府県もあるため、areaCodes を順に探し、
+// どれも無ければ先頭（府県の代表エリア）を使う。気温は同じ並び順の観測地点の値
+func (f *Forecast) WeeklyForecast(areaCodes ...string) (Area, []WeeklyDay) {
+	r := f.Weekly()
+	if r == nil || len(r.TimeSeries) == 0 || len(r.TimeSeries[0].Areas) == 0 {
+		return Area{}, nil
+	}
+
+	weather := r.TimeSeries[0]
+	index := 0
+	for _, code := range areaCodes {
+		if i := weather.areaIndex(code); i >= 0 {
+			index = i
+			break
+		}
+	}
+	area := weather.Areas[index]
+
+	var temps *AreaForecast
+	if len(r.TimeSeries) > 1 && len(r.TimeSeries[1].Areas) > 0 {
+		tempSeries := r.TimeSeries[1]
+		if index < len(tempSeries.Areas) {
+			temps = &tempSeries.Areas[index]
+		} else {
+			temps = &tempSeries.Areas[0]
+		}
+	}
+
+	days := make([]WeeklyDay, 0, len(weather.TimeDefines))
+	for i, td := range weather.TimeDefines {
+		day := WeeklyDay{
+			Date:        td,
+			WeatherCode: valueAt(area.WeatherCodes, i),
+			Pop:         intAt(area.Pops, i),
+			Reliability: valueAt(area.Reliabilities, i),
+		}
+		if temps != nil {
+			day.TempMin = intAt(temps.TempsMin, i)
+			day.TempMax = intAt(temps.TempsMax, i)
+		}
+		days = append(days, day)
+	}
+	return area.Area, days
+}
+
+func valueAt(values []string, i int) string {
+	if i < len(values) {
+		return values[i]
+	}
+	return ""
+}
+
+func intAt(values []string, i int) *int {
+	v, err := strconv.Atoi(valueAt(values, i))
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+func (ts *TimeSeries) areaIndex(code string) int {
+	for i := range ts.Areas {
+		if ts.Areas[i].Area.Code == code {
+			return i
+		}
+	}
+	return -1
+}
+
+func (ts *TimeSeries) findArea(code string) *AreaForecast {
+	if i := ts.areaIndex(code); i >= 0 {
+		return &ts.Areas[i]
+	}
+	return nil
+}
+
+// endOfDay は t の翌日0時を返す
+func endOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+}
+
+// sameDate は t を date のタイムゾーンに揃えて日付を比較する
+func sameDate(t, date time.Time) bool {
+	t = t.In(date.Location())
+	y1, m1, d1 := t.Date()
+	y2, m2, d2 := date.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
+}
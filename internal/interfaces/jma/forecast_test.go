@@ -0,0 +1,143 @@
+package jma_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+	"github.com/Isshinfunada/weather-bot/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestForecast(t *testing.T) *jma.Forecast {
+	body, err := os.ReadFile("testdata/forecast_130000.json")
+	require.NoError(t, err)
+
+	forecast, err := jma.ParseForecast(body)
+	require.NoError(t, err)
+	return forecast
+}
+
+func TestParseForecast(t *testing.T) {
+	forecast := loadTestForecast(t)
+
+	require.Len(t, forecast.Reports, 2)
+	assert.NotEmpty(t, forecast.Raw)
+	assert.Equal(t, "気象庁", forecast.Short().PublishingOffice)
+	assert.True(t, forecast.ReportDatetime().Equal(time.Date(2024, 6, 10, 11, 0, 0, 0, utils.JST)))
+}
+
+func TestParseForecast_InvalidJSON(t *testing.T) {
+	forecast, err := jma.ParseForecast([]byte(`{"not": "an array"}`))
+	assert.Error(t, err)
+	assert.Nil(t, forecast)
+}
+
+func TestWeatherCodes(t *testing.T) {
+	forecast := loadTestForecast(t)
+
+	// 当日・翌日それぞれの日付に対応するコードだけが返る
+	today := time.Date(2024, 6, 10, 8, 0, 0, 0, utils.JST)
+	assert.Equal(t, []string{"101"}, forecast.WeatherCodes("130010", today))
+
+	tomorrow := today.AddDate(0, 0, 1)
+	assert.Equal(t, []string{"313"}, forecast.WeatherCodes("130010", tomorrow))
+	assert.Equal(t, []string{"300"}, forecast.WeatherCodes("130020", tomorrow))
+}
+
+func TestWeatherCodes_NoMatch(t *testing.T) {
+	forecast := loadTestForecast(t)
+
+	// 存在しないエリアや範囲外の日付では空
+	assert.Empty(t, forecast.WeatherCodes("999999", time.Date(2024, 6, 10, 0, 0, 0, 0, utils.JST)))
+	assert.Empty(t, forecast.WeatherCodes("130010", time.Date(2024, 6, 20, 0, 0, 0, 0, utils.JST)))
+
+	var empty *jma.Forecast
+	assert.Empty(t, empty.WeatherCodes("130010", time.Now()))
+}
+
+func TestWeatherCodeBlocks(t *testing.T) {
+	forecast := loadTestForecast(t)
+
+	// 11時発表の当日分は発表時刻から日付が変わるまで
+	blocks := forecast.WeatherCodeBlocks("130010", time.Date(2024, 6, 10, 0, 0, 0, 0, utils.JST))
+	require.Len(t, blocks, 1)
+	assert.Equal(t, "101", blocks[0].Code)
+	assert.True(t, blocks[0].Start.Equal(time.Date(2024, 6, 10, 11, 0, 0, 0, utils.JST)))
+	assert.True(t, blocks[0].End.Equal(time.Date(2024, 6, 11, 0, 0, 0, 0, utils.JST)))
+}
+
+func TestPops(t *testing.T) {
+	forecast := loadTestForecast(t)
+
+	today := time.Date(2024, 6, 10, 8, 0, 0, 0, utils.JST)
+	pops := forecast.Pops("130010", today)
+	require.Len(t, pops, 2)
+	assert.Equal(t, 10, pops[0].Value)
+	assert.True(t, pops[0].Start.Equal(time.Date(2024, 6, 10, 12, 0, 0, 0, utils.JST)))
+	assert.True(t, pops[0].End.Equal(time.Date(2024, 6, 10, 18, 0, 0, 0, utils.JST)))
+	assert.Equal(t, 30, pops[1].Value)
+
+	// 最後のブロックは6時間として扱う
+	tomorrow := today.AddDate(0, 0, 1)
+	pops = forecast.Pops("130010", tomorrow)
+	require.Len(t, pops, 4)
+	assert.Equal(t, []int{60, 70, 40, 20}, []int{pops[0].Value, pops[1].Value, pops[2].Value, pops[3].Value})
+	assert.True(t, pops[3].End.Equal(time.Date(2024, 6, 12, 0, 0, 0, 0, utils.JST)))
+}
+
+func TestPops_SkipsPastBlocks(t *testing.T) {
+	forecast, err := jma.ParseForecast([]byte(`[{
+		"timeSeries": [{
+			"timeDefines": ["2024-06-10T00:00:00+09:00", "2024-06-10T06:00:00+09:00"],
+			"areas": [{"area": {"code": "130010"}, "pops": ["", "40"]}]
+		}]
+	}]`))
+	require.NoError(t, err)
+
+	pops := forecast.Pops("130010", time.Date(2024, 6, 10, 0, 0, 0, 0, utils.JST))
+	require.Len(t, pops, 1)
+	assert.Equal(t, 40, pops[0].Value)
+}
+
+func TestWeeklyForecast(t *testing.T) {
+	forecast := loadTestForecast(t)
+
+	area, days := forecast.WeeklyForecast("130010", "130000")
+	assert.Equal(t, "130010", area.Code)
+	require.Len(t, days, 7)
+
+	// 初日は降水確率・気温が未発表
+	assert.Equal(t, "313", days[0].WeatherCode)
+	assert.Nil(t, days[0].Pop)
+	assert.Nil(t, days[0].TempMax)
+
+	assert.True(t, days[4].Date.Equal(time.Date(2024, 6, 15, 0, 0, 0, 0, utils.JST)))
+	assert.Equal(t, "300", days[4].WeatherCode)
+	require.NotNil(t, days[4].Pop)
+	assert.Equal(t, 70, *days[4].Pop)
+	assert.Equal(t, "C", days[4].Reliability)
+	require.NotNil(t, days[4].TempMin)
+	assert.Equal(t, 21, *days[4].TempMin)
+	assert.Equal(t, 24, *days[4].TempMax)
+}
+
+func TestWeeklyForecast_FallbackToFirstArea(t *testing.T) {
+	forecast := loadTestForecast(t)
+
+	// 週間予報に無いエリア（伊豆諸島など）は府県の代表エリアを使う
+	area, days := forecast.WeeklyForecast("130020", "130000")
+	assert.Equal(t, "130010", area.Code)
+	assert.Len(t, days, 7)
+}
+
+func TestWeeklyForecast_NoWeekly(t *testing.T) {
+	forecast, err := jma.ParseForecast([]byte(`[{"timeSeries": []}]`))
+	require.NoError(t, err)
+
+	area, days := forecast.WeeklyForecast("130010")
+	assert.Empty(t, area.Code)
+	assert.Nil(t, days)
+}
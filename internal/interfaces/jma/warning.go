@@ -0,0 +1,120 @@
+package jma
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// WarningReport は warning/data/warning/{office}.json を表す
+// areaTypes の1要素目が class10、2要素目が class20（市区町村）単位の警報・注意報
+type WarningReport struct {
+	PublishingOffice string            `json:"publishingOffice"`
+	ReportDatetime   time.Time         `json:"reportDatetime"`
+	HeadlineText     string            `json:"headlineText"`
+	AreaTypes        []WarningAreaType `json:"areaTypes"`
+	// 取得した JSON そのもの
+	Raw []byte `json:"-"`
+}
+
+type WarningAreaType struct {
+	Areas []WarningArea `json:"areas"`
+}
+
+type WarningArea struct {
+	Code     string    `json:"code"`
+	Warnings []Warning `json:"warnings"`
+}
+
+// Warning は1エリアに対する1種類の警報・注意報
+type Warning struct {
+	Code   string `json:"code"`
+	Status string `json:"status"`
+}
+
+// 警報・注意報の状態
+const (
+	WarningStatusIssued    = "発表"
+	WarningStatusContinued = "継続"
+	WarningStatusCleared   = "解除"
+	WarningStatusNone      = "発表警報・注意報はなし"
+)
+
+// class20 単位の areaTypes の位置
+const class20AreaType = 1
+
+// warningNames は警報・注意報コードと名称の対応
+var warningNames = map[string]string{
+	"02": "暴風雪警報",
+	"03": "大雨警報",
+	"04": "洪水警報",
+	"05": "暴風警報",
+	"06": "大雪警報",
+	"07": "波浪警報",
+	"08": "高潮警報",
+	"10": "大雨注意報",
+	"12": "大雪注意報",
+	"13": "風雪注意報",
+	"14": "雷注意報",
+	"15": "強風注意報",
+	"16": "波浪注意報",
+	"17": "融雪注意報",
+	"18": "洪水注意報",
+	"19": "高潮注意報",
+	"20": "濃霧注意報",
+	"21": "乾燥注意報",
+	"22": "なだれ注意報",
+	"23": "低温注意報",
+	"24": "霜注意報",
+	"25": "着氷注意報",
+	"26": "着雪注意報",
+	"32": "暴風雪特別警報",
+	"33": "大雨特別警報",
+	"35": "暴風特別警報",
+	"36": "大雪特別警報",
+	"37": "波浪特別警報",
+	"38": "高潮特別警報",
+}
+
+// WarningName は警報・注意報コードの名称を返す。未知のコードはコードそのもの
+func WarningName(code string) string {
+	if name, ok := warningNames[code]; ok {
+		return name
+	}
+	return code
+}
+
+// IsActive は警報・注意報が発表中（発表・継続など解除以外）か判定する
+func (w Warning) IsActive() bool {
+	return w.Code != "" && w.Status != WarningStatusCleared && w.Status != WarningStatusNone
+}
+
+// ParseWarning は JMA の警報・注意報 JSON をパースする
+func ParseWarning(body []byte) (*WarningReport, error) {
+	var report WarningReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse warning JSON: %w", err)
+	}
+	report.Raw = body
+	return &report, nil
+}
+
+// ActiveWarnings は class20 エリアに発表中の警報・注意報を返す
+func (r *WarningReport) ActiveWarnings(class20ID string) []Warning {
+	if r == nil || len(r.AreaTypes) <= class20AreaType {
+		return nil
+	}
+
+	var active []Warning
+	for _, area := range r.AreaTypes[class20AreaType].Areas {
+		if area.Code != class20ID {
+			continue
+		}
+		for _, w := range area.Warnings {
+			if w.IsActive() {
+				active = append(active, w)
+			}
+		}
+	}
+	return active
+}
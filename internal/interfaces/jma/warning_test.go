@@ -0,0 +1,73 @@
+package jma_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+	"github.com/Isshinfunada/weather-bot/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestWarning(t *testing.T) *jma.WarningReport {
+	body, err := os.ReadFile("testdata/warning_130000.json")
+	require.NoError(t, err)
+
+	report, err := jma.ParseWarning(body)
+	require.NoError(t, err)
+	return report
+}
+
+func TestParseWarning(t *testing.T) {
+	report := loadTestWarning(t)
+
+	assert.Equal(t, "気象庁", report.PublishingOffice)
+	assert.True(t, report.ReportDatetime.Equal(time.Date(2024, 6, 10, 16, 2, 0, 0, utils.JST)))
+	require.Len(t, report.AreaTypes, 2)
+	assert.NotEmpty(t, report.Raw)
+}
+
+func TestActiveWarnings(t *testing.T) {
+	report := loadTestWarning(t)
+
+	// 解除された強風注意報は含まない
+	active := report.ActiveWarnings("1310100")
+	require.Len(t, active, 2)
+	assert.Equal(t, "03", active[0].Code)
+	assert.Equal(t, "大雨警報", jma.WarningName(active[0].Code))
+	assert.Equal(t, "14", active[1].Code)
+
+	// 発表なし・存在しないエリアは空
+	assert.Empty(t, report.ActiveWarnings("1336100"))
+	assert.Empty(t, report.ActiveWarnings("9999999"))
+	// class10 のコードは対象外
+	assert.Empty(t, report.ActiveWarnings("130010"))
+}
+
+func TestWarningName_Unknown(t *testing.T) {
+	assert.Equal(t, "99", jma.WarningName("99"))
+}
+
+func TestFetchWarning(t *testing.T) {
+	body, err := os.ReadFile("testdata/warning_130000.json")
+	require.NoError(t, err)
+
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	client := jma.NewClient(jma.Config{BaseURL: srv.URL, HTTPClient: srv.Client()})
+
+	report, err := client.FetchWarning(context.Background(), "130000")
+	require.NoError(t, err)
+	assert.Equal(t, "/warning/data/warning/130000.json", gotPath)
+	assert.Len(t, report.ActiveWarnings("1310200"), 2)
+}
@@ -0,0 +1,26 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+)
+
+// Notifier はユーザーへメッセージを送る
+type Notifier interface {
+	Notify(ctx context.Context, user *entity.User, message string) error
+}
+
+type logNotifier struct{}
+
+// NewLogNotifier は標準出力にメッセージを出す Notifier を返す
+// LINE Messaging API と連携するまでの仮実装
+func NewLogNotifier() Notifier {
+	return &logNotifier{}
+}
+
+func (n *logNotifier) Notify(ctx context.Context, user *entity.User, message string) error {
+	fmt.Printf("User %d: %s\n", user.ID, message)
+	return nil
+}
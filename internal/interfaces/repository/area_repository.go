@@ -11,6 +11,7 @@ import (
 // インターフェース
 type AreaRepository interface {
 	FindHierarchyByClass20ID(ctx context.Context, class20ID string) (*entity.HierarchyArea, error)
+	FindActiveOfficeIDs(ctx context.Context) ([]string, error)
 }
 
 // 実装構造体
@@ -69,3 +70,34 @@ func (r *areaRepository) FindHierarchyByClass20ID(ctx context.Context, class20ID
 		Center:  &ct,
 	}, nil
 }
+
+// FindActiveOfficeIDs は有効なユーザーが選択しているエリアの area_offices の ID を重複なく返します
+func (r *areaRepository) FindActiveOfficeIDs(ctx context.Context) ([]string, error) {
+	query := `
+		SELECT DISTINCT c10.parent_id
+		FROM users u
+		JOIN area_class20 c20 ON u.selected_area_id = c20.id
+		JOIN area_class15 c15 ON c20.parent_id = c15.id
+		JOIN area_class10 c10 ON c15.parent_id = c10.id
+		WHERE u.is_active = TRUE
+		ORDER BY c10.parent_id
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query active offices: %w", err)
+	}
+	defer rows.Close()
+
+	var officeIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan office id: %w", err)
+		}
+		officeIDs = append(officeIDs, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return officeIDs, nil
+}
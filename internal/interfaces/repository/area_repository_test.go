@@ -139,3 +139,28 @@ func TestFindHierarchyByClass20ID_QueryError(t *testing.T) {
 
 	require.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestFindActiveOfficeIDs(t *testing.T) {
+	repo, mock, cleanup := setupAreaRepoTest(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT c10.parent_id`)).
+		WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow("130000").AddRow("270000"))
+
+	officeIDs, err := repo.FindActiveOfficeIDs(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"130000", "270000"}, officeIDs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindActiveOfficeIDs_QueryError(t *testing.T) {
+	repo, mock, cleanup := setupAreaRepoTest(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT c10.parent_id`)).
+		WillReturnError(errors.New("db error"))
+
+	officeIDs, err := repo.FindActiveOfficeIDs(context.Background())
+	assert.Error(t, err)
+	assert.Nil(t, officeIDs)
+}
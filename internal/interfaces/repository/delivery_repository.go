@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/utils"
+)
+
+type DeliveryRepository interface {
+	ClaimDelivery(ctx context.Context, userID int, targetDate time.Time, notificationType string, claimedAt, staleBefore time.Time) (bool, error)
+	ReleaseDelivery(ctx context.Context, userID int, targetDate time.Time, notificationType string) error
+}
+
+type deliveryRepository struct {
+	db *sql.DB
+}
+
+func NewDeliveryRepository(db *sql.DB) DeliveryRepository {
+	return &deliveryRepository{db: db}
+}
+
+// ClaimDelivery はユーザー・対象日・通知種別の組を claimedAt に処理中として記録します
+// 既に他の実行が記録していれば記録せず false を返します
+// ただし staleBefore より前の記録で、記録した後の通知の履歴が無ければ、処理中にプロセスが落ちたとみなして記録し直します
+func (r *deliveryRepository) ClaimDelivery(ctx context.Context, userID int, targetDate time.Time, notificationType string, claimedAt, staleBefore time.Time) (bool, error) {
+	query := `
+		INSERT INTO weather_deliveries (user_id, target_date, notification_type, created_at, claimed_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (user_id, target_date, notification_type)
+		DO UPDATE SET claimed_at = EXCLUDED.claimed_at
+		WHERE weather_deliveries.claimed_at < $5
+		AND NOT EXISTS (
+			SELECT 1 FROM notification_history h
+			WHERE h.user_id = weather_deliveries.user_id
+			AND h.notification_type = weather_deliveries.notification_type
+			AND h.notification_time >= weather_deliveries.claimed_at
+		)
+		RETURNING id
+	`
+
+	var id int
+	err := r.db.QueryRowContext(ctx, query,
+		userID,
+		targetDate.Format("2006-01-02"),
+		notificationType,
+		claimedAt.In(utils.JST),
+		staleBefore.In(utils.JST),
+	).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to claim delivery: %w", err)
+	}
+	return true, nil
+}
+
+// ReleaseDelivery は処理に失敗した組の記録を消し、後の実行で処理し直せるようにします
+func (r *deliveryRepository) ReleaseDelivery(ctx context.Context, userID int, targetDate time.Time, notificationType string) error {
+	query := `DELETE FROM weather_deliveries WHERE user_id = $1 AND target_date = $2 AND notification_type = $3`
+
+	if _, err := r.db.ExecContext(ctx, query, userID, targetDate.Format("2006-01-02"), notificationType); err != nil {
+		return fmt.Errorf("failed to release delivery: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/repository"
+	"github.com/Isshinfunada/weather-bot/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupDeliveryRepoTest(t *testing.T) (repository.DeliveryRepository, sqlmock.Sqlmock, func()) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	repo := repository.NewDeliveryRepository(db)
+	cleanup := func() { db.Close() }
+	return repo, mock, cleanup
+}
+
+func TestClaimDelivery(t *testing.T) {
+	targetDate := time.Date(2024, 6, 10, 0, 0, 0, 0, utils.JST)
+	claimedAt := time.Date(2024, 6, 10, 7, 0, 0, 0, utils.JST)
+	staleBefore := claimedAt.Add(-10 * time.Minute)
+	query := regexp.QuoteMeta(`ON CONFLICT (user_id, target_date, notification_type)`)
+
+	t.Run("claimed", func(t *testing.T) {
+		repo, mock, cleanup := setupDeliveryRepoTest(t)
+		defer cleanup()
+
+		mock.ExpectQuery(query).
+			WithArgs(10, "2024-06-10", "daily", claimedAt, staleBefore).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+		claimed, err := repo.ClaimDelivery(context.Background(), 10, targetDate, "daily", claimedAt, staleBefore)
+		require.NoError(t, err)
+		assert.True(t, claimed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("reclaims stale claim without history", func(t *testing.T) {
+		repo, mock, cleanup := setupDeliveryRepoTest(t)
+		defer cleanup()
+
+		// 古い記録を上書きしたときも id が返る
+		mock.ExpectQuery(regexp.QuoteMeta(`DO UPDATE SET claimed_at = EXCLUDED.claimed_at`)).
+			WithArgs(10, "2024-06-10", "daily", claimedAt, staleBefore).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+		claimed, err := repo.ClaimDelivery(context.Background(), 10, targetDate, "daily", claimedAt, staleBefore)
+		require.NoError(t, err)
+		assert.True(t, claimed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("already claimed", func(t *testing.T) {
+		repo, mock, cleanup := setupDeliveryRepoTest(t)
+		defer cleanup()
+
+		mock.ExpectQuery(query).
+			WithArgs(10, "2024-06-10", "daily", claimedAt, staleBefore).
+			WillReturnError(sql.ErrNoRows)
+
+		claimed, err := repo.ClaimDelivery(context.Background(), 10, targetDate, "daily", claimedAt, staleBefore)
+		require.NoError(t, err)
+		assert.False(t, claimed)
+	})
+
+	t.Run("db error", func(t *testing.T) {
+		repo, mock, cleanup := setupDeliveryRepoTest(t)
+		defer cleanup()
+
+		mock.ExpectQuery(query).WillReturnError(errors.New("db error"))
+
+		_, err := repo.ClaimDelivery(context.Background(), 10, targetDate, "daily", claimedAt, staleBefore)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to claim delivery")
+	})
+}
+
+func TestReleaseDelivery(t *testing.T) {
+	repo, mock, cleanup := setupDeliveryRepoTest(t)
+	defer cleanup()
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM weather_deliveries WHERE user_id = $1 AND target_date = $2 AND notification_type = $3`)).
+		WithArgs(10, "2024-06-10", "daily").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.ReleaseDelivery(context.Background(), 10, time.Date(2024, 6, 10, 0, 0, 0, 0, utils.JST), "daily")
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,8 @@
+package repository
+
+import "time"
+
+// NewCachedWeatherRuleRepositoryWithClock は now を現在時刻として使う NewCachedWeatherRuleRepository
+func NewCachedWeatherRuleRepositoryWithClock(repo WeatherRuleRepository, ttl time.Duration, now func() time.Time) CachedWeatherRuleRepository {
+	return newCachedWeatherRuleRepository(repo, ttl, now)
+}
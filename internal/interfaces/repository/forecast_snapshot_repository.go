@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+)
+
+type ForecastSnapshotRepository interface {
+	SaveSnapshot(ctx context.Context, snapshot *entity.ForecastSnapshot) error
+	FindSnapshotByID(ctx context.Context, id int) (*entity.ForecastSnapshot, error)
+}
+
+type forecastSnapshotRepository struct {
+	db *sql.DB
+}
+
+func NewForecastSnapshotRepository(db *sql.DB) ForecastSnapshotRepository {
+	return &forecastSnapshotRepository{db: db}
+}
+
+// SaveSnapshot はオフィスと発表時刻の組み合わせで1行だけ保存し、IDをセットします
+// 同じ発表のスナップショットが既にあればその行のIDを返します
+func (r *forecastSnapshotRepository) SaveSnapshot(ctx context.Context, snapshot *entity.ForecastSnapshot) error {
+	query := `
+		INSERT INTO forecast_snapshots (office_id, report_datetime, data)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (office_id, report_datetime)
+		DO UPDATE SET office_id = EXCLUDED.office_id
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		snapshot.OfficeID,
+		snapshot.ReportDatetime,
+		snapshot.Data,
+	).Scan(&snapshot.ID, &snapshot.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save forecast snapshot: %w", err)
+	}
+	return nil
+}
+
+func (r *forecastSnapshotRepository) FindSnapshotByID(ctx context.Context, id int) (*entity.ForecastSnapshot, error) {
+	query := `
+		SELECT id, office_id, report_datetime, data, created_at
+		FROM forecast_snapshots
+		WHERE id = $1
+	`
+
+	var s entity.ForecastSnapshot
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&s.ID, &s.OfficeID, &s.ReportDatetime, &s.Data, &s.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get forecast snapshot: %w", err)
+	}
+	return &s, nil
+}
@@ -0,0 +1,105 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/repository"
+	"github.com/Isshinfunada/weather-bot/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupForecastSnapshotRepoTest(t *testing.T) (repository.ForecastSnapshotRepository, sqlmock.Sqlmock, func()) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	repo := repository.NewForecastSnapshotRepository(db)
+	cleanup := func() { db.Close() }
+	return repo, mock, cleanup
+}
+
+func TestSaveSnapshot_Success(t *testing.T) {
+	repo, mock, cleanup := setupForecastSnapshotRepoTest(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	reportDatetime := time.Date(2024, 6, 10, 11, 0, 0, 0, utils.JST)
+	snapshot := &entity.ForecastSnapshot{
+		OfficeID:       "130000",
+		ReportDatetime: reportDatetime,
+		Data:           []byte(`[]`),
+	}
+
+	query := regexp.QuoteMeta(`
+		INSERT INTO forecast_snapshots (office_id, report_datetime, data)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (office_id, report_datetime)
+		DO UPDATE SET office_id = EXCLUDED.office_id
+		RETURNING id, created_at
+	`)
+	mock.ExpectQuery(query).
+		WithArgs("130000", reportDatetime, snapshot.Data).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(5, time.Now()))
+
+	err := repo.SaveSnapshot(ctx, snapshot)
+	require.NoError(t, err)
+	assert.Equal(t, 5, snapshot.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveSnapshot_Failure(t *testing.T) {
+	repo, mock, cleanup := setupForecastSnapshotRepoTest(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO forecast_snapshots`)).
+		WillReturnError(errors.New("insert failed"))
+
+	err := repo.SaveSnapshot(context.Background(), &entity.ForecastSnapshot{OfficeID: "130000"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to save forecast snapshot")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindSnapshotByID_Success(t *testing.T) {
+	repo, mock, cleanup := setupForecastSnapshotRepoTest(t)
+	defer cleanup()
+
+	reportDatetime := time.Date(2024, 6, 10, 11, 0, 0, 0, utils.JST)
+	query := regexp.QuoteMeta(`
+		SELECT id, office_id, report_datetime, data, created_at
+		FROM forecast_snapshots
+		WHERE id = $1
+	`)
+	mock.ExpectQuery(query).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "office_id", "report_datetime", "data", "created_at"}).
+			AddRow(5, "130000", reportDatetime, []byte(`[]`), time.Now()))
+
+	snapshot, err := repo.FindSnapshotByID(context.Background(), 5)
+	require.NoError(t, err)
+	require.NotNil(t, snapshot)
+	assert.Equal(t, "130000", snapshot.OfficeID)
+	assert.Equal(t, reportDatetime, snapshot.ReportDatetime)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindSnapshotByID_NotFound(t *testing.T) {
+	repo, mock, cleanup := setupForecastSnapshotRepoTest(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM forecast_snapshots`)).
+		WithArgs(99).
+		WillReturnError(sql.ErrNoRows)
+
+	snapshot, err := repo.FindSnapshotByID(context.Background(), 99)
+	assert.NoError(t, err)
+	assert.Nil(t, snapshot)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,7 +3,9 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/Isshinfunada/weather-bot/internal/entity"
@@ -13,6 +15,11 @@ import (
 
 type NotificationRepository interface {
 	InsertNotificationHistory(ctx context.Context, history *entity.NotificationHistory) error
+	FindNotificationHistoriesBySnapshotID(ctx context.Context, snapshotID int) ([]*entity.NotificationHistory, error)
+	FindLatestEvaluation(ctx context.Context, userID int, targetDate time.Time) (*entity.NotificationHistory, error)
+	FindEvaluationsInRange(ctx context.Context, from, to time.Time) ([]*entity.NotificationHistory, error)
+	ClaimPendingDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*entity.NotificationHistory, error)
+	UpdateDelivery(ctx context.Context, history *entity.NotificationHistory) error
 }
 
 type notificationRepository struct {
@@ -27,22 +34,40 @@ func (r *notificationRepository) InsertNotificationHistory(ctx context.Context,
 	query := `
         INSERT INTO notification_history (
             user_id, notification_time, is_notify_trigger, weather_data,
-            weather_codes, created_at
+            weather_codes, created_at, forecast_snapshot_id, matched_pops,
+            target_date, notification_type, warning_codes, message,
+            delivery_status, delivery_attempts, last_error, next_attempt_at, delivered_at
         )
-        VALUES ($1, $2, $3, $4, $5, $6)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
         RETURNING id
     `
 
+	matchedPops, err := marshalNullableJSON(history.MatchedPops)
+	if err != nil {
+		return fmt.Errorf("failed to marshal matched pops: %w", err)
+	}
+
 	now := time.Now().In(utils.JST)
 	history.CreatedAt = now
 
-	err := r.db.QueryRowContext(ctx, query,
+	err = r.db.QueryRowContext(ctx, query,
 		history.UserID,
 		history.NotificationTime,
 		history.IsNotifyTrigger,
-		history.WeatherData,
+		nullableJSON(history.WeatherData),
 		pq.Array(history.WeatherCodes),
 		history.CreatedAt,
+		history.ForecastSnapshotID,
+		matchedPops,
+		nullableDate(history.TargetDate),
+		notificationTypeOrDefault(history.NotificationType),
+		nullableArray(history.WarningCodes),
+		nullableString(history.Message),
+		deliveryStatusOrDefault(history.DeliveryStatus),
+		history.DeliveryAttempts,
+		nullableString(history.LastError),
+		history.NextAttemptAt,
+		history.DeliveredAt,
 	).Scan(&history.ID)
 
 	if err != nil {
@@ -50,3 +75,227 @@ func (r *notificationRepository) InsertNotificationHistory(ctx context.Context,
 	}
 	return nil
 }
+
+// FindNotificationHistoriesBySnapshotID は指定したスナップショットを基に判定された履歴を返します
+func (r *notificationRepository) FindNotificationHistoriesBySnapshotID(ctx context.Context, snapshotID int) ([]*entity.NotificationHistory, error) {
+	query := `
+		SELECT id, user_id, notification_type, notification_time, is_notify_trigger, weather_codes,
+			weather_data, forecast_snapshot_id, matched_pops, target_date, created_at
+		FROM notification_history
+		WHERE forecast_snapshot_id = $1
+		ORDER BY id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, snapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notification history by snapshot: %w", err)
+	}
+	defer rows.Close()
+
+	var histories []*entity.NotificationHistory
+	for rows.Next() {
+		var h entity.NotificationHistory
+		var isNotifyTrigger sql.NullBool
+		var matchedPops []byte
+		var targetDate sql.NullTime
+		if err := rows.Scan(
+			&h.ID, &h.UserID, &h.NotificationType, &h.NotificationTime, &isNotifyTrigger, pq.Array(&h.WeatherCodes),
+			&h.WeatherData, &h.ForecastSnapshotID, &matchedPops, &targetDate, &h.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan notification history: %w", err)
+		}
+		h.IsNotifyTrigger = isNotifyTrigger.Bool
+		h.TargetDate = targetDate.Time
+		if len(matchedPops) > 0 {
+			if err := json.Unmarshal(matchedPops, &h.MatchedPops); err != nil {
+				return nil, fmt.Errorf("failed to parse matched pops: %w", err)
+			}
+		}
+		histories = append(histories, &h)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return histories, nil
+}
+
+// FindLatestEvaluation はユーザーの対象日についての直近の天気の判定（daily / recheck）を返します。無ければ nil
+// 対象日の違う判定（前日の夕方の翌日分など）とは比べない
+func (r *notificationRepository) FindLatestEvaluation(ctx context.Context, userID int, targetDate time.Time) (*entity.NotificationHistory, error) {
+	query := `
+		SELECT id, user_id, notification_type, notification_time, is_notify_trigger, weather_codes,
+			target_date, created_at
+		FROM notification_history
+		WHERE user_id = $1 AND target_date = $2 AND notification_type IN ('daily', 'recheck')
+		ORDER BY notification_time DESC, id DESC
+		LIMIT 1
+	`
+
+	var h entity.NotificationHistory
+	var isNotifyTrigger sql.NullBool
+	var scannedTargetDate sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, userID, targetDate.Format("2006-01-02")).Scan(
+		&h.ID, &h.UserID, &h.NotificationType, &h.NotificationTime, &isNotifyTrigger, pq.Array(&h.WeatherCodes),
+		&scannedTargetDate, &h.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get latest evaluation: %w", err)
+	}
+	h.IsNotifyTrigger = isNotifyTrigger.Bool
+	h.TargetDate = scannedTargetDate.Time
+	return &h, nil
+}
+
+// FindEvaluationsInRange は通知時刻が [from, to) の天気の判定（daily / recheck）を古い順に返します
+func (r *notificationRepository) FindEvaluationsInRange(ctx context.Context, from, to time.Time) ([]*entity.NotificationHistory, error) {
+	query := `
+		SELECT id, user_id, notification_type, notification_time, is_notify_trigger, weather_codes,
+			weather_data, forecast_snapshot_id, target_date, created_at
+		FROM notification_history
+		WHERE notification_type IN ('daily', 'recheck')
+			AND notification_time >= $1 AND notification_time < $2
+		ORDER BY notification_time, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notification history in range: %w", err)
+	}
+	defer rows.Close()
+
+	var histories []*entity.NotificationHistory
+	for rows.Next() {
+		var h entity.NotificationHistory
+		var isNotifyTrigger sql.NullBool
+		var targetDate sql.NullTime
+		if err := rows.Scan(
+			&h.ID, &h.UserID, &h.NotificationType, &h.NotificationTime, &isNotifyTrigger, pq.Array(&h.WeatherCodes),
+			&h.WeatherData, &h.ForecastSnapshotID, &targetDate, &h.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan notification history: %w", err)
+		}
+		h.IsNotifyTrigger = isNotifyTrigger.Bool
+		h.TargetDate = targetDate.Time
+		histories = append(histories, &h)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return histories, nil
+}
+
+// ClaimPendingDeliveries は送信時刻を過ぎた pending の通知を古い順に limit 件まで返します
+// 返した行は next_attempt_at を leaseUntil に進め、その間は他の dispatcher が取らないようにします
+func (r *notificationRepository) ClaimPendingDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*entity.NotificationHistory, error) {
+	query := `
+		UPDATE notification_history
+		SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM notification_history
+			WHERE delivery_status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at, id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, notification_type, notification_time, message, delivery_status, delivery_attempts
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, now, leaseUntil, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim pending deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var histories []*entity.NotificationHistory
+	for rows.Next() {
+		var h entity.NotificationHistory
+		var message sql.NullString
+		if err := rows.Scan(
+			&h.ID, &h.UserID, &h.NotificationType, &h.NotificationTime, &message, &h.DeliveryStatus, &h.DeliveryAttempts,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan pending delivery: %w", err)
+		}
+		h.Message = message.String
+		h.NextAttemptAt = &leaseUntil
+		histories = append(histories, &h)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	// UPDATE ... RETURNING は順序を保証しないので並べ直す
+	sort.Slice(histories, func(i, j int) bool { return histories[i].ID < histories[j].ID })
+	return histories, nil
+}
+
+// UpdateDelivery は通知の送信状態を書き込みます
+func (r *notificationRepository) UpdateDelivery(ctx context.Context, history *entity.NotificationHistory) error {
+	query := `
+		UPDATE notification_history
+		SET delivery_status = $2, delivery_attempts = $3, last_error = $4, next_attempt_at = $5, delivered_at = $6
+		WHERE id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		history.ID,
+		deliveryStatusOrDefault(history.DeliveryStatus),
+		history.DeliveryAttempts,
+		nullableString(history.LastError),
+		history.NextAttemptAt,
+		history.DeliveredAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update delivery of notification history %d: %w", history.ID, err)
+	}
+	return nil
+}
+
+// marshalNullableJSON は空のスライスを NULL、それ以外を JSON として書き込むための変換
+func marshalNullableJSON[T any](values []T) (interface{}, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(values)
+}
+
+// nullableDate はゼロ値の日付を NULL、それ以外を "2006-01-02" として書き込むための変換
+func nullableDate(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Format("2006-01-02")
+}
+
+// notificationTypeOrDefault は未設定の通知種別を日次通知として扱う
+func notificationTypeOrDefault(notificationType string) string {
+	if notificationType == "" {
+		return entity.NotificationTypeDaily
+	}
+	return notificationType
+}
+
+// deliveryStatusOrDefault は未設定の送信状態を送る必要が無いものとして扱う
+func deliveryStatusOrDefault(status string) string {
+	if status == "" {
+		return entity.DeliveryStatusNone
+	}
+	return status
+}
+
+// nullableArray は空の配列を NULL として書き込むための変換
+func nullableArray(values []string) interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	return pq.Array(values)
+}
+
+// nullableJSON は空の JSON を NULL として書き込むための変換
+func nullableJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return data
+}
@@ -2,6 +2,8 @@ package repository_test
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"regexp"
 	"testing"
@@ -41,10 +43,12 @@ func TestInsertNotificationHistory_Success(t *testing.T) {
 
 	query := regexp.QuoteMeta(`
         INSERT INTO notification_history (
-            user_id, notification_time, is_notify_trigger, weather_data, 
-            weather_codes, created_at
+            user_id, notification_time, is_notify_trigger, weather_data,
+            weather_codes, created_at, forecast_snapshot_id, matched_pops,
+            target_date, notification_type, warning_codes, message,
+            delivery_status, delivery_attempts, last_error, next_attempt_at, delivered_at
         )
-        VALUES ($1, $2, $3, $4, $5, $6)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
         RETURNING id
     `)
 
@@ -56,6 +60,17 @@ func TestInsertNotificationHistory_Success(t *testing.T) {
 			history.WeatherData,
 			sqlmock.AnyArg(),
 			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
+			nil,
+			nil,
+			"daily",
+			nil,
+			nil,
+			"none",
+			0,
+			nil,
+			nil,
+			nil,
 		).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
 
@@ -82,10 +97,12 @@ func TestInsertNotificationHistory_Failure(t *testing.T) {
 
 	query := regexp.QuoteMeta(`
         INSERT INTO notification_history (
-            user_id, notification_time, is_notify_trigger, weather_data, 
-            weather_codes, created_at
+            user_id, notification_time, is_notify_trigger, weather_data,
+            weather_codes, created_at, forecast_snapshot_id, matched_pops,
+            target_date, notification_type, warning_codes, message,
+            delivery_status, delivery_attempts, last_error, next_attempt_at, delivered_at
         )
-        VALUES ($1, $2, $3, $4, $5, $6)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
         RETURNING id
     `)
 
@@ -97,6 +114,17 @@ func TestInsertNotificationHistory_Failure(t *testing.T) {
 			history.WeatherData,
 			sqlmock.AnyArg(), // pq.Array(history.WeatherCodes) の結果として
 			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
+			nil,
+			nil,
+			"daily",
+			nil,
+			nil,
+			"none",
+			0,
+			nil,
+			nil,
+			nil,
 		).
 		WillReturnError(errors.New("insert failed"))
 
@@ -105,3 +133,290 @@ func TestInsertNotificationHistory_Failure(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to insert notification history")
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestInsertNotificationHistory_WithSnapshot(t *testing.T) {
+	repo, mock, cleanup := setupNotificationRepoTest(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	snapshotID := 7
+	popStart := time.Date(2024, 6, 10, 12, 0, 0, 0, utils.JST)
+	history := &entity.NotificationHistory{
+		UserID:             1,
+		NotificationTime:   time.Now().In(utils.JST),
+		IsNotifyTrigger:    true,
+		WeatherCodes:       []string{"100"},
+		MatchedPops:        []entity.PopBlock{{Start: popStart, End: popStart.Add(6 * time.Hour), Pop: 60}},
+		ForecastSnapshotID: &snapshotID,
+		TargetDate:         time.Date(2024, 6, 11, 0, 0, 0, 0, utils.JST),
+	}
+	matchedPops, err := json.Marshal(history.MatchedPops)
+	require.NoError(t, err)
+
+	// weather_data は NULL、スナップショットIDを参照する
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO notification_history`)).
+		WithArgs(
+			history.UserID,
+			history.NotificationTime,
+			history.IsNotifyTrigger,
+			nil,
+			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
+			snapshotID,
+			matchedPops,
+			"2024-06-11",
+			"daily",
+			nil,
+			nil,
+			"none",
+			0,
+			nil,
+			nil,
+			nil,
+		).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(43))
+
+	err = repo.InsertNotificationHistory(ctx, history)
+	require.NoError(t, err)
+	assert.Equal(t, 43, history.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInsertNotificationHistory_Pending(t *testing.T) {
+	repo, mock, cleanup := setupNotificationRepoTest(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().In(utils.JST)
+	nextAttemptAt := now.Add(5 * time.Minute)
+	history := &entity.NotificationHistory{
+		UserID:           1,
+		NotificationTime: now,
+		IsNotifyTrigger:  true,
+		WeatherCodes:     []string{"300"},
+		Message:          "雨の予報です",
+		DeliveryStatus:   entity.DeliveryStatusPending,
+		NextAttemptAt:    &nextAttemptAt,
+	}
+
+	// 判定と送る文面を一緒に書き込む
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO notification_history`)).
+		WithArgs(
+			history.UserID,
+			history.NotificationTime,
+			history.IsNotifyTrigger,
+			nil,
+			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
+			nil,
+			nil,
+			nil,
+			"daily",
+			nil,
+			"雨の予報です",
+			"pending",
+			0,
+			nil,
+			&nextAttemptAt,
+			nil,
+		).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(44))
+
+	err := repo.InsertNotificationHistory(ctx, history)
+	require.NoError(t, err)
+	assert.Equal(t, 44, history.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindNotificationHistoriesBySnapshotID_Success(t *testing.T) {
+	repo, mock, cleanup := setupNotificationRepoTest(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().In(utils.JST)
+
+	query := regexp.QuoteMeta(`
+		SELECT id, user_id, notification_type, notification_time, is_notify_trigger, weather_codes,
+			weather_data, forecast_snapshot_id, matched_pops, target_date, created_at
+		FROM notification_history
+		WHERE forecast_snapshot_id = $1
+		ORDER BY id
+	`)
+	rows := sqlmock.NewRows([]string{
+		"id", "user_id", "notification_type", "notification_time", "is_notify_trigger", "weather_codes",
+		"weather_data", "forecast_snapshot_id", "matched_pops", "target_date", "created_at",
+	}).
+		AddRow(1, 10, "daily", now, true, "{300,313}", nil, 7, []byte(`[{"start":"2024-06-10T12:00:00+09:00","end":"2024-06-10T18:00:00+09:00","pop":70}]`), now, now).
+		AddRow(2, 11, "weekly", now, false, "{100}", nil, 7, nil, nil, now)
+	mock.ExpectQuery(query).WithArgs(7).WillReturnRows(rows)
+
+	histories, err := repo.FindNotificationHistoriesBySnapshotID(ctx, 7)
+	require.NoError(t, err)
+	require.Len(t, histories, 2)
+	assert.Equal(t, 10, histories[0].UserID)
+	assert.True(t, histories[0].IsNotifyTrigger)
+	assert.Equal(t, []string{"300", "313"}, histories[0].WeatherCodes)
+	require.Len(t, histories[0].MatchedPops, 1)
+	assert.Equal(t, 70, histories[0].MatchedPops[0].Pop)
+	assert.Equal(t, entity.NotificationTypeWeekly, histories[1].NotificationType)
+	assert.Empty(t, histories[1].MatchedPops)
+	require.NotNil(t, histories[1].ForecastSnapshotID)
+	assert.Equal(t, 7, *histories[1].ForecastSnapshotID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindNotificationHistoriesBySnapshotID_QueryError(t *testing.T) {
+	repo, mock, cleanup := setupNotificationRepoTest(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM notification_history`)).
+		WithArgs(7).
+		WillReturnError(errors.New("db error"))
+
+	histories, err := repo.FindNotificationHistoriesBySnapshotID(context.Background(), 7)
+	assert.Error(t, err)
+	assert.Nil(t, histories)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindLatestEvaluation(t *testing.T) {
+	query := regexp.QuoteMeta(`
+		SELECT id, user_id, notification_type, notification_time, is_notify_trigger, weather_codes,
+			target_date, created_at
+		FROM notification_history
+		WHERE user_id = $1 AND target_date = $2 AND notification_type IN ('daily', 'recheck')
+		ORDER BY notification_time DESC, id DESC
+		LIMIT 1
+	`)
+
+	targetDate := time.Date(2024, 6, 10, 0, 0, 0, 0, utils.JST)
+
+	t.Run("found", func(t *testing.T) {
+		repo, mock, cleanup := setupNotificationRepoTest(t)
+		defer cleanup()
+
+		now := time.Now().In(utils.JST)
+		rows := sqlmock.NewRows([]string{
+			"id", "user_id", "notification_type", "notification_time", "is_notify_trigger", "weather_codes", "target_date", "created_at",
+		}).AddRow(5, 10, "recheck", now, true, "{300}", targetDate, now)
+		mock.ExpectQuery(query).WithArgs(10, "2024-06-10").WillReturnRows(rows)
+
+		h, err := repo.FindLatestEvaluation(context.Background(), 10, targetDate)
+		require.NoError(t, err)
+		require.NotNil(t, h)
+		assert.Equal(t, entity.NotificationTypeRecheck, h.NotificationType)
+		assert.True(t, h.IsNotifyTrigger)
+		assert.Equal(t, []string{"300"}, h.WeatherCodes)
+		assert.Equal(t, targetDate, h.TargetDate)
+	})
+
+	t.Run("no history", func(t *testing.T) {
+		repo, mock, cleanup := setupNotificationRepoTest(t)
+		defer cleanup()
+
+		mock.ExpectQuery(query).WithArgs(10, "2024-06-10").WillReturnError(sql.ErrNoRows)
+
+		h, err := repo.FindLatestEvaluation(context.Background(), 10, targetDate)
+		require.NoError(t, err)
+		assert.Nil(t, h)
+	})
+}
+
+func TestFindEvaluationsInRange(t *testing.T) {
+	repo, mock, cleanup := setupNotificationRepoTest(t)
+	defer cleanup()
+
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, utils.JST)
+	to := time.Date(2024, 7, 1, 0, 0, 0, 0, utils.JST)
+	now := time.Now().In(utils.JST)
+
+	query := regexp.QuoteMeta(`
+		SELECT id, user_id, notification_type, notification_time, is_notify_trigger, weather_codes,
+			weather_data, forecast_snapshot_id, target_date, created_at
+		FROM notification_history
+		WHERE notification_type IN ('daily', 'recheck')
+			AND notification_time >= $1 AND notification_time < $2
+		ORDER BY notification_time, id
+	`)
+	rows := sqlmock.NewRows([]string{
+		"id", "user_id", "notification_type", "notification_time", "is_notify_trigger", "weather_codes",
+		"weather_data", "forecast_snapshot_id", "target_date", "created_at",
+	}).
+		AddRow(1, 10, "daily", now, false, "{100}", []byte(`[]`), nil, nil, now).
+		AddRow(2, 10, "recheck", now, true, "{300}", nil, 7, now, now)
+	mock.ExpectQuery(query).WithArgs(from, to).WillReturnRows(rows)
+
+	histories, err := repo.FindEvaluationsInRange(context.Background(), from, to)
+	require.NoError(t, err)
+	require.Len(t, histories, 2)
+	assert.Equal(t, []byte(`[]`), histories[0].WeatherData)
+	assert.Nil(t, histories[0].ForecastSnapshotID)
+	assert.True(t, histories[0].TargetDate.IsZero())
+	assert.True(t, histories[1].IsNotifyTrigger)
+	require.NotNil(t, histories[1].ForecastSnapshotID)
+	assert.Equal(t, 7, *histories[1].ForecastSnapshotID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimPendingDeliveries(t *testing.T) {
+	repo, mock, cleanup := setupNotificationRepoTest(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Date(2024, 6, 10, 7, 0, 0, 0, utils.JST)
+	leaseUntil := now.Add(5 * time.Minute)
+
+	rows := sqlmock.NewRows([]string{
+		"id", "user_id", "notification_type", "notification_time", "message", "delivery_status", "delivery_attempts",
+	}).
+		AddRow(12, 2, "weekly", now.Add(-time.Hour), "週間予報", "pending", 2).
+		AddRow(11, 1, "daily", now.Add(-2*time.Hour), "雨の予報です", "pending", 1)
+	mock.ExpectQuery(`UPDATE notification_history\s+SET next_attempt_at = \$2\s+WHERE id IN \(.*FOR UPDATE SKIP LOCKED`).
+		WithArgs(now, leaseUntil, 100).
+		WillReturnRows(rows)
+
+	histories, err := repo.ClaimPendingDeliveries(ctx, now, leaseUntil, 100)
+	require.NoError(t, err)
+	require.Len(t, histories, 2)
+	assert.Equal(t, 11, histories[0].ID)
+	assert.Equal(t, "雨の予報です", histories[0].Message)
+	assert.Equal(t, 1, histories[0].DeliveryAttempts)
+	assert.Equal(t, leaseUntil, *histories[0].NextAttemptAt)
+	assert.Equal(t, 12, histories[1].ID)
+	assert.Equal(t, "weekly", histories[1].NotificationType)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateDelivery(t *testing.T) {
+	repo, mock, cleanup := setupNotificationRepoTest(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	deliveredAt := time.Date(2024, 6, 10, 7, 0, 0, 0, utils.JST)
+	history := &entity.NotificationHistory{
+		ID:               11,
+		DeliveryStatus:   entity.DeliveryStatusSent,
+		DeliveryAttempts: 2,
+		DeliveredAt:      &deliveredAt,
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE notification_history`)).
+		WithArgs(11, "sent", 2, nil, nil, &deliveredAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.UpdateDelivery(ctx, history)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateDelivery_Error(t *testing.T) {
+	repo, mock, cleanup := setupNotificationRepoTest(t)
+	defer cleanup()
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE notification_history`)).
+		WillReturnError(errors.New("db error"))
+
+	err := repo.UpdateDelivery(context.Background(), &entity.NotificationHistory{ID: 11})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "notification history 11")
+}
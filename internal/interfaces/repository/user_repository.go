@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -15,6 +16,8 @@ type UserRepository interface {
 	FindUserByID(ctx context.Context, userID int) (*entity.User, error)
 	FindUserByLINEUserID(ctx context.Context, LINEUserID string) (*entity.User, error)
 	FindUserByNotifyTimeRange(ctx context.Context, start, end time.Time) ([]*entity.User, error)
+	FindActiveUsersByOfficeID(ctx context.Context, officeID string) ([]*entity.User, error)
+	FindActiveUsersByNotifyMode(ctx context.Context, notifyMode string) ([]*entity.User, error)
 	UpdateUser(ctx context.Context, user *entity.User) error
 	DeleteUser(ctx context.Context, userID int) error
 }
@@ -27,15 +30,86 @@ func NewUserRepository(db *sql.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
+// userColumns は SELECT するカラム。並びは scanUser と合わせる
+const userColumns = `
+	id, line_user_id, selected_area_id, notify_time,
+	is_active, created_at, updated_at, pop_threshold,
+	time_windows, target_day, weekly_notify, notify_mode,
+	notify_all_clear
+`
+
+// rowScanner は *sql.Row と *sql.Rows の共通部分
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*entity.User, error) {
+	var u entity.User
+	var timeWindows []byte
+	err := row.Scan(
+		&u.ID,
+		&u.LINEUserID,
+		&u.SelectedAreaID,
+		&u.NotifyTime,
+		&u.IsActive,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+		&u.PopThreshold,
+		&timeWindows,
+		&u.TargetDay,
+		&u.WeeklyNotify,
+		&u.NotifyMode,
+		&u.NotifyAllClear,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(timeWindows) > 0 {
+		if err := json.Unmarshal(timeWindows, &u.TimeWindows); err != nil {
+			return nil, fmt.Errorf("failed to parse time windows: %w", err)
+		}
+	}
+	return &u, nil
+}
+
+// marshalTimeWindows は time_windows カラムに書き込む JSON を返す。未設定は空配列
+func marshalTimeWindows(windows []entity.TimeWindow) ([]byte, error) {
+	if len(windows) == 0 {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(windows)
+}
+
+// targetDayOrDefault は未設定の target_day を当日として扱う
+func targetDayOrDefault(targetDay string) string {
+	if targetDay == "" {
+		return entity.TargetDayToday
+	}
+	return targetDay
+}
+
+// notifyModeOrDefault は未設定の notify_mode を毎回通知として扱う
+func notifyModeOrDefault(notifyMode string) string {
+	if notifyMode == "" {
+		return entity.NotifyModeAlways
+	}
+	return notifyMode
+}
+
 // CreateUserはusersテーブルに新規レコードを挿入し、
 // 作成したレコードのID　を取得して戻り値として返します
 func (r *userRepository) CreateUser(ctx context.Context, user *entity.User) (*entity.User, error) {
 	query := `
-	INSERT INTO users (line_user_id, selected_area_id, notify_time, is_active, created_at, updated_at)
-	VALUES ($1, $2, $3, $4, $5, $6)
+	INSERT INTO users (line_user_id, selected_area_id, notify_time, is_active, created_at, updated_at, pop_threshold, time_windows, target_day, weekly_notify, notify_mode, notify_all_clear)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 	RETURNING id
 	`
 
+	timeWindows, err := marshalTimeWindows(user.TimeWindows)
+	if err != nil {
+		return nil, fmt.Errorf("faild to insert user: %w", err)
+	}
+
 	now := time.Now().In(utils.JST)
 	if user.CreatedAt.IsZero() {
 		user.CreatedAt = now
@@ -43,7 +117,7 @@ func (r *userRepository) CreateUser(ctx context.Context, user *entity.User) (*en
 	user.UpdatedAt = now
 
 	var newID int
-	err := r.db.QueryRowContext(
+	err = r.db.QueryRowContext(
 		ctx, query,
 		user.LINEUserID,
 		user.SelectedAreaID,
@@ -51,6 +125,12 @@ func (r *userRepository) CreateUser(ctx context.Context, user *entity.User) (*en
 		user.IsActive,
 		user.CreatedAt,
 		user.UpdatedAt,
+		user.PopThreshold,
+		timeWindows,
+		targetDayOrDefault(user.TargetDay),
+		user.WeeklyNotify,
+		notifyModeOrDefault(user.NotifyMode),
+		user.NotifyAllClear,
 	).Scan(&newID)
 	if err != nil {
 		return nil, fmt.Errorf("faild to insert user: %w", err)
@@ -63,25 +143,14 @@ func (r *userRepository) CreateUser(ctx context.Context, user *entity.User) (*en
 // FindUserByIDはusersテーブルを検索し、見つかったらUserを返します
 func (r *userRepository) FindUserByID(ctx context.Context, userID int) (*entity.User, error) {
 	query := `
-		SELECT
-            id, line_user_id, selected_area_id, notify_time,
-            is_active, created_at, updated_at
-        FROM users
-        WHERE id = $1
-        LIMIT 1
+		SELECT` + userColumns + `
+		FROM users
+		WHERE id = $1
+		LIMIT 1
 	`
 	row := r.db.QueryRowContext(ctx, query, userID)
 
-	var u entity.User
-	err := row.Scan(
-		&u.ID,
-		&u.LINEUserID,
-		&u.SelectedAreaID,
-		&u.NotifyTime,
-		&u.IsActive,
-		&u.CreatedAt,
-		&u.UpdatedAt,
-	)
+	u, err := scanUser(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -89,14 +158,12 @@ func (r *userRepository) FindUserByID(ctx context.Context, userID int) (*entity.
 		return nil, fmt.Errorf("failed to get user by id: %w", err)
 	}
 
-	return &u, nil
+	return u, nil
 }
 
 func (r *userRepository) FindUserByLINEUserID(ctx context.Context, LINEUserID string) (*entity.User, error) {
 	query := `
-		SELECT
-			id, line_user_id, selected_area_id, notify_time,
-			is_active, created_at, updated_at
+		SELECT` + userColumns + `
 		FROM users
 		WHERE line_user_id = $1
 		LIMIT 1
@@ -104,29 +171,20 @@ func (r *userRepository) FindUserByLINEUserID(ctx context.Context, LINEUserID st
 
 	row := r.db.QueryRowContext(ctx, query, LINEUserID)
 
-	var u entity.User
-	err := row.Scan(
-		&u.ID,
-		&u.LINEUserID,
-		&u.SelectedAreaID,
-		&u.NotifyTime,
-		&u.IsActive,
-		&u.CreatedAt,
-		&u.UpdatedAt,
-	)
+	u, err := scanUser(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to get user by LINEUserID: %w", err)
 	}
-	return &u, nil
+	return u, nil
 
 }
 
 func (r *userRepository) FindUserByNotifyTimeRange(ctx context.Context, start, end time.Time) ([]*entity.User, error) {
 	query := `
-		SELECT id, line_user_id, selected_area_id, notify_time, is_active, created_at, updated_at
+		SELECT` + userColumns + `
 		FROM users
 		WHERE notify_time >= $1 AND notify_time < $2
 	`
@@ -139,11 +197,76 @@ func (r *userRepository) FindUserByNotifyTimeRange(ctx context.Context, start, e
 
 	var users []*entity.User
 	for rows.Next() {
-		var u entity.User
-		if err := rows.Scan(&u.ID, &u.LINEUserID, &u.SelectedAreaID, &u.NotifyTime, &u.IsActive, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		u, err := scanUser(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan user: %w", err)
 		}
-		users = append(users, &u)
+		users = append(users, u)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return users, nil
+}
+
+// FindActiveUsersByOfficeID は選択エリアが area_offices の配下にある有効なユーザーを返します
+func (r *userRepository) FindActiveUsersByOfficeID(ctx context.Context, officeID string) ([]*entity.User, error) {
+	query := `
+		SELECT` + userColumns + `
+		FROM users
+		WHERE is_active = TRUE
+		AND selected_area_id IN (
+			SELECT c20.id
+			FROM area_class20 c20
+			JOIN area_class15 c15 ON c20.parent_id = c15.id
+			JOIN area_class10 c10 ON c15.parent_id = c10.id
+			WHERE c10.parent_id = $1
+		)
+		ORDER BY id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, officeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users by office: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*entity.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, u)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return users, nil
+}
+
+// FindActiveUsersByNotifyMode は指定した通知のしかたの有効なユーザーを返します
+func (r *userRepository) FindActiveUsersByNotifyMode(ctx context.Context, notifyMode string) ([]*entity.User, error) {
+	query := `
+		SELECT` + userColumns + `
+		FROM users
+		WHERE is_active = TRUE AND notify_mode = $1
+		ORDER BY id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, notifyMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users by notify mode: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*entity.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, u)
 	}
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("rows error: %w", err)
@@ -158,10 +281,21 @@ func (r *userRepository) UpdateUser(ctx context.Context, user *entity.User) erro
 			selected_area_id = $1,
 			notify_time = $2,
 			is_active = $3,
-			updated_at = $4
-		WHERE id = $5
+			updated_at = $4,
+			pop_threshold = $5,
+			time_windows = $6,
+			target_day = $7,
+			weekly_notify = $8,
+			notify_mode = $9,
+			notify_all_clear = $10
+		WHERE id = $11
 	`
 
+	timeWindows, err := marshalTimeWindows(user.TimeWindows)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
 	user.UpdatedAt = time.Now().In(utils.JST)
 
 	result, err := r.db.ExecContext(
@@ -171,6 +305,12 @@ func (r *userRepository) UpdateUser(ctx context.Context, user *entity.User) erro
 		user.NotifyTime,
 		user.IsActive,
 		user.UpdatedAt,
+		user.PopThreshold,
+		timeWindows,
+		targetDayOrDefault(user.TargetDay),
+		user.WeeklyNotify,
+		notifyModeOrDefault(user.NotifyMode),
+		user.NotifyAllClear,
 		user.ID,
 	)
 	if err != nil {
@@ -39,11 +39,11 @@ func TestCreateUser_Succeess(t *testing.T) {
 	}
 
 	mock.ExpectQuery(regexp.QuoteMeta(`
-	    INSERT INTO users (line_user_id, selected_area_id, notify_time, is_active, created_at, updated_at)
-	    VALUES ($1, $2, $3, $4, $5, $6)
+	    INSERT INTO users (line_user_id, selected_area_id, notify_time, is_active, created_at, updated_at, pop_threshold, time_windows, target_day, weekly_notify, notify_mode, notify_all_clear)
+	    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 	    RETURNING id
 	`)).
-		WithArgs(user.LINEUserID, user.SelectedAreaID, user.NotifyTime, user.IsActive, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(user.LINEUserID, user.SelectedAreaID, user.NotifyTime, user.IsActive, sqlmock.AnyArg(), sqlmock.AnyArg(), user.PopThreshold, []byte(`[]`), "today", false, "always", false).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
 	created, err := repo.CreateUser(ctx, user)
@@ -65,11 +65,11 @@ func TestCreateUser_QueryError(t *testing.T) {
 	}
 
 	mock.ExpectQuery(regexp.QuoteMeta(`
-		INSERT INTO users (line_user_id, selected_area_id, notify_time, is_active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO users (line_user_id, selected_area_id, notify_time, is_active, created_at, updated_at, pop_threshold, time_windows, target_day, weekly_notify, notify_mode, notify_all_clear)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 		RETURNING id
 	`)).
-		WithArgs(user.LINEUserID, user.SelectedAreaID, user.NotifyTime, user.IsActive, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(user.LINEUserID, user.SelectedAreaID, user.NotifyTime, user.IsActive, sqlmock.AnyArg(), sqlmock.AnyArg(), user.PopThreshold, []byte(`[]`), "today", false, "always", false).
 		WillReturnError(errors.New("insert failed"))
 
 	_, err := repo.CreateUser(ctx, user)
@@ -85,14 +85,16 @@ func TestFindUserByID_Success(t *testing.T) {
 	query := `
 		SELECT
             id, line_user_id, selected_area_id, notify_time,
-            is_active, created_at, updated_at
+            is_active, created_at, updated_at, pop_threshold,
+            time_windows, target_day, weekly_notify, notify_mode,
+            notify_all_clear
         FROM users
         WHERE id = $1
         LIMIT 1
 	`
 	rows := sqlmock.NewRows([]string{
-		"id", "line_user_id", "selected_area_id", "notify_time", "is_active", "created_at", "updated_at",
-	}).AddRow(1, "U123", 0110000, time.Date(0, 1, 1, 9, 0, 0, 0, utils.JST), true, time.Now().In(utils.JST), time.Now().In(utils.JST))
+		"id", "line_user_id", "selected_area_id", "notify_time", "is_active", "created_at", "updated_at", "pop_threshold", "time_windows", "target_day", "weekly_notify", "notify_mode", "notify_all_clear",
+	}).AddRow(1, "U123", 0110000, time.Date(0, 1, 1, 9, 0, 0, 0, utils.JST), true, time.Now().In(utils.JST), time.Now().In(utils.JST), nil, []byte(`[]`), "today", false, "always", false)
 
 	mock.ExpectQuery(regexp.QuoteMeta(query)).
 		WithArgs(1).
@@ -112,7 +114,9 @@ func TestFindUserByID_NotFound(t *testing.T) {
 	query := `
 		SELECT
             id, line_user_id, selected_area_id, notify_time,
-            is_active, created_at, updated_at
+            is_active, created_at, updated_at, pop_threshold,
+            time_windows, target_day, weekly_notify, notify_mode,
+            notify_all_clear
         FROM users
         WHERE id = $1
         LIMIT 1
@@ -134,14 +138,16 @@ func TestFindUserByLINEUserID_Success(t *testing.T) {
 	query := `
 		SELECT
             id, line_user_id, selected_area_id, notify_time,
-            is_active, created_at, updated_at
+            is_active, created_at, updated_at, pop_threshold,
+            time_windows, target_day, weekly_notify, notify_mode,
+            notify_all_clear
         FROM users
         WHERE line_user_id = $1
         LIMIT 1
 	`
 	rows := sqlmock.NewRows([]string{
-		"id", "line_user_id", "selected_area_id", "notify_time", "is_active", "created_at", "updated_at",
-	}).AddRow(1, "U123", 0110000, time.Date(0, 1, 1, 9, 0, 0, 0, utils.JST), true, time.Now().In(utils.JST), time.Now().In(utils.JST))
+		"id", "line_user_id", "selected_area_id", "notify_time", "is_active", "created_at", "updated_at", "pop_threshold", "time_windows", "target_day", "weekly_notify", "notify_mode", "notify_all_clear",
+	}).AddRow(1, "U123", 0110000, time.Date(0, 1, 1, 9, 0, 0, 0, utils.JST), true, time.Now().In(utils.JST), time.Now().In(utils.JST), nil, []byte(`[]`), "today", false, "always", false)
 
 	mock.ExpectQuery(regexp.QuoteMeta(query)).
 		WithArgs("U123").
@@ -161,7 +167,9 @@ func TestFindUserByLINEUserID_NotFound(t *testing.T) {
 	query := `
 		SELECT
             id, line_user_id, selected_area_id, notify_time,
-            is_active, created_at, updated_at
+            is_active, created_at, updated_at, pop_threshold,
+            time_windows, target_day, weekly_notify, notify_mode,
+            notify_all_clear
         FROM users
         WHERE line_user_id = $1
         LIMIT 1
@@ -187,7 +195,9 @@ func TestFindUsersByNotifyTimeRange_Success(t *testing.T) {
 	query := `
 		SELECT
 			id, line_user_id, selected_area_id, notify_time,
-			is_active, created_at, updated_at
+			is_active, created_at, updated_at, pop_threshold,
+            time_windows, target_day, weekly_notify, notify_mode,
+            notify_all_clear
 		FROM users
 		WHERE notify_time >= $1 AND notify_time < $2
 	`
@@ -195,10 +205,10 @@ func TestFindUsersByNotifyTimeRange_Success(t *testing.T) {
 	// モックデータの設定
 	rows := sqlmock.NewRows([]string{
 		"id", "line_user_id", "selected_area_id", "notify_time",
-		"is_active", "created_at", "updated_at",
+		"is_active", "created_at", "updated_at", "pop_threshold", "time_windows", "target_day", "weekly_notify", "notify_mode", "notify_all_clear",
 	}).
-		AddRow(1, "U123", "0150000", time.Date(0, 1, 1, 8, 30, 0, 0, utils.JST), true, time.Now().In(utils.JST), time.Now().In(utils.JST)).
-		AddRow(2, "U456", "0150100", time.Date(0, 1, 1, 8, 45, 0, 0, utils.JST), true, time.Now().In(utils.JST), time.Now().In(utils.JST))
+		AddRow(1, "U123", "0150000", time.Date(0, 1, 1, 8, 30, 0, 0, utils.JST), true, time.Now().In(utils.JST), time.Now().In(utils.JST), nil, []byte(`[]`), "today", false, "always", false).
+		AddRow(2, "U456", "0150100", time.Date(0, 1, 1, 8, 45, 0, 0, utils.JST), true, time.Now().In(utils.JST), time.Now().In(utils.JST), 50, []byte(`[{"start":"07:00","end":"09:00"}]`), "auto", true, "on_change", true)
 
 	mock.ExpectQuery(regexp.QuoteMeta(query)).
 		WithArgs(startTime.Format("15:04"), endTime.Format("15:04")).
@@ -213,12 +223,20 @@ func TestFindUsersByNotifyTimeRange_Success(t *testing.T) {
 	assert.Equal(t, "0150000", users[0].SelectedAreaID)
 	assert.Equal(t, "08:30", users[0].NotifyTime.Format("15:04"))
 	assert.True(t, users[0].IsActive)
+	assert.Nil(t, users[0].PopThreshold)
 
 	assert.Equal(t, 2, users[1].ID)
 	assert.Equal(t, "U456", users[1].LINEUserID)
 	assert.Equal(t, "0150100", users[1].SelectedAreaID)
 	assert.Equal(t, "08:45", users[1].NotifyTime.Format("15:04"))
 	assert.True(t, users[1].IsActive)
+	require.NotNil(t, users[1].PopThreshold)
+	assert.Equal(t, 50, *users[1].PopThreshold)
+	assert.Equal(t, []entity.TimeWindow{{Start: "07:00", End: "09:00"}}, users[1].TimeWindows)
+	assert.Equal(t, entity.TargetDayAuto, users[1].TargetDay)
+	assert.True(t, users[1].WeeklyNotify)
+	assert.Equal(t, entity.NotifyModeOnChange, users[1].NotifyMode)
+	assert.True(t, users[1].NotifyAllClear)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -236,7 +254,9 @@ func TestFindUsersByNotifyTimeRange_NoUsers(t *testing.T) {
 	query := `
 		SELECT
 			id, line_user_id, selected_area_id, notify_time,
-			is_active, created_at, updated_at
+			is_active, created_at, updated_at, pop_threshold,
+            time_windows, target_day, weekly_notify, notify_mode,
+            notify_all_clear
 		FROM users
 		WHERE notify_time >= $1 AND notify_time < $2
 	`
@@ -244,7 +264,7 @@ func TestFindUsersByNotifyTimeRange_NoUsers(t *testing.T) {
 	// モックデータの設定（ユーザーなし）
 	rows := sqlmock.NewRows([]string{
 		"id", "line_user_id", "selected_area_id", "notify_time",
-		"is_active", "created_at", "updated_at",
+		"is_active", "created_at", "updated_at", "pop_threshold", "time_windows", "target_day", "weekly_notify", "notify_mode", "notify_all_clear",
 	})
 
 	mock.ExpectQuery(regexp.QuoteMeta(query)).
@@ -271,7 +291,9 @@ func TestFindUsersByNotifyTimeRange_QueryError(t *testing.T) {
 	query := `
 		SELECT
 			id, line_user_id, selected_area_id, notify_time,
-			is_active, created_at, updated_at
+			is_active, created_at, updated_at, pop_threshold,
+            time_windows, target_day, weekly_notify, notify_mode,
+            notify_all_clear
 		FROM users
 		WHERE notify_time >= $1 AND notify_time < $2
 	`
@@ -289,6 +311,62 @@ func TestFindUsersByNotifyTimeRange_QueryError(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestFindActiveUsersByOfficeID_Success(t *testing.T) {
+	repo, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+
+	rows := sqlmock.NewRows([]string{
+		"id", "line_user_id", "selected_area_id", "notify_time",
+		"is_active", "created_at", "updated_at", "pop_threshold", "time_windows", "target_day", "weekly_notify", "notify_mode", "notify_all_clear",
+	}).
+		AddRow(1, "U123", "1310100", time.Date(0, 1, 1, 7, 0, 0, 0, utils.JST), true, time.Now().In(utils.JST), time.Now().In(utils.JST), nil, []byte(`[]`), "today", false, "always", false)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE c10.parent_id = $1`)).
+		WithArgs("130000").
+		WillReturnRows(rows)
+
+	users, err := repo.FindActiveUsersByOfficeID(context.Background(), "130000")
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, "1310100", users[0].SelectedAreaID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindActiveUsersByOfficeID_QueryError(t *testing.T) {
+	repo, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM users`)).
+		WithArgs("130000").
+		WillReturnError(errors.New("db error"))
+
+	users, err := repo.FindActiveUsersByOfficeID(context.Background(), "130000")
+	assert.Error(t, err)
+	assert.Nil(t, users)
+}
+
+func TestFindActiveUsersByNotifyMode(t *testing.T) {
+	repo, mock, cleanup := setupMockDB(t)
+	defer cleanup()
+
+	rows := sqlmock.NewRows([]string{
+		"id", "line_user_id", "selected_area_id", "notify_time",
+		"is_active", "created_at", "updated_at", "pop_threshold", "time_windows", "target_day", "weekly_notify", "notify_mode", "notify_all_clear",
+	}).
+		AddRow(3, "U789", "1310100", time.Date(0, 1, 1, 7, 0, 0, 0, utils.JST), true, time.Now().In(utils.JST), time.Now().In(utils.JST), nil, []byte(`[]`), "today", false, "on_change", true)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE is_active = TRUE AND notify_mode = $1`)).
+		WithArgs("on_change").
+		WillReturnRows(rows)
+
+	users, err := repo.FindActiveUsersByNotifyMode(context.Background(), entity.NotifyModeOnChange)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, entity.NotifyModeOnChange, users[0].NotifyMode)
+	assert.True(t, users[0].NotifyAllClear)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestUpdateUser_Success(t *testing.T) {
 	repo, mock, cleanup := setupMockDB(t)
 	defer cleanup()
@@ -299,6 +377,7 @@ func TestUpdateUser_Success(t *testing.T) {
 		SelectedAreaID: "0120200",
 		NotifyTime:     time.Date(0, 1, 1, 10, 0, 0, 0, utils.JST),
 		IsActive:       false,
+		TimeWindows:    []entity.TimeWindow{{Start: "07:00", End: "09:00"}},
 	}
 
 	query := `
@@ -307,11 +386,17 @@ func TestUpdateUser_Success(t *testing.T) {
 			selected_area_id = $1,
 			notify_time = $2,
 			is_active = $3,
-			updated_at = $4
-		WHERE id = $5
+			updated_at = $4,
+			pop_threshold = $5,
+			time_windows = $6,
+			target_day = $7,
+			weekly_notify = $8,
+			notify_mode = $9,
+			notify_all_clear = $10
+		WHERE id = $11
 	`
 	mock.ExpectExec(regexp.QuoteMeta(query)).
-		WithArgs(user.SelectedAreaID, user.NotifyTime, user.IsActive, sqlmock.AnyArg(), user.ID).
+		WithArgs(user.SelectedAreaID, user.NotifyTime, user.IsActive, sqlmock.AnyArg(), user.PopThreshold, []byte(`[{"start":"07:00","end":"09:00"}]`), "today", false, "always", false, user.ID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	err := repo.UpdateUser(ctx, user)
@@ -336,11 +421,17 @@ func TestUpdateUser_NoRows(t *testing.T) {
 			selected_area_id = $1,
 			notify_time = $2,
 			is_active = $3,
-			updated_at = $4
-		WHERE id = $5
+			updated_at = $4,
+			pop_threshold = $5,
+			time_windows = $6,
+			target_day = $7,
+			weekly_notify = $8,
+			notify_mode = $9,
+			notify_all_clear = $10
+		WHERE id = $11
 	`
 	mock.ExpectExec(regexp.QuoteMeta(query)).
-		WithArgs(user.SelectedAreaID, user.NotifyTime, user.IsActive, sqlmock.AnyArg(), user.ID).
+		WithArgs(user.SelectedAreaID, user.NotifyTime, user.IsActive, sqlmock.AnyArg(), user.PopThreshold, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), user.ID).
 		WillReturnResult(sqlmock.NewResult(0, 0)) // no rows affected
 
 	err := repo.UpdateUser(ctx, user)
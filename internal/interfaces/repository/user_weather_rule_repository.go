@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/utils"
+)
+
+type UserWeatherRuleRepository interface {
+	FindUserWeatherRules(ctx context.Context, userID int) ([]*entity.UserWeatherRule, error)
+	SaveUserWeatherRule(ctx context.Context, rule *entity.UserWeatherRule) error
+	DeleteUserWeatherRules(ctx context.Context, userID int, weatherCode, category string) error
+}
+
+type userWeatherRuleRepository struct {
+	db *sql.DB
+}
+
+func NewUserWeatherRuleRepository(db *sql.DB) UserWeatherRuleRepository {
+	return &userWeatherRuleRepository{db: db}
+}
+
+// FindUserWeatherRules はユーザーの通知ルールの上書きを返します
+func (r *userWeatherRuleRepository) FindUserWeatherRules(ctx context.Context, userID int) ([]*entity.UserWeatherRule, error) {
+	query := `
+		SELECT id, user_id, weather_code, category, is_notify_trigger, created_at, updated_at
+		FROM user_weather_rules
+		WHERE user_id = $1
+		ORDER BY category NULLS LAST, weather_code
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user weather rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*entity.UserWeatherRule
+	for rows.Next() {
+		var rule entity.UserWeatherRule
+		var weatherCode, category sql.NullString
+		if err := rows.Scan(
+			&rule.ID, &rule.UserID, &weatherCode, &category, &rule.IsNotifyTrigger, &rule.CreatedAt, &rule.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan user weather rule: %w", err)
+		}
+		rule.WeatherCode = weatherCode.String
+		rule.Category = category.String
+		rules = append(rules, &rule)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return rules, nil
+}
+
+// SaveUserWeatherRule は天気コードかカテゴリの上書きを登録し、既にあれば更新します
+func (r *userWeatherRuleRepository) SaveUserWeatherRule(ctx context.Context, rule *entity.UserWeatherRule) error {
+	// 部分ユニークインデックスごとに衝突先を指定する
+	conflict := `(user_id, weather_code) WHERE weather_code IS NOT NULL`
+	if rule.Category != "" {
+		conflict = `(user_id, category) WHERE category IS NOT NULL`
+	}
+	query := `
+		INSERT INTO user_weather_rules (user_id, weather_code, category, is_notify_trigger, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT ` + conflict + `
+		DO UPDATE SET is_notify_trigger = EXCLUDED.is_notify_trigger, updated_at = EXCLUDED.updated_at
+		RETURNING id, created_at
+	`
+
+	now := time.Now().In(utils.JST)
+	err := r.db.QueryRowContext(ctx, query,
+		rule.UserID,
+		nullableString(rule.WeatherCode),
+		nullableString(rule.Category),
+		rule.IsNotifyTrigger,
+		now,
+		now,
+	).Scan(&rule.ID, &rule.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save user weather rule: %w", err)
+	}
+	rule.UpdatedAt = now
+	return nil
+}
+
+// DeleteUserWeatherRules はユーザーの上書きを削除します
+// weatherCode か category を指定するとその上書きだけ、どちらも空なら全て削除します
+func (r *userWeatherRuleRepository) DeleteUserWeatherRules(ctx context.Context, userID int, weatherCode, category string) error {
+	query := `DELETE FROM user_weather_rules WHERE user_id = $1`
+	args := []interface{}{userID}
+	switch {
+	case weatherCode != "":
+		query += ` AND weather_code = $2`
+		args = append(args, weatherCode)
+	case category != "":
+		query += ` AND category = $2`
+		args = append(args, category)
+	}
+
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete user weather rules: %w", err)
+	}
+	return nil
+}
+
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,128 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/repository"
+	"github.com/Isshinfunada/weather-bot/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupUserWeatherRuleRepoTest(t *testing.T) (repository.UserWeatherRuleRepository, sqlmock.Sqlmock, func()) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	repo := repository.NewUserWeatherRuleRepository(db)
+	cleanup := func() { db.Close() }
+	return repo, mock, cleanup
+}
+
+func TestFindUserWeatherRules_Success(t *testing.T) {
+	repo, mock, cleanup := setupUserWeatherRuleRepoTest(t)
+	defer cleanup()
+
+	now := time.Now().In(utils.JST)
+	query := regexp.QuoteMeta(`
+		SELECT id, user_id, weather_code, category, is_notify_trigger, created_at, updated_at
+		FROM user_weather_rules
+		WHERE user_id = $1
+		ORDER BY category NULLS LAST, weather_code
+	`)
+	rows := sqlmock.NewRows([]string{
+		"id", "user_id", "weather_code", "category", "is_notify_trigger", "created_at", "updated_at",
+	}).
+		AddRow(1, 10, nil, "snow", true, now, now).
+		AddRow(2, 10, "200", nil, true, now, now)
+	mock.ExpectQuery(query).WithArgs(10).WillReturnRows(rows)
+
+	rules, err := repo.FindUserWeatherRules(context.Background(), 10)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "", rules[0].WeatherCode)
+	assert.Equal(t, entity.WeatherCategorySnow, rules[0].Category)
+	assert.Equal(t, "200", rules[1].WeatherCode)
+	assert.Equal(t, "", rules[1].Category)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveUserWeatherRule(t *testing.T) {
+	t.Run("weather code", func(t *testing.T) {
+		repo, mock, cleanup := setupUserWeatherRuleRepoTest(t)
+		defer cleanup()
+
+		now := time.Now().In(utils.JST)
+		mock.ExpectQuery(regexp.QuoteMeta(`ON CONFLICT (user_id, weather_code) WHERE weather_code IS NOT NULL`)).
+			WithArgs(10, "200", nil, true, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, now))
+
+		rule := &entity.UserWeatherRule{UserID: 10, WeatherCode: "200", IsNotifyTrigger: true}
+		err := repo.SaveUserWeatherRule(context.Background(), rule)
+		require.NoError(t, err)
+		assert.Equal(t, 3, rule.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("category", func(t *testing.T) {
+		repo, mock, cleanup := setupUserWeatherRuleRepoTest(t)
+		defer cleanup()
+
+		now := time.Now().In(utils.JST)
+		mock.ExpectQuery(regexp.QuoteMeta(`ON CONFLICT (user_id, category) WHERE category IS NOT NULL`)).
+			WithArgs(10, nil, "rain", false, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(4, now))
+
+		rule := &entity.UserWeatherRule{UserID: 10, Category: entity.WeatherCategoryRain, IsNotifyTrigger: false}
+		err := repo.SaveUserWeatherRule(context.Background(), rule)
+		require.NoError(t, err)
+		assert.Equal(t, 4, rule.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("db error", func(t *testing.T) {
+		repo, mock, cleanup := setupUserWeatherRuleRepoTest(t)
+		defer cleanup()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO user_weather_rules`)).WillReturnError(errors.New("db error"))
+
+		err := repo.SaveUserWeatherRule(context.Background(), &entity.UserWeatherRule{UserID: 10, WeatherCode: "200"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to save user weather rule")
+	})
+}
+
+func TestDeleteUserWeatherRules(t *testing.T) {
+	tests := []struct {
+		name        string
+		weatherCode string
+		category    string
+		query       string
+		args        []driver.Value
+	}{
+		{name: "all", query: `DELETE FROM user_weather_rules WHERE user_id = $1`, args: []driver.Value{10}},
+		{name: "weather code", weatherCode: "200", query: `DELETE FROM user_weather_rules WHERE user_id = $1 AND weather_code = $2`, args: []driver.Value{10, "200"}},
+		{name: "category", category: "snow", query: `DELETE FROM user_weather_rules WHERE user_id = $1 AND category = $2`, args: []driver.Value{10, "snow"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, mock, cleanup := setupUserWeatherRuleRepoTest(t)
+			defer cleanup()
+
+			mock.ExpectExec("^" + regexp.QuoteMeta(tt.query) + "$").
+				WithArgs(tt.args...).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			err := repo.DeleteUserWeatherRules(context.Background(), 10, tt.weatherCode, tt.category)
+			require.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/utils"
+)
+
+type WarningRepository interface {
+	FindActiveUserWarnings(ctx context.Context, userID int) ([]*entity.UserWarning, error)
+	InsertUserWarning(ctx context.Context, warning *entity.UserWarning) (bool, error)
+	ClearUserWarning(ctx context.Context, id int, clearedAt time.Time) error
+	FindLastReportDatetime(ctx context.Context, officeID string) (*time.Time, error)
+	SaveLastReportDatetime(ctx context.Context, officeID string, reportDatetime time.Time) error
+}
+
+type warningRepository struct {
+	db *sql.DB
+}
+
+func NewWarningRepository(db *sql.DB) WarningRepository {
+	return &warningRepository{db: db}
+}
+
+// FindActiveUserWarnings はユーザーに通知済みで、まだ解除されていない警報・注意報を返します
+func (r *warningRepository) FindActiveUserWarnings(ctx context.Context, userID int) ([]*entity.UserWarning, error) {
+	query := `
+		SELECT id, user_id, area_code, warning_code, report_datetime, notified_at, cleared_at
+		FROM user_warnings
+		WHERE user_id = $1 AND cleared_at IS NULL
+		ORDER BY id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query active user warnings: %w", err)
+	}
+	defer rows.Close()
+
+	var warnings []*entity.UserWarning
+	for rows.Next() {
+		var w entity.UserWarning
+		if err := rows.Scan(
+			&w.ID, &w.UserID, &w.AreaCode, &w.WarningCode, &w.ReportDatetime, &w.NotifiedAt, &w.ClearedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan user warning: %w", err)
+		}
+		warnings = append(warnings, &w)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return warnings, nil
+}
+
+// InsertUserWarning は警報・注意報のエピソードを記録し、IDをセットします
+// 同じ警報・注意報が解除されずに残っている場合は記録せず false を返します
+func (r *warningRepository) InsertUserWarning(ctx context.Context, warning *entity.UserWarning) (bool, error) {
+	query := `
+		INSERT INTO user_warnings (user_id, area_code, warning_code, report_datetime, notified_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, warning_code) WHERE cleared_at IS NULL
+		DO NOTHING
+		RETURNING id
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		warning.UserID,
+		warning.AreaCode,
+		warning.WarningCode,
+		warning.ReportDatetime,
+		warning.NotifiedAt,
+	).Scan(&warning.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to insert user warning: %w", err)
+	}
+	return true, nil
+}
+
+// ClearUserWarning は警報・注意報のエピソードを解除済みにします
+func (r *warningRepository) ClearUserWarning(ctx context.Context, id int, clearedAt time.Time) error {
+	query := `UPDATE user_warnings SET cleared_at = $1 WHERE id = $2 AND cleared_at IS NULL`
+
+	if _, err := r.db.ExecContext(ctx, query, clearedAt, id); err != nil {
+		return fmt.Errorf("failed to clear user warning: %w", err)
+	}
+	return nil
+}
+
+// FindLastReportDatetime は poller が最後に処理したオフィスの発表時刻を返します。未処理なら nil
+func (r *warningRepository) FindLastReportDatetime(ctx context.Context, officeID string) (*time.Time, error) {
+	query := `SELECT report_datetime FROM warning_report_states WHERE office_id = $1`
+
+	var reportDatetime time.Time
+	err := r.db.QueryRowContext(ctx, query, officeID).Scan(&reportDatetime)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get last warning report: %w", err)
+	}
+	reportDatetime = jstWallClock(reportDatetime)
+	return &reportDatetime, nil
+}
+
+// SaveLastReportDatetime は poller が処理したオフィスの発表時刻を記録します
+func (r *warningRepository) SaveLastReportDatetime(ctx context.Context, officeID string, reportDatetime time.Time) error {
+	query := `
+		INSERT INTO warning_report_states (office_id, report_datetime, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (office_id)
+		DO UPDATE SET report_datetime = EXCLUDED.report_datetime, updated_at = EXCLUDED.updated_at
+	`
+
+	// TIMESTAMP には JST の時刻として書き込む
+	if _, err := r.db.ExecContext(ctx, query, officeID, reportDatetime.In(utils.JST), time.Now().In(utils.JST)); err != nil {
+		return fmt.Errorf("failed to save last warning report: %w", err)
+	}
+	return nil
+}
+
+// jstWallClock は TIMESTAMP 列から読んだ時刻を JST の時刻として読み直す
+// TIMESTAMP はオフセットを持たず JST の時刻を保存しているが、lib/pq は UTC として返すため
+func jstWallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), utils.JST)
+}
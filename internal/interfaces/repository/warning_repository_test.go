@@ -0,0 +1,162 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/repository"
+	"github.com/Isshinfunada/weather-bot/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupWarningRepoTest(t *testing.T) (repository.WarningRepository, sqlmock.Sqlmock, func()) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	repo := repository.NewWarningRepository(db)
+	cleanup := func() { db.Close() }
+	return repo, mock, cleanup
+}
+
+func TestFindActiveUserWarnings_Success(t *testing.T) {
+	repo, mock, cleanup := setupWarningRepoTest(t)
+	defer cleanup()
+
+	now := time.Now().In(utils.JST)
+	query := regexp.QuoteMeta(`
+		SELECT id, user_id, area_code, warning_code, report_datetime, notified_at, cleared_at
+		FROM user_warnings
+		WHERE user_id = $1 AND cleared_at IS NULL
+		ORDER BY id
+	`)
+	rows := sqlmock.NewRows([]string{
+		"id", "user_id", "area_code", "warning_code", "report_datetime", "notified_at", "cleared_at",
+	}).
+		AddRow(1, 10, "1310100", "03", now, now, nil).
+		AddRow(2, 10, "1310100", "14", now, now, nil)
+	mock.ExpectQuery(query).WithArgs(10).WillReturnRows(rows)
+
+	warnings, err := repo.FindActiveUserWarnings(context.Background(), 10)
+	require.NoError(t, err)
+	require.Len(t, warnings, 2)
+	assert.Equal(t, "03", warnings[0].WarningCode)
+	assert.Nil(t, warnings[0].ClearedAt)
+	assert.Equal(t, "14", warnings[1].WarningCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInsertUserWarning(t *testing.T) {
+	query := regexp.QuoteMeta(`
+		INSERT INTO user_warnings (user_id, area_code, warning_code, report_datetime, notified_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, warning_code) WHERE cleared_at IS NULL
+		DO NOTHING
+		RETURNING id
+	`)
+	now := time.Now().In(utils.JST)
+
+	t.Run("inserted", func(t *testing.T) {
+		repo, mock, cleanup := setupWarningRepoTest(t)
+		defer cleanup()
+
+		warning := &entity.UserWarning{UserID: 10, AreaCode: "1310100", WarningCode: "03", ReportDatetime: now, NotifiedAt: now}
+		mock.ExpectQuery(query).
+			WithArgs(10, "1310100", "03", now, now).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+
+		inserted, err := repo.InsertUserWarning(context.Background(), warning)
+		require.NoError(t, err)
+		assert.True(t, inserted)
+		assert.Equal(t, 5, warning.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("already notified", func(t *testing.T) {
+		repo, mock, cleanup := setupWarningRepoTest(t)
+		defer cleanup()
+
+		// 解除前の同じ警報があると ON CONFLICT DO NOTHING で行が返らない
+		mock.ExpectQuery(query).WillReturnError(sql.ErrNoRows)
+
+		inserted, err := repo.InsertUserWarning(context.Background(), &entity.UserWarning{UserID: 10, WarningCode: "03"})
+		require.NoError(t, err)
+		assert.False(t, inserted)
+	})
+
+	t.Run("db error", func(t *testing.T) {
+		repo, mock, cleanup := setupWarningRepoTest(t)
+		defer cleanup()
+
+		mock.ExpectQuery(query).WillReturnError(errors.New("db error"))
+
+		inserted, err := repo.InsertUserWarning(context.Background(), &entity.UserWarning{UserID: 10, WarningCode: "03"})
+		assert.Error(t, err)
+		assert.False(t, inserted)
+	})
+}
+
+func TestClearUserWarning(t *testing.T) {
+	repo, mock, cleanup := setupWarningRepoTest(t)
+	defer cleanup()
+
+	now := time.Now().In(utils.JST)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE user_warnings SET cleared_at = $1 WHERE id = $2 AND cleared_at IS NULL`)).
+		WithArgs(now, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.ClearUserWarning(context.Background(), 5, now)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindLastReportDatetime(t *testing.T) {
+	query := regexp.QuoteMeta(`SELECT report_datetime FROM warning_report_states WHERE office_id = $1`)
+
+	t.Run("found", func(t *testing.T) {
+		repo, mock, cleanup := setupWarningRepoTest(t)
+		defer cleanup()
+
+		reportDatetime := time.Date(2024, 6, 10, 16, 2, 0, 0, utils.JST)
+		// TIMESTAMP 列の JST の時刻を、Postgres と lib/pq は UTC として返す
+		mock.ExpectQuery(query).WithArgs("130000").
+			WillReturnRows(sqlmock.NewRows([]string{"report_datetime"}).AddRow(time.Date(2024, 6, 10, 16, 2, 0, 0, time.UTC)))
+
+		got, err := repo.FindLastReportDatetime(context.Background(), "130000")
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.True(t, got.Equal(reportDatetime))
+	})
+
+	t.Run("not polled yet", func(t *testing.T) {
+		repo, mock, cleanup := setupWarningRepoTest(t)
+		defer cleanup()
+
+		mock.ExpectQuery(query).WithArgs("130000").WillReturnError(sql.ErrNoRows)
+
+		got, err := repo.FindLastReportDatetime(context.Background(), "130000")
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+}
+
+func TestSaveLastReportDatetime(t *testing.T) {
+	repo, mock, cleanup := setupWarningRepoTest(t)
+	defer cleanup()
+
+	// JMA の発表時刻は +09:00 で返るが、JST に揃えて書き込む
+	reportDatetime := time.Date(2024, 6, 10, 7, 2, 0, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO warning_report_states`)).
+		WithArgs("130000", time.Date(2024, 6, 10, 16, 2, 0, 0, utils.JST), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.SaveLastReportDatetime(context.Background(), "130000", reportDatetime)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
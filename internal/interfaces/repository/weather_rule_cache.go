@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+)
+
+// unknownWeatherCodes はルールに無い天気コードを引いた回数（コードごと）
+// /debug/vars の weather_rule_unknown_codes で確認できる
+var unknownWeatherCodes = expvar.NewMap("weather_rule_unknown_codes")
+
+// CachedWeatherRuleRepository は全てのルールをメモリに持ち、引くときに SQL を発行しない
+// 登録・更新・削除は元のリポジトリに書き込んでから読み込み直すので、同じプロセスではすぐに反映される
+// 他のプロセスには ttl が過ぎてから反映される
+type CachedWeatherRuleRepository interface {
+	WeatherRuleRepository
+	// Refresh はルールを読み込み直す
+	Refresh(ctx context.Context) error
+}
+
+type cachedWeatherRuleRepository struct {
+	repo WeatherRuleRepository
+	ttl  time.Duration
+	now  func() time.Time
+
+	// refreshMu は読み込みを1つずつにする
+	refreshMu sync.Mutex
+	mu        sync.RWMutex
+	rules     map[string]*entity.WeatherRule
+	loadedAt  time.Time
+}
+
+// NewCachedWeatherRuleRepository は repo のルールをキャッシュする
+// ttl を過ぎると次に引いたときに読み込み直す。0 以下なら Refresh を呼ぶまで読み込み直さない
+func NewCachedWeatherRuleRepository(repo WeatherRuleRepository, ttl time.Duration) CachedWeatherRuleRepository {
+	return newCachedWeatherRuleRepository(repo, ttl, time.Now)
+}
+
+func newCachedWeatherRuleRepository(repo WeatherRuleRepository, ttl time.Duration, now func() time.Time) CachedWeatherRuleRepository {
+	return &cachedWeatherRuleRepository{repo: repo, ttl: ttl, now: now}
+}
+
+func (r *cachedWeatherRuleRepository) Refresh(ctx context.Context) error {
+	r.refreshMu.Lock()
+	defer r.refreshMu.Unlock()
+	return r.refresh(ctx)
+}
+
+func (r *cachedWeatherRuleRepository) refresh(ctx context.Context) error {
+	list, err := r.repo.ListRules(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load weather rules: %w", err)
+	}
+
+	rules := make(map[string]*entity.WeatherRule, len(list))
+	for _, rule := range list {
+		rules[rule.WeatherCode] = rule
+	}
+
+	r.mu.Lock()
+	r.rules = rules
+	r.loadedAt = r.now()
+	r.mu.Unlock()
+	return nil
+}
+
+// ensureFresh は未読み込みか ttl を過ぎていれば読み込み直す
+// 読み込み直しに失敗したときは、読み込み済みのルールがあればそれを使い続ける
+func (r *cachedWeatherRuleRepository) ensureFresh(ctx context.Context) error {
+	if !r.expired() {
+		return nil
+	}
+
+	r.refreshMu.Lock()
+	defer r.refreshMu.Unlock()
+	// 待っている間に他の呼び出しが読み込んでいれば何もしない
+	if !r.expired() {
+		return nil
+	}
+
+	err := r.refresh(ctx)
+	if err == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	loaded := r.rules != nil
+	r.mu.RUnlock()
+	if !loaded {
+		return err
+	}
+	log.Printf("[WARN] %v; using cached weather rules\n", err)
+	return nil
+}
+
+func (r *cachedWeatherRuleRepository) expired() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.rules == nil {
+		return true
+	}
+	return r.ttl > 0 && r.now().Sub(r.loadedAt) >= r.ttl
+}
+
+func (r *cachedWeatherRuleRepository) GetRule(ctx context.Context, weatherCode string) (*entity.WeatherRule, error) {
+	if err := r.ensureFresh(ctx); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	rule, ok := r.rules[weatherCode]
+	r.mu.RUnlock()
+	if !ok {
+		unknownWeatherCodes.Add(weatherCode, 1)
+		log.Printf("[WARN] unknown weather code %s\n", weatherCode)
+		return nil, fmt.Errorf("failed to get weather rule: unknown weather code %s", weatherCode)
+	}
+
+	// キャッシュの値を呼び出し側で書き換えられないようにコピーを返す
+	copied := *rule
+	return &copied, nil
+}
+
+func (r *cachedWeatherRuleRepository) ListRules(ctx context.Context) ([]*entity.WeatherRule, error) {
+	if err := r.ensureFresh(ctx); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	rules := make([]*entity.WeatherRule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		copied := *rule
+		rules = append(rules, &copied)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(rules, func(i, j int) bool { return rules[i].WeatherCode < rules[j].WeatherCode })
+	return rules, nil
+}
+
+func (r *cachedWeatherRuleRepository) CreateRule(ctx context.Context, rule *entity.WeatherRule) (bool, error) {
+	created, err := r.repo.CreateRule(ctx, rule)
+	if err != nil || !created {
+		return created, err
+	}
+	r.refreshAfterWrite(ctx)
+	return true, nil
+}
+
+func (r *cachedWeatherRuleRepository) UpdateRule(ctx context.Context, rule *entity.WeatherRule) (bool, error) {
+	updated, err := r.repo.UpdateRule(ctx, rule)
+	if err != nil || !updated {
+		return updated, err
+	}
+	r.refreshAfterWrite(ctx)
+	return true, nil
+}
+
+func (r *cachedWeatherRuleRepository) DeleteRule(ctx context.Context, weatherCode string) (bool, error) {
+	deleted, err := r.repo.DeleteRule(ctx, weatherCode)
+	if err != nil || !deleted {
+		return deleted, err
+	}
+	r.refreshAfterWrite(ctx)
+	return true, nil
+}
+
+// refreshAfterWrite は書き込み後に読み込み直す
+// 書き込み自体は成功しているので、失敗しても ttl が過ぎたときの読み込みに任せる
+func (r *cachedWeatherRuleRepository) refreshAfterWrite(ctx context.Context) {
+	if err := r.Refresh(ctx); err != nil {
+		log.Printf("[WARN] %v; changes will be loaded after the cache expires\n", err)
+	}
+}
@@ -0,0 +1,240 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubWeatherRuleRepo は ListRules の呼び出し回数を数えるスタブ
+type stubWeatherRuleRepo struct {
+	mu        sync.Mutex
+	rules     []*entity.WeatherRule
+	err       error
+	listCalls int
+}
+
+func (s *stubWeatherRuleRepo) GetRule(ctx context.Context, weatherCode string) (*entity.WeatherRule, error) {
+	return nil, errors.New("GetRule should not be called")
+}
+
+func (s *stubWeatherRuleRepo) ListRules(ctx context.Context) ([]*entity.WeatherRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listCalls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	rules := make([]*entity.WeatherRule, len(s.rules))
+	for i, r := range s.rules {
+		copied := *r
+		rules[i] = &copied
+	}
+	return rules, nil
+}
+
+func (s *stubWeatherRuleRepo) CreateRule(ctx context.Context, rule *entity.WeatherRule) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.rules {
+		if r.WeatherCode == rule.WeatherCode {
+			return false, nil
+		}
+	}
+	copied := *rule
+	s.rules = append(s.rules, &copied)
+	return true, nil
+}
+
+func (s *stubWeatherRuleRepo) UpdateRule(ctx context.Context, rule *entity.WeatherRule) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, r := range s.rules {
+		if r.WeatherCode == rule.WeatherCode {
+			copied := *rule
+			s.rules[i] = &copied
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *stubWeatherRuleRepo) DeleteRule(ctx context.Context, weatherCode string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, r := range s.rules {
+		if r.WeatherCode == weatherCode {
+			s.rules = append(s.rules[:i], s.rules[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *stubWeatherRuleRepo) set(rules []*entity.WeatherRule, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = rules
+	s.err = err
+}
+
+func (s *stubWeatherRuleRepo) calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listCalls
+}
+
+// testClock はテストで進める時計
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time          { return c.now }
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestCachedWeatherRuleRepository_ServesFromMemory(t *testing.T) {
+	ctx := context.Background()
+	base := &stubWeatherRuleRepo{rules: []*entity.WeatherRule{
+		{WeatherCode: "100", IsNotifyTrigger: false},
+		{WeatherCode: "300", IsNotifyTrigger: true},
+	}}
+	cache := repository.NewCachedWeatherRuleRepository(base, time.Hour)
+	require.NoError(t, cache.Refresh(ctx))
+
+	for i := 0; i < 100; i++ {
+		rule, err := cache.GetRule(ctx, "300")
+		require.NoError(t, err)
+		assert.True(t, rule.IsNotifyTrigger)
+	}
+	assert.Equal(t, 1, base.calls())
+
+	// 返した値を書き換えてもキャッシュには影響しない
+	rule, err := cache.GetRule(ctx, "100")
+	require.NoError(t, err)
+	rule.IsNotifyTrigger = true
+	rule, err = cache.GetRule(ctx, "100")
+	require.NoError(t, err)
+	assert.False(t, rule.IsNotifyTrigger)
+
+	rules, err := cache.ListRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "100", rules[0].WeatherCode)
+	assert.Equal(t, "300", rules[1].WeatherCode)
+}
+
+func TestCachedWeatherRuleRepository_LoadsOnFirstUse(t *testing.T) {
+	base := &stubWeatherRuleRepo{rules: []*entity.WeatherRule{{WeatherCode: "300", IsNotifyTrigger: true}}}
+	cache := repository.NewCachedWeatherRuleRepository(base, 0)
+
+	_, err := cache.GetRule(context.Background(), "300")
+	require.NoError(t, err)
+	assert.Equal(t, 1, base.calls())
+}
+
+func TestCachedWeatherRuleRepository_RefreshesAfterTTL(t *testing.T) {
+	ctx := context.Background()
+	base := &stubWeatherRuleRepo{rules: []*entity.WeatherRule{{WeatherCode: "200", IsNotifyTrigger: false}}}
+	clock := &testClock{now: time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)}
+	cache := repository.NewCachedWeatherRuleRepositoryWithClock(base, time.Minute, clock.Now)
+	require.NoError(t, cache.Refresh(ctx))
+
+	base.set([]*entity.WeatherRule{{WeatherCode: "200", IsNotifyTrigger: true}}, nil)
+	clock.Advance(59 * time.Second)
+	rule, err := cache.GetRule(ctx, "200")
+	require.NoError(t, err)
+	assert.False(t, rule.IsNotifyTrigger)
+
+	clock.Advance(time.Second)
+	rule, err = cache.GetRule(ctx, "200")
+	require.NoError(t, err)
+	assert.True(t, rule.IsNotifyTrigger)
+	assert.Equal(t, 2, base.calls())
+}
+
+func TestCachedWeatherRuleRepository_KeepsRulesWhenRefreshFails(t *testing.T) {
+	ctx := context.Background()
+	base := &stubWeatherRuleRepo{rules: []*entity.WeatherRule{{WeatherCode: "300", IsNotifyTrigger: true}}}
+	clock := &testClock{now: time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)}
+	cache := repository.NewCachedWeatherRuleRepositoryWithClock(base, time.Minute, clock.Now)
+	require.NoError(t, cache.Refresh(ctx))
+
+	base.set(nil, errors.New("db error"))
+	clock.Advance(time.Minute)
+
+	rule, err := cache.GetRule(ctx, "300")
+	require.NoError(t, err)
+	assert.True(t, rule.IsNotifyTrigger)
+	assert.Equal(t, 2, base.calls())
+
+	// 明示的な読み込み直しはエラーを返す
+	assert.Error(t, cache.Refresh(ctx))
+}
+
+func TestCachedWeatherRuleRepository_InitialLoadError(t *testing.T) {
+	base := &stubWeatherRuleRepo{err: errors.New("db error")}
+	cache := repository.NewCachedWeatherRuleRepository(base, time.Hour)
+
+	rule, err := cache.GetRule(context.Background(), "300")
+	assert.Error(t, err)
+	assert.Nil(t, rule)
+}
+
+func TestCachedWeatherRuleRepository_UnknownCode(t *testing.T) {
+	ctx := context.Background()
+	base := &stubWeatherRuleRepo{rules: []*entity.WeatherRule{{WeatherCode: "100"}}}
+	cache := repository.NewCachedWeatherRuleRepository(base, time.Hour)
+	require.NoError(t, cache.Refresh(ctx))
+
+	counter := expvar.Get("weather_rule_unknown_codes").(*expvar.Map)
+	before := int64(0)
+	if v, ok := counter.Get("999").(*expvar.Int); ok {
+		before = v.Value()
+	}
+
+	rule, err := cache.GetRule(ctx, "999")
+	assert.Error(t, err)
+	assert.Nil(t, rule)
+	assert.Equal(t, before+1, counter.Get("999").(*expvar.Int).Value())
+	// 未知のコードでは読み込み直さない
+	assert.Equal(t, 1, base.calls())
+}
+
+func TestCachedWeatherRuleRepository_WritesTakeEffectImmediately(t *testing.T) {
+	ctx := context.Background()
+	base := &stubWeatherRuleRepo{rules: []*entity.WeatherRule{{WeatherCode: "200", IsNotifyTrigger: false}}}
+	cache := repository.NewCachedWeatherRuleRepository(base, time.Hour)
+	require.NoError(t, cache.Refresh(ctx))
+
+	updated, err := cache.UpdateRule(ctx, &entity.WeatherRule{WeatherCode: "200", IsNotifyTrigger: true})
+	require.NoError(t, err)
+	assert.True(t, updated)
+	rule, err := cache.GetRule(ctx, "200")
+	require.NoError(t, err)
+	assert.True(t, rule.IsNotifyTrigger)
+
+	created, err := cache.CreateRule(ctx, &entity.WeatherRule{WeatherCode: "999", IsNotifyTrigger: true})
+	require.NoError(t, err)
+	assert.True(t, created)
+	_, err = cache.GetRule(ctx, "999")
+	require.NoError(t, err)
+
+	deleted, err := cache.DeleteRule(ctx, "999")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	_, err = cache.GetRule(ctx, "999")
+	assert.Error(t, err)
+
+	// 何も変わらなかったときは読み込み直さない
+	calls := base.calls()
+	deleted, err = cache.DeleteRule(ctx, "999")
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	assert.Equal(t, calls, base.calls())
+}
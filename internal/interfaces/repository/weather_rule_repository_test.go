@@ -32,16 +32,23 @@ func TestGetRule_Success(t *testing.T) {
 		WeatherCode:        "100",
 		WeatherDescription: "晴",
 		IsNotifyTrigger:    false,
+		Precipitation:      entity.PrecipitationNone,
+		Intensity:          entity.IntensityNone,
 	}
 
 	query := regexp.QuoteMeta(`
-	SELECT weather_code, weather_description, is_notify_trigger
+	SELECT weather_code, weather_description, is_notify_trigger,
+		precipitation, intensity, has_thunder, has_fog, severity
 	FROM weather_notification_rules
 	WHERE weather_code = $1
 	`)
 
-	rows := sqlmock.NewRows([]string{"weather_code", "weather_description", "is_notify_trigger"}).
-		AddRow(expectedRule.WeatherCode, expectedRule.WeatherDescription, expectedRule.IsNotifyTrigger)
+	rows := sqlmock.NewRows([]string{
+		"weather_code", "weather_description", "is_notify_trigger",
+		"precipitation", "intensity", "has_thunder", "has_fog", "severity",
+	}).
+		AddRow(expectedRule.WeatherCode, expectedRule.WeatherDescription, expectedRule.IsNotifyTrigger,
+			expectedRule.Precipitation, expectedRule.Intensity, false, false, 0)
 
 	mock.ExpectQuery(query).WithArgs(weatherCode).WillReturnRows(rows)
 
@@ -51,6 +58,8 @@ func TestGetRule_Success(t *testing.T) {
 	assert.Equal(t, expectedRule.WeatherCode, rule.WeatherCode)
 	assert.Equal(t, expectedRule.WeatherDescription, rule.WeatherDescription)
 	assert.Equal(t, expectedRule.IsNotifyTrigger, rule.IsNotifyTrigger)
+	assert.Equal(t, expectedRule.Precipitation, rule.Precipitation)
+	assert.Equal(t, expectedRule.Intensity, rule.Intensity)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -63,7 +72,8 @@ func TestGetRule_NotFound(t *testing.T) {
 	weatherCode := "999"
 
 	query := regexp.QuoteMeta(`
-	SELECT weather_code, weather_description, is_notify_trigger
+	SELECT weather_code, weather_description, is_notify_trigger,
+		precipitation, intensity, has_thunder, has_fog, severity
 	FROM weather_notification_rules
 	WHERE weather_code = $1
 	`)
@@ -85,7 +95,8 @@ func TestGetRule_QueryError(t *testing.T) {
 	weatherCode := "100"
 
 	query := regexp.QuoteMeta(`
-	SELECT weather_code, weather_description, is_notify_trigger
+	SELECT weather_code, weather_description, is_notify_trigger,
+		precipitation, intensity, has_thunder, has_fog, severity
 	FROM weather_notification_rules
 	WHERE weather_code = $1
 	`)
@@ -98,3 +109,136 @@ func TestGetRule_QueryError(t *testing.T) {
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestListRules_Success(t *testing.T) {
+	repo, mock, cleanup := setupWeatherRuleRepoTest(t)
+	defer cleanup()
+
+	query := regexp.QuoteMeta(`
+	SELECT weather_code, weather_description, is_notify_trigger,
+		precipitation, intensity, has_thunder, has_fog, severity
+	FROM weather_notification_rules
+	ORDER BY weather_code
+	`)
+	rows := sqlmock.NewRows([]string{
+		"weather_code", "weather_description", "is_notify_trigger",
+		"precipitation", "intensity", "has_thunder", "has_fog", "severity",
+	}).
+		AddRow("100", "晴", false, "none", "none", false, false, 0).
+		AddRow("350", "雨で雷を伴う", true, "rain", "moderate", true, false, 3)
+	mock.ExpectQuery(query).WillReturnRows(rows)
+
+	rules, err := repo.ListRules(context.Background())
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "100", rules[0].WeatherCode)
+	assert.Equal(t, "350", rules[1].WeatherCode)
+	assert.True(t, rules[1].HasThunder)
+	assert.Equal(t, 3, rules[1].Severity)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListRules_QueryError(t *testing.T) {
+	repo, mock, cleanup := setupWeatherRuleRepoTest(t)
+	defer cleanup()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM weather_notification_rules`)).WillReturnError(errors.New("db error"))
+
+	rules, err := repo.ListRules(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to query weather rules")
+	assert.Nil(t, rules)
+}
+
+func TestCreateRule(t *testing.T) {
+	query := regexp.QuoteMeta(`
+	INSERT INTO weather_notification_rules (
+		weather_code, weather_description, is_notify_trigger,
+		precipitation, intensity, has_thunder, has_fog, severity
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (weather_code) DO NOTHING
+	RETURNING weather_code
+	`)
+	rule := &entity.WeatherRule{
+		WeatherCode: "350", WeatherDescription: "雨で雷を伴う", IsNotifyTrigger: true,
+		Precipitation: "rain", Intensity: "moderate", HasThunder: true, Severity: 3,
+	}
+
+	t.Run("created", func(t *testing.T) {
+		repo, mock, cleanup := setupWeatherRuleRepoTest(t)
+		defer cleanup()
+
+		mock.ExpectQuery(query).
+			WithArgs("350", "雨で雷を伴う", true, "rain", "moderate", true, false, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"weather_code"}).AddRow("350"))
+
+		created, err := repo.CreateRule(context.Background(), rule)
+		require.NoError(t, err)
+		assert.True(t, created)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("already exists", func(t *testing.T) {
+		repo, mock, cleanup := setupWeatherRuleRepoTest(t)
+		defer cleanup()
+
+		mock.ExpectQuery(query).WillReturnError(sql.ErrNoRows)
+
+		created, err := repo.CreateRule(context.Background(), rule)
+		require.NoError(t, err)
+		assert.False(t, created)
+	})
+}
+
+func TestUpdateRule(t *testing.T) {
+	query := regexp.QuoteMeta(`UPDATE weather_notification_rules`)
+	rule := &entity.WeatherRule{WeatherCode: "200", WeatherDescription: "曇", IsNotifyTrigger: true, Precipitation: "none", Intensity: "none"}
+
+	t.Run("updated", func(t *testing.T) {
+		repo, mock, cleanup := setupWeatherRuleRepoTest(t)
+		defer cleanup()
+
+		mock.ExpectExec(query).
+			WithArgs("200", "曇", true, "none", "none", false, false, 0).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		updated, err := repo.UpdateRule(context.Background(), rule)
+		require.NoError(t, err)
+		assert.True(t, updated)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		repo, mock, cleanup := setupWeatherRuleRepoTest(t)
+		defer cleanup()
+
+		mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		updated, err := repo.UpdateRule(context.Background(), rule)
+		require.NoError(t, err)
+		assert.False(t, updated)
+	})
+}
+
+func TestDeleteRule(t *testing.T) {
+	repo, mock, cleanup := setupWeatherRuleRepoTest(t)
+	defer cleanup()
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM weather_notification_rules WHERE weather_code = $1`)).
+		WithArgs("999").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	deleted, err := repo.DeleteRule(context.Background(), "999")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM weather_notification_rules`)).
+		WithArgs("999").
+		WillReturnError(errors.New("db error"))
+
+	deleted, err = repo.DeleteRule(context.Background(), "999")
+	require.Error(t, err)
+	assert.False(t, deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
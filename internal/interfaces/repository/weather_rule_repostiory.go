@@ -10,6 +10,10 @@ import (
 
 type WeatherRuleRepository interface {
 	GetRule(ctx context.Context, weatherCode string) (*entity.WeatherRule, error)
+	ListRules(ctx context.Context) ([]*entity.WeatherRule, error)
+	CreateRule(ctx context.Context, rule *entity.WeatherRule) (bool, error)
+	UpdateRule(ctx context.Context, rule *entity.WeatherRule) (bool, error)
+	DeleteRule(ctx context.Context, weatherCode string) (bool, error)
 }
 
 type weatherRuleRepository struct {
@@ -22,7 +26,8 @@ func NewWeatherRuleRepository(db *sql.DB) WeatherRuleRepository {
 
 func (r *weatherRuleRepository) GetRule(ctx context.Context, weatherCode string) (*entity.WeatherRule, error) {
 	query := `
-	SELECT weather_code, weather_description, is_notify_trigger
+	SELECT weather_code, weather_description, is_notify_trigger,
+		precipitation, intensity, has_thunder, has_fog, severity
 	FROM weather_notification_rules
 	WHERE weather_code = $1
 	`
@@ -30,9 +35,123 @@ func (r *weatherRuleRepository) GetRule(ctx context.Context, weatherCode string)
 	var rule entity.WeatherRule
 	err := r.db.QueryRowContext(ctx, query, weatherCode).Scan(
 		&rule.WeatherCode, &rule.WeatherDescription, &rule.IsNotifyTrigger,
+		&rule.Precipitation, &rule.Intensity, &rule.HasThunder, &rule.HasFog, &rule.Severity,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get weather rule: %w", err)
 	}
 	return &rule, nil
 }
+
+// ListRules は全ての天気コードのルールを返します
+func (r *weatherRuleRepository) ListRules(ctx context.Context) ([]*entity.WeatherRule, error) {
+	query := `
+	SELECT weather_code, weather_description, is_notify_trigger,
+		precipitation, intensity, has_thunder, has_fog, severity
+	FROM weather_notification_rules
+	ORDER BY weather_code
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query weather rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*entity.WeatherRule
+	for rows.Next() {
+		var rule entity.WeatherRule
+		if err := rows.Scan(
+			&rule.WeatherCode, &rule.WeatherDescription, &rule.IsNotifyTrigger,
+			&rule.Precipitation, &rule.Intensity, &rule.HasThunder, &rule.HasFog, &rule.Severity,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan weather rule: %w", err)
+		}
+		rules = append(rules, &rule)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return rules, nil
+}
+
+// CreateRule は天気コードのルールを登録します。同じコードが既にあれば登録せず false を返します
+func (r *weatherRuleRepository) CreateRule(ctx context.Context, rule *entity.WeatherRule) (bool, error) {
+	query := `
+	INSERT INTO weather_notification_rules (
+		weather_code, weather_description, is_notify_trigger,
+		precipitation, intensity, has_thunder, has_fog, severity
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (weather_code) DO NOTHING
+	RETURNING weather_code
+	`
+
+	var code string
+	err := r.db.QueryRowContext(ctx, query,
+		rule.WeatherCode,
+		rule.WeatherDescription,
+		rule.IsNotifyTrigger,
+		rule.Precipitation,
+		rule.Intensity,
+		rule.HasThunder,
+		rule.HasFog,
+		rule.Severity,
+	).Scan(&code)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to create weather rule: %w", err)
+	}
+	return true, nil
+}
+
+// UpdateRule は天気コードのルールを更新します。コードが無ければ false を返します
+func (r *weatherRuleRepository) UpdateRule(ctx context.Context, rule *entity.WeatherRule) (bool, error) {
+	query := `
+	UPDATE weather_notification_rules
+	SET weather_description = $2,
+		is_notify_trigger = $3,
+		precipitation = $4,
+		intensity = $5,
+		has_thunder = $6,
+		has_fog = $7,
+		severity = $8
+	WHERE weather_code = $1
+	`
+
+	result, err := r.db.ExecContext(ctx, query,
+		rule.WeatherCode,
+		rule.WeatherDescription,
+		rule.IsNotifyTrigger,
+		rule.Precipitation,
+		rule.Intensity,
+		rule.HasThunder,
+		rule.HasFog,
+		rule.Severity,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update weather rule: %w", err)
+	}
+	return rowsAffected(result)
+}
+
+// DeleteRule は天気コードのルールを削除します。コードが無ければ false を返します
+func (r *weatherRuleRepository) DeleteRule(ctx context.Context, weatherCode string) (bool, error) {
+	query := `DELETE FROM weather_notification_rules WHERE weather_code = $1`
+
+	result, err := r.db.ExecContext(ctx, query, weatherCode)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete weather rule: %w", err)
+	}
+	return rowsAffected(result)
+}
+
+func rowsAffected(result sql.Result) (bool, error) {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return n > 0, nil
+}
@@ -27,6 +27,14 @@ func (m *MockAreaRepo) FindHierarchyByClass20ID(ctx context.Context, class20ID s
 	return hier, args.Error(1)
 }
 
+func (m *MockAreaRepo) FindActiveOfficeIDs(ctx context.Context) ([]string, error) {
+	args := m.Called(ctx)
+	if ids := args.Get(0); ids != nil {
+		return ids.([]string), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 // テスト用セットアップ関数
 func setupAreaUsecaseTest() (*MockAreaRepo, usecase.AreaUseCase) {
 	mockRepo := new(MockAreaRepo)
@@ -0,0 +1,206 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+)
+
+// BatchConfig は複数ユーザーをまとめて処理するときの設定
+type BatchConfig struct {
+	Workers     int           // 同時に処理するユーザー数。0 以下なら defaultBatchWorkers
+	UserTimeout time.Duration // 1ユーザーの処理にかける時間の上限。0 以下なら defaultUserTimeout
+	// MaxFailureRate はこれを超える割合のユーザーが失敗したら実行全体を失敗とみなす。0 以下なら defaultMaxFailureRate
+	MaxFailureRate float64
+}
+
+const (
+	defaultBatchWorkers   = 8
+	defaultUserTimeout    = 30 * time.Second
+	defaultMaxFailureRate = 0.1
+)
+
+func (c BatchConfig) workers() int {
+	if c.Workers <= 0 {
+		return defaultBatchWorkers
+	}
+	return c.Workers
+}
+
+func (c BatchConfig) userTimeout() time.Duration {
+	if c.UserTimeout <= 0 {
+		return defaultUserTimeout
+	}
+	return c.UserTimeout
+}
+
+func (c BatchConfig) maxFailureRate() float64 {
+	if c.MaxFailureRate <= 0 {
+		return defaultMaxFailureRate
+	}
+	return c.MaxFailureRate
+}
+
+// BatchResult は複数ユーザーをまとめて処理した結果
+type BatchResult struct {
+	Matched     int          `json:"matched"`     // 対象のユーザー数
+	Evaluated   int          `json:"evaluated"`   // 予報を判定できたユーザー数
+	Notified    int          `json:"notified"`    // 通知したユーザー数
+	Skipped     int          `json:"skipped"`     // 判定の結果、通知不要だったユーザー数
+	Queued      int          `json:"queued"`      // 通知に失敗し、再送待ちにしたユーザー数
+	Duplicates  []int        `json:"duplicates"`  // 同じ対象日の通知を他の実行で処理済みだったユーザーID
+	Failed      int          `json:"failed"`      // 失敗したユーザー数
+	FailureRate float64      `json:"failureRate"` // Failed / Matched
+	Degraded    bool         `json:"degraded"`    // FailureRate が BatchConfig.MaxFailureRate を超えた
+	Failures    []*UserError `json:"failures"`
+	DurationMs  int64        `json:"durationMs"`
+
+	// 予報を取得したオフィスのうち、ForecastCache の予報か JMA の 304 でキャッシュを使った数と、取得し直した数
+	ForecastCacheHits   int `json:"forecastCacheHits"`
+	ForecastCacheMisses int `json:"forecastCacheMisses"`
+
+	// DryRun のときは通知も保存もしておらず、Notified は通知するはずだったユーザー数
+	DryRun    bool            `json:"dryRun"`
+	Decisions []*UserDecision `json:"decisions,omitempty"` // ドライランでの各ユーザーの判定結果
+}
+
+// Err は失敗したユーザーのエラーを1つにまとめる。失敗が無ければ nil
+func (r *BatchResult) Err() error {
+	if len(r.Failures) == 0 {
+		return nil
+	}
+	errs := make([]error, len(r.Failures))
+	for i, err := range r.Failures {
+		errs[i] = err
+	}
+	return errors.Join(errs...)
+}
+
+// userOutcome は1ユーザー分の処理がどこまで進んだか
+type userOutcome int
+
+const (
+	outcomeNotEvaluated userOutcome = iota // 判定の前に失敗した
+	outcomeEvaluated                       // 判定したが履歴の保存に失敗した
+	outcomeSkipped                         // 判定の結果、通知不要だった
+	outcomeNotified                        // 通知した
+	outcomeDuplicate                       // 他の実行で処理済みだった
+	outcomeQueued                          // 通知に失敗し、再送待ちにした
+)
+
+// UserError は1ユーザー分の処理の失敗
+type UserError struct {
+	UserID int
+	Err    error
+}
+
+func (e *UserError) Error() string {
+	return fmt.Sprintf("user %d: %v", e.UserID, e.Err)
+}
+
+func (e *UserError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UserError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		UserID int    `json:"userId"`
+		Reason string `json:"reason"`
+	}{e.UserID, e.Err.Error()})
+}
+
+// runForUsers は users を cfg.Workers 並列で fn に渡し、結果を集計する。失敗はユーザーID順に並べる
+// fn にはユーザーごとにタイムアウトを設定した ctx を渡す
+// ctx がキャンセルされると、まだ始めていないユーザーは処理せずキャンセルのエラーにする
+func runForUsers(ctx context.Context, cfg BatchConfig, users []*entity.User, fn func(ctx context.Context, user *entity.User) (userOutcome, error)) *BatchResult {
+	startedAt := time.Now()
+	result := &BatchResult{Matched: len(users), Duplicates: []int{}, Failures: []*UserError{}}
+
+	var mu sync.Mutex
+	record := func(user *entity.User, outcome userOutcome, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch outcome {
+		case outcomeEvaluated:
+			result.Evaluated++
+		case outcomeNotified:
+			result.Evaluated++
+			result.Notified++
+		case outcomeSkipped:
+			result.Evaluated++
+			result.Skipped++
+		case outcomeQueued:
+			result.Evaluated++
+			result.Queued++
+		case outcomeDuplicate:
+			result.Duplicates = append(result.Duplicates, user.ID)
+		}
+		if err != nil {
+			result.Failures = append(result.Failures, &UserError{UserID: user.ID, Err: err})
+		}
+	}
+
+	runParallel(ctx, cfg, users,
+		func(ctx context.Context, user *entity.User) {
+			outcome, err := fn(ctx, user)
+			record(user, outcome, err)
+		},
+		func(user *entity.User, err error) {
+			record(user, outcomeNotEvaluated, err)
+		},
+	)
+
+	sort.Ints(result.Duplicates)
+	sort.Slice(result.Failures, func(i, j int) bool { return result.Failures[i].UserID < result.Failures[j].UserID })
+	result.Failed = len(result.Failures)
+	if result.Matched > 0 {
+		result.FailureRate = float64(result.Failed) / float64(result.Matched)
+	}
+	result.Degraded = result.FailureRate > cfg.maxFailureRate()
+	result.DurationMs = time.Since(startedAt).Milliseconds()
+	return result
+}
+
+// runParallel は items を cfg.Workers 並列で fn に渡し、全て終わるまで待つ
+// fn には1件ごとに cfg.UserTimeout のタイムアウトを設定した ctx を渡す
+// ctx がキャンセルされると、まだ始めていない item は fn に渡さず cancelled に渡す
+func runParallel[T any](ctx context.Context, cfg BatchConfig, items []T, fn func(ctx context.Context, item T), cancelled func(item T, err error)) {
+	workers := cfg.workers()
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	jobs := make(chan T)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range jobs {
+				itemCtx, cancel := context.WithTimeout(ctx, cfg.userTimeout())
+				fn(itemCtx, item)
+				cancel()
+			}
+		}()
+	}
+
+dispatch:
+	for i, item := range items {
+		select {
+		case jobs <- item:
+		case <-ctx.Done():
+			for _, skipped := range items[i:] {
+				cancelled(skipped, ctx.Err())
+			}
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,531 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/cache"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+	"github.com/Isshinfunada/weather-bot/internal/usecase"
+	"github.com/Isshinfunada/weather-bot/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// StubBatchFetcher は同時に実行中の取得数を数えるスタブです
+// block が設定されたオフィスは ctx が終わるまで返らない
+// notModified が設定されたオフィスは前回から更新されていない予報を返す
+type StubBatchFetcher struct {
+	delay       time.Duration
+	block       map[string]bool
+	notModified map[string]bool
+
+	mu          sync.Mutex
+	calls       int
+	inFlight    int
+	maxInFlight int
+}
+
+func (f *StubBatchFetcher) FetchForecast(ctx context.Context, officeID string) (*jma.Forecast, error) {
+	f.mu.Lock()
+	f.calls++
+	f.inFlight++
+	if f.inFlight > f.maxInFlight {
+		f.maxInFlight = f.inFlight
+	}
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.inFlight--
+		f.mu.Unlock()
+	}()
+
+	if f.block[officeID] {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	forecast := newTestForecast(officeID, "100")
+	forecast.NotModified = f.notModified[officeID]
+	return forecast, nil
+}
+
+// Calls は取得した回数を返す。ユーザーのタイムアウト後も取得が続いていることがあるのでロックして読む
+func (f *StubBatchFetcher) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// setupBatchTest はユーザーごとに別のオフィスを割り当てた WeatherUsecase を作る
+// ユーザー n はオフィス office<n> に属する
+func setupBatchTest(t *testing.T, userCount int, fetcher jma.ForecastFetcher, cfg usecase.BatchConfig) (usecase.WeatherUsecase, *MockUserRepoForRange, *MockAreaUC) {
+	t.Helper()
+
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockAreaUC := new(MockAreaUC)
+	mockUserRepo := new(MockUserRepoForRange)
+
+	users := make([]*entity.User, userCount)
+	for i := range users {
+		id := i + 1
+		users[i] = &entity.User{ID: id, SelectedAreaID: fmt.Sprintf("area%d", id)}
+		officeID := fmt.Sprintf("office%d", id)
+		mockAreaUC.On("GetHierarchy", mock.Anything, users[i].SelectedAreaID).Return(&entity.HierarchyArea{
+			Office:  &entity.AreaOffice{ID: officeID},
+			Class10: &entity.AreaClass10{ID: officeID},
+		}, nil).Maybe()
+	}
+	mockUserRepo.On("FindUserByNotifyTimeRange", mock.Anything, mock.Anything, mock.Anything).Return(users, nil)
+	mockRuleRepo.On("GetRule", mock.Anything, "100").Return(&entity.WeatherRule{WeatherCode: "100", IsNotifyTrigger: false}, nil).Maybe()
+	mockNotificationRepo.
+		On("InsertNotificationHistory", mock.Anything, mock.AnythingOfType("*entity.NotificationHistory")).
+		Return(nil).Maybe()
+
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, mockUserRepo, mockAreaUC, fetcher, nil, &StubSnapshotRepo{}, newNopNotifier(), cfg)
+	return weatherUC, mockUserRepo, mockAreaUC
+}
+
+func batchTimeRange() (time.Time, time.Time) {
+	return time.Date(0, 1, 1, 8, 0, 0, 0, utils.JST), time.Date(0, 1, 1, 9, 0, 0, 0, utils.JST)
+}
+
+func TestProcessWeatherForUsersInTimeRange_BoundedWorkers(t *testing.T) {
+	fetcher := &StubBatchFetcher{delay: 20 * time.Millisecond}
+	weatherUC, _, _ := setupBatchTest(t, 6, fetcher, usecase.BatchConfig{Workers: 2})
+
+	start, end := batchTimeRange()
+	result, err := weatherUC.ProcessWeatherForUsersInTimeRange(context.Background(), start, end, usecase.ProcessOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, 6, result.Matched)
+	assert.Equal(t, 6, result.Evaluated)
+	assert.Equal(t, 6, result.Skipped)
+	assert.Empty(t, result.Failures)
+	assert.Equal(t, 6, fetcher.calls)
+	assert.LessOrEqual(t, fetcher.maxInFlight, 2)
+}
+
+func TestProcessWeatherForUsersInTimeRange_ForecastCacheStats(t *testing.T) {
+	fetcher := &StubBatchFetcher{notModified: map[string]bool{"office1": true, "office3": true}}
+	weatherUC, _, _ := setupBatchTest(t, 4, fetcher, usecase.BatchConfig{})
+
+	start, end := batchTimeRange()
+	result, err := weatherUC.ProcessWeatherForUsersInTimeRange(context.Background(), start, end, usecase.ProcessOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, result.ForecastCacheHits)
+	assert.Equal(t, 2, result.ForecastCacheMisses)
+}
+
+func TestProcessWeatherForUsersInTimeRange_ForecastCache(t *testing.T) {
+	ctx := context.Background()
+
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockAreaUC := new(MockAreaUC)
+	mockUserRepo := new(MockUserRepoForRange)
+	mockFetcher := new(MockForecastFetcher)
+	mockNotificationRepo := new(MockNotificationRepo)
+
+	users := []*entity.User{{ID: 1, SelectedAreaID: "area1"}, {ID: 2, SelectedAreaID: "area2"}}
+	mockUserRepo.On("FindUserByNotifyTimeRange", ctx, mock.Anything, mock.Anything).Return(users, nil)
+	for _, id := range []string{"1", "2"} {
+		mockAreaUC.On("GetHierarchy", mock.Anything, "area"+id).Return(&entity.HierarchyArea{
+			Office:  &entity.AreaOffice{ID: "office" + id},
+			Class10: &entity.AreaClass10{ID: "office" + id},
+		}, nil)
+	}
+	mockRuleRepo.On("GetRule", mock.Anything, "100").Return(&entity.WeatherRule{WeatherCode: "100", IsNotifyTrigger: false}, nil)
+	mockNotificationRepo.
+		On("InsertNotificationHistory", mock.Anything, mock.AnythingOfType("*entity.NotificationHistory")).
+		Return(nil)
+	mockFetcher.On("FetchForecast", mock.Anything, "office2").Return(newTestForecast("office2", "100"), nil)
+
+	// office1 は他のレプリカが取得済み
+	forecastCache := cache.NewMemoryForecastCache(0, time.Hour)
+	require.NoError(t, forecastCache.Set(ctx, "office1", newTestForecast("office1", "100")))
+
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, mockUserRepo, mockAreaUC, mockFetcher, forecastCache, &StubSnapshotRepo{}, newNopNotifier(), usecase.BatchConfig{})
+	start, end := batchTimeRange()
+	result, err := weatherUC.ProcessWeatherForUsersInTimeRange(ctx, start, end, usecase.ProcessOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, result.Evaluated)
+	assert.Equal(t, 1, result.ForecastCacheHits)
+	assert.Equal(t, 1, result.ForecastCacheMisses)
+	mockFetcher.AssertNotCalled(t, "FetchForecast", mock.Anything, "office1")
+
+	// JMA から取得した予報はキャッシュに保存する
+	cached, err := forecastCache.Get(ctx, "office2")
+	require.NoError(t, err)
+	assert.NotNil(t, cached)
+}
+
+func TestProcessWeatherForUsersInTimeRange_UserTimeout(t *testing.T) {
+	// ユーザー1の取得だけが返らない
+	fetcher := &StubBatchFetcher{block: map[string]bool{"office1": true}}
+	weatherUC, _, _ := setupBatchTest(t, 2, fetcher, usecase.BatchConfig{Workers: 2, UserTimeout: 50 * time.Millisecond})
+
+	start, end := batchTimeRange()
+	result, err := weatherUC.ProcessWeatherForUsersInTimeRange(context.Background(), start, end, usecase.ProcessOptions{})
+	require.NoError(t, err)
+
+	// タイムアウトしたユーザーだけが失敗し、他のユーザーは処理される
+	require.Len(t, result.Failures, 1)
+	assert.Equal(t, 1, result.Failures[0].UserID)
+	assert.True(t, errors.Is(result.Failures[0], context.DeadlineExceeded))
+	assert.Equal(t, 1, result.Evaluated)
+	assert.Equal(t, 2, fetcher.Calls())
+}
+
+func TestProcessWeatherForUsersInTimeRange_ParentCanceled(t *testing.T) {
+	fetcher := &StubBatchFetcher{block: map[string]bool{"office1": true}}
+	weatherUC, _, _ := setupBatchTest(t, 3, fetcher, usecase.BatchConfig{Workers: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(30*time.Millisecond, cancel)
+
+	start, end := batchTimeRange()
+	result, err := weatherUC.ProcessWeatherForUsersInTimeRange(ctx, start, end, usecase.ProcessOptions{})
+	require.NoError(t, err)
+
+	// キャンセル後は残りのユーザーを処理しない
+	assert.Equal(t, 1, fetcher.Calls())
+	require.Len(t, result.Failures, 3)
+	for i, failure := range result.Failures {
+		assert.Equal(t, i+1, failure.UserID)
+		assert.True(t, errors.Is(failure, context.Canceled))
+	}
+	assert.True(t, result.Degraded)
+	assert.True(t, errors.Is(result.Err(), context.Canceled))
+}
+
+func TestProcessWeatherForUsersInTimeRange_CollectsErrors(t *testing.T) {
+	fetcher := &StubBatchFetcher{}
+	weatherUC, _, mockAreaUC := setupBatchTest(t, 3, fetcher, usecase.BatchConfig{MaxFailureRate: 0.5})
+
+	// ユーザー2の地域だけ取得に失敗する
+	mockAreaUC.ExpectedCalls = nil
+	for _, id := range []int{1, 3} {
+		officeID := fmt.Sprintf("office%d", id)
+		mockAreaUC.On("GetHierarchy", mock.Anything, fmt.Sprintf("area%d", id)).Return(&entity.HierarchyArea{
+			Office:  &entity.AreaOffice{ID: officeID},
+			Class10: &entity.AreaClass10{ID: officeID},
+		}, nil)
+	}
+	mockAreaUC.On("GetHierarchy", mock.Anything, "area2").Return(nil, errors.New("db error"))
+
+	start, end := batchTimeRange()
+	result, err := weatherUC.ProcessWeatherForUsersInTimeRange(context.Background(), start, end, usecase.ProcessOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, result.Matched)
+	assert.Equal(t, 2, result.Evaluated)
+	assert.Equal(t, 1, result.Failed)
+	require.Len(t, result.Failures, 1)
+	assert.Equal(t, 2, result.Failures[0].UserID)
+	assert.Contains(t, result.Failures[0].Error(), "db error")
+	assert.InDelta(t, 1.0/3, result.FailureRate, 0.001)
+	// 閾値 0.5 以下なので実行全体は失敗にしない
+	assert.False(t, result.Degraded)
+	assert.Equal(t, 2, fetcher.calls)
+}
+
+func TestProcessWeatherForUsersInTimeRange_CircuitOpen(t *testing.T) {
+	ctx := context.Background()
+
+	mockAreaUC := new(MockAreaUC)
+	mockUserRepo := new(MockUserRepoForRange)
+	mockFetcher := new(MockForecastFetcher)
+
+	users := []*entity.User{{ID: 1, SelectedAreaID: "1310100"}, {ID: 2, SelectedAreaID: "1310100"}, {ID: 3, SelectedAreaID: "1310100"}}
+	mockUserRepo.On("FindUserByNotifyTimeRange", ctx, mock.Anything, mock.Anything).Return(users, nil)
+	mockAreaUC.On("GetHierarchy", mock.Anything, "1310100").Return(&entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "130000"},
+		Class10: &entity.AreaClass10{ID: "130010"},
+	}, nil)
+	mockFetcher.On("FetchForecast", mock.Anything, "130000").Return(nil, fmt.Errorf("failed to fetch: %w", jma.ErrCircuitOpen))
+
+	weatherUC := usecase.NewWeatherUsecase(new(MockWeatherRuleRepo), &StubUserRuleRepo{}, new(MockNotificationRepo), &StubDeliveryRepo{}, mockUserRepo, mockAreaUC, mockFetcher, nil, &StubSnapshotRepo{}, newNopNotifier(), usecase.BatchConfig{Workers: 1})
+	start, end := batchTimeRange()
+	result, err := weatherUC.ProcessWeatherForUsersInTimeRange(ctx, start, end, usecase.ProcessOptions{})
+	require.NoError(t, err)
+
+	// JMA へのリクエストが止まっている間は、同じオフィスのユーザーで取得し直さない
+	require.Len(t, result.Failures, 3)
+	for _, failure := range result.Failures {
+		assert.ErrorIs(t, failure, jma.ErrCircuitOpen)
+	}
+	mockFetcher.AssertNumberOfCalls(t, "FetchForecast", 1)
+}
+
+func TestProcessWeatherForUsersInTimeRange_CountsNotified(t *testing.T) {
+	ctx := context.Background()
+
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockAreaUC := new(MockAreaUC)
+	mockUserRepo := new(MockUserRepoForRange)
+	mockFetcher := new(MockForecastFetcher)
+	mockNotifier := new(MockNotifier)
+
+	users := []*entity.User{{ID: 1, SelectedAreaID: "1310100"}, {ID: 2, SelectedAreaID: "1310100"}}
+	mockUserRepo.On("FindUserByNotifyTimeRange", ctx, mock.Anything, mock.Anything).Return(users, nil)
+	mockAreaUC.On("GetHierarchy", mock.Anything, "1310100").Return(&entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "130000"},
+		Class10: &entity.AreaClass10{ID: "130010"},
+	}, nil)
+	mockFetcher.On("FetchForecast", mock.Anything, "130000").Return(newTestForecast("130010", "300"), nil)
+	mockRuleRepo.On("GetRule", mock.Anything, "300").Return(&entity.WeatherRule{WeatherCode: "300", IsNotifyTrigger: true}, nil)
+	mockNotificationRepo.
+		On("InsertNotificationHistory", mock.Anything, mock.AnythingOfType("*entity.NotificationHistory")).
+		Return(nil)
+	// ユーザー2への通知だけ失敗する
+	mockNotifier.On("Notify", mock.Anything, users[0], mock.Anything).Return(nil)
+	mockNotifier.On("Notify", mock.Anything, users[1], mock.Anything).Return(errors.New("line api error"))
+
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, mockUserRepo, mockAreaUC, mockFetcher, nil, &StubSnapshotRepo{}, mockNotifier, usecase.BatchConfig{})
+	start, end := batchTimeRange()
+	result, err := weatherUC.ProcessWeatherForUsersInTimeRange(ctx, start, end, usecase.ProcessOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, result.Matched)
+	assert.Equal(t, 2, result.Evaluated)
+	assert.Equal(t, 1, result.Notified)
+	assert.Equal(t, 0, result.Skipped)
+	// 送れなかった通知は再送待ちにするので、失敗には数えない
+	assert.Equal(t, 1, result.Queued)
+	assert.Empty(t, result.Failures)
+	assert.False(t, result.Degraded)
+
+	statuses := map[int]entity.NotificationHistory{}
+	for _, h := range mockNotificationRepo.Updates() {
+		statuses[h.UserID] = h
+	}
+	assert.Equal(t, entity.DeliveryStatusSent, statuses[1].DeliveryStatus)
+	assert.NotNil(t, statuses[1].DeliveredAt)
+	assert.Equal(t, entity.DeliveryStatusPending, statuses[2].DeliveryStatus)
+	assert.Equal(t, 1, statuses[2].DeliveryAttempts)
+	assert.Equal(t, "line api error", statuses[2].LastError)
+	require.NotNil(t, statuses[2].NextAttemptAt)
+}
+
+func TestProcessWeatherForUsersInTimeRange_HistoryError(t *testing.T) {
+	ctx := context.Background()
+
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockAreaUC := new(MockAreaUC)
+	mockUserRepo := new(MockUserRepoForRange)
+	mockFetcher := new(MockForecastFetcher)
+	mockNotifier := new(MockNotifier)
+
+	users := []*entity.User{{ID: 1, SelectedAreaID: "1310100"}}
+	mockUserRepo.On("FindUserByNotifyTimeRange", ctx, mock.Anything, mock.Anything).Return(users, nil)
+	mockAreaUC.On("GetHierarchy", mock.Anything, "1310100").Return(&entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "130000"},
+		Class10: &entity.AreaClass10{ID: "130010"},
+	}, nil)
+	mockFetcher.On("FetchForecast", mock.Anything, "130000").Return(newTestForecast("130010", "300"), nil)
+	mockRuleRepo.On("GetRule", mock.Anything, "300").Return(&entity.WeatherRule{WeatherCode: "300", IsNotifyTrigger: true}, nil)
+	mockNotificationRepo.
+		On("InsertNotificationHistory", mock.Anything, mock.AnythingOfType("*entity.NotificationHistory")).
+		Return(errors.New("db error")).Once()
+	mockNotificationRepo.
+		On("InsertNotificationHistory", mock.Anything, mock.AnythingOfType("*entity.NotificationHistory")).
+		Return(nil)
+	mockNotifier.On("Notify", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, mockUserRepo, mockAreaUC, mockFetcher, nil, &StubSnapshotRepo{}, mockNotifier, usecase.BatchConfig{})
+	start, end := batchTimeRange()
+
+	// 判定を書き込めなければ送らず、次の実行で処理し直す
+	first, err := weatherUC.ProcessWeatherForUsersInTimeRange(ctx, start, end, usecase.ProcessOptions{})
+	require.NoError(t, err)
+	require.Len(t, first.Failures, 1)
+	assert.Equal(t, 1, first.Evaluated)
+	mockNotifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything, mock.Anything)
+
+	second, err := weatherUC.ProcessWeatherForUsersInTimeRange(ctx, start, end, usecase.ProcessOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, second.Notified)
+	assert.Empty(t, second.Failures)
+}
+
+func TestProcessWeatherForUsersInTimeRange_FindUsersError(t *testing.T) {
+	mockUserRepo := new(MockUserRepoForRange)
+	mockUserRepo.On("FindUserByNotifyTimeRange", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("db error"))
+
+	weatherUC := usecase.NewWeatherUsecase(new(MockWeatherRuleRepo), &StubUserRuleRepo{}, new(MockNotificationRepo), &StubDeliveryRepo{}, mockUserRepo, new(MockAreaUC), new(MockForecastFetcher), nil, &StubSnapshotRepo{}, newNopNotifier(), usecase.BatchConfig{})
+	start, end := batchTimeRange()
+	result, err := weatherUC.ProcessWeatherForUsersInTimeRange(context.Background(), start, end, usecase.ProcessOptions{})
+	assert.Error(t, err)
+	assert.Nil(t, result)
+}
+
+func TestProcessWeatherForUsersInTimeRange_DryRun(t *testing.T) {
+	ctx := context.Background()
+
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockAreaUC := new(MockAreaUC)
+	mockUserRepo := new(MockUserRepoForRange)
+	mockNotifier := new(MockNotifier)
+
+	users := []*entity.User{
+		{ID: 2, SelectedAreaID: "area2"},
+		{ID: 1, SelectedAreaID: "area1"},
+	}
+	mockUserRepo.On("FindUserByNotifyTimeRange", ctx, mock.Anything, mock.Anything).Return(users, nil)
+	mockAreaUC.On("GetHierarchy", mock.Anything, mock.Anything).Return(&entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "office1"},
+		Class10: &entity.AreaClass10{ID: "office1"},
+	}, nil)
+	mockRuleRepo.On("GetRule", mock.Anything, "100").Return(&entity.WeatherRule{WeatherCode: "100", IsNotifyTrigger: false}, nil)
+
+	snapshotRepo := &StubSnapshotRepo{}
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, mockUserRepo, mockAreaUC, &StubBatchFetcher{}, nil, snapshotRepo, mockNotifier, usecase.BatchConfig{})
+
+	start, end := batchTimeRange()
+	result, err := weatherUC.ProcessWeatherForUsersInTimeRange(ctx, start, end, usecase.ProcessOptions{DryRun: true})
+	require.NoError(t, err)
+
+	assert.True(t, result.DryRun)
+	assert.Equal(t, 2, result.Evaluated)
+	require.Len(t, result.Decisions, 2)
+	assert.Equal(t, 1, result.Decisions[0].UserID)
+	assert.Equal(t, 2, result.Decisions[1].UserID)
+	assert.Equal(t, []string{"100"}, result.Decisions[1].WeatherCodes)
+	assert.Empty(t, result.Decisions[1].MatchedCodes)
+
+	mockNotifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything, mock.Anything)
+	mockNotificationRepo.AssertNotCalled(t, "InsertNotificationHistory", mock.Anything, mock.Anything)
+	assert.Empty(t, snapshotRepo.snapshots)
+}
+
+// setupIdempotencyTest は2人のユーザーに雨の予報を通知する WeatherUsecase を作る
+func setupIdempotencyTest(deliveryRepo *StubDeliveryRepo, n *MockNotifier) usecase.WeatherUsecase {
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockAreaUC := new(MockAreaUC)
+	mockUserRepo := new(MockUserRepoForRange)
+	mockFetcher := new(MockForecastFetcher)
+
+	users := []*entity.User{{ID: 1, SelectedAreaID: "1310100"}, {ID: 2, SelectedAreaID: "1310100"}}
+	mockUserRepo.On("FindUserByNotifyTimeRange", mock.Anything, mock.Anything, mock.Anything).Return(users, nil)
+	mockAreaUC.On("GetHierarchy", mock.Anything, "1310100").Return(&entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "130000"},
+		Class10: &entity.AreaClass10{ID: "130010"},
+	}, nil)
+	mockFetcher.On("FetchForecast", mock.Anything, "130000").Return(newTestForecast("130010", "300"), nil)
+	mockRuleRepo.On("GetRule", mock.Anything, "300").Return(&entity.WeatherRule{WeatherCode: "300", IsNotifyTrigger: true}, nil)
+	mockNotificationRepo.
+		On("InsertNotificationHistory", mock.Anything, mock.AnythingOfType("*entity.NotificationHistory")).
+		Return(nil)
+
+	return usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, deliveryRepo, mockUserRepo, mockAreaUC, mockFetcher, nil, &StubSnapshotRepo{}, n, usecase.BatchConfig{})
+}
+
+func TestProcessWeatherForUsersInTimeRange_SkipsDelivered(t *testing.T) {
+	ctx := context.Background()
+	mockNotifier := new(MockNotifier)
+	mockNotifier.On("Notify", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	weatherUC := setupIdempotencyTest(&StubDeliveryRepo{}, mockNotifier)
+
+	start, end := batchTimeRange()
+	first, err := weatherUC.ProcessWeatherForUsersInTimeRange(ctx, start, end, usecase.ProcessOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, first.Notified)
+	assert.Empty(t, first.Duplicates)
+
+	// 時間帯が重なった2回目の実行では通知しない
+	second, err := weatherUC.ProcessWeatherForUsersInTimeRange(ctx, start, end, usecase.ProcessOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, second.Notified)
+	assert.Equal(t, 0, second.Evaluated)
+	assert.Equal(t, []int{1, 2}, second.Duplicates)
+	assert.Empty(t, second.Failures)
+
+	mockNotifier.AssertNumberOfCalls(t, "Notify", 2)
+}
+
+func TestProcessWeatherForUsersInTimeRange_ConcurrentRuns(t *testing.T) {
+	ctx := context.Background()
+	mockNotifier := new(MockNotifier)
+	mockNotifier.On("Notify", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	// 2つのレプリカが同じ DB に対して同時に実行する
+	deliveryRepo := &StubDeliveryRepo{}
+	replicas := []usecase.WeatherUsecase{
+		setupIdempotencyTest(deliveryRepo, mockNotifier),
+		setupIdempotencyTest(deliveryRepo, mockNotifier),
+	}
+
+	start, end := batchTimeRange()
+	results := make([]*usecase.BatchResult, len(replicas))
+	var wg sync.WaitGroup
+	for i, weatherUC := range replicas {
+		wg.Add(1)
+		go func(i int, weatherUC usecase.WeatherUsecase) {
+			defer wg.Done()
+			result, err := weatherUC.ProcessWeatherForUsersInTimeRange(ctx, start, end, usecase.ProcessOptions{})
+			assert.NoError(t, err)
+			results[i] = result
+		}(i, weatherUC)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 2, results[0].Notified+results[1].Notified)
+	assert.Len(t, append(results[0].Duplicates, results[1].Duplicates...), 2)
+	mockNotifier.AssertNumberOfCalls(t, "Notify", 2)
+}
+
+func TestProcessWeatherForUsersInTimeRange_QueuesFailedDelivery(t *testing.T) {
+	ctx := context.Background()
+	mockNotifier := new(MockNotifier)
+	// ユーザー2への通知は1回目だけ失敗する
+	mockNotifier.On("Notify", mock.Anything, mock.MatchedBy(func(u *entity.User) bool { return u.ID == 2 }), mock.Anything).
+		Return(errors.New("line api error")).Once()
+	mockNotifier.On("Notify", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	weatherUC := setupIdempotencyTest(&StubDeliveryRepo{}, mockNotifier)
+
+	start, end := batchTimeRange()
+	first, err := weatherUC.ProcessWeatherForUsersInTimeRange(ctx, start, end, usecase.ProcessOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, first.Notified)
+	assert.Equal(t, 1, first.Queued)
+	assert.Empty(t, first.Failures)
+
+	// 再送は dispatcher に任せ、次の実行では判定し直さない
+	second, err := weatherUC.ProcessWeatherForUsersInTimeRange(ctx, start, end, usecase.ProcessOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, second.Notified)
+	assert.Equal(t, []int{1, 2}, second.Duplicates)
+	mockNotifier.AssertNumberOfCalls(t, "Notify", 2)
+}
+
+func TestProcessWeatherForUsersInTimeRange_DryRunDoesNotClaim(t *testing.T) {
+	ctx := context.Background()
+	weatherUC := setupIdempotencyTest(&StubDeliveryRepo{}, newNopNotifier())
+
+	start, end := batchTimeRange()
+	dryRun, err := weatherUC.ProcessWeatherForUsersInTimeRange(ctx, start, end, usecase.ProcessOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, 2, dryRun.Notified)
+
+	result, err := weatherUC.ProcessWeatherForUsersInTimeRange(ctx, start, end, usecase.ProcessOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Notified)
+	assert.Empty(t, result.Duplicates)
+}
@@ -0,0 +1,162 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/cache"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/repository"
+)
+
+// forecastLoader は1回の処理実行の間だけ予報を area_offices の ID 単位で保持する
+// 同じオフィスへの同時リクエストは1回の取得にまとめ（single-flight）、
+// 取得した予報は forecast_snapshots に1回だけ保存する。snapshotRepo が nil なら保存しない（ドライラン）
+// cache があれば JMA より先に見て、JMA から取得した予報を保存する
+// まとめた取得は最初に呼んだユーザーの ctx ではなく、実行全体の ctx で timeout を上限に行う
+type forecastLoader struct {
+	ctx          context.Context // 実行全体の ctx。キャンセルされたら取得中のリクエストもキャンセルする
+	fetcher      jma.ForecastFetcher
+	cache        cache.ForecastCache
+	snapshotRepo repository.ForecastSnapshotRepository
+	timeout      time.Duration
+
+	mu     sync.Mutex
+	calls  map[string]*forecastCall
+	hits   int // キャッシュにあったか、前回の取得から更新されていなかったオフィスの数
+	misses int
+}
+
+// loadedForecast は予報と、その保存先スナップショットの組
+type loadedForecast struct {
+	Forecast   *jma.Forecast
+	SnapshotID int
+}
+
+type forecastCall struct {
+	done   chan struct{}
+	loaded *loadedForecast
+	err    error
+}
+
+func newForecastLoader(ctx context.Context, fetcher jma.ForecastFetcher, forecastCache cache.ForecastCache, snapshotRepo repository.ForecastSnapshotRepository, timeout time.Duration) *forecastLoader {
+	return &forecastLoader{
+		ctx:          ctx,
+		fetcher:      fetcher,
+		cache:        forecastCache,
+		snapshotRepo: snapshotRepo,
+		timeout:      timeout,
+		calls:        make(map[string]*forecastCall),
+	}
+}
+
+// Load はオフィスの予報を返す。同じオフィスを取得中なら、その結果を待つ
+// ctx は待つ時間の上限にだけ使い、ctx が終わっても取得中のリクエストはキャンセルしない
+// 1人のユーザーのタイムアウトで、同じオフィスを待つ他のユーザーまで失敗させないため
+func (l *forecastLoader) Load(ctx context.Context, officeID string) (*loadedForecast, error) {
+	l.mu.Lock()
+	call, ok := l.calls[officeID]
+	if !ok {
+		call = &forecastCall{done: make(chan struct{})}
+		l.calls[officeID] = call
+		go l.run(officeID, call)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.loaded, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *forecastLoader) run(officeID string, call *forecastCall) {
+	ctx, cancel := context.WithTimeout(l.ctx, l.timeout)
+	defer cancel()
+
+	call.loaded, call.err = l.load(ctx, officeID)
+
+	// 失敗した結果は保持せず、後続のユーザーで再取得させる
+	// JMA へのリクエストを止めているときは、後続のユーザーも同じ結果になるので保持する
+	if call.err != nil && !errors.Is(call.err, jma.ErrCircuitOpen) {
+		l.mu.Lock()
+		delete(l.calls, officeID)
+		l.mu.Unlock()
+	}
+	close(call.done)
+}
+
+func (l *forecastLoader) load(ctx context.Context, officeID string) (*loadedForecast, error) {
+	forecast, cached, err := l.fetch(ctx, officeID)
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	if cached {
+		l.hits++
+	} else {
+		l.misses++
+	}
+	l.mu.Unlock()
+
+	if l.snapshotRepo == nil {
+		return &loadedForecast{Forecast: forecast}, nil
+	}
+
+	snapshot := &entity.ForecastSnapshot{
+		OfficeID:       officeID,
+		ReportDatetime: forecast.ReportDatetime(),
+		Data:           forecast.Raw,
+	}
+	if err := l.snapshotRepo.SaveSnapshot(ctx, snapshot); err != nil {
+		return nil, fmt.Errorf("failed to save forecast snapshot for office %s: %w", officeID, err)
+	}
+
+	return &loadedForecast{Forecast: forecast, SnapshotID: snapshot.ID}, nil
+}
+
+// fetch はキャッシュにある予報を返し、無ければ JMA から取得してキャッシュに保存する
+// キャッシュを使えなくても JMA から取得した予報で処理を続ける
+// 予報がキャッシュにあったか、JMA で前回から更新されていなければ cached を true にする
+func (l *forecastLoader) fetch(ctx context.Context, officeID string) (forecast *jma.Forecast, cached bool, err error) {
+	if l.cache != nil {
+		forecast, err := l.cache.Get(ctx, officeID)
+		if err != nil {
+			fmt.Printf("failed to get cached forecast for office %s: %v\n", officeID, err)
+		} else if forecast != nil {
+			return forecast, true, nil
+		}
+	}
+
+	forecast, err = l.fetcher.FetchForecast(ctx, officeID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch weather data: %w", err)
+	}
+	if l.cache != nil {
+		if err := l.cache.Set(ctx, officeID, forecast); err != nil {
+			fmt.Printf("failed to cache forecast for office %s: %v\n", officeID, err)
+		}
+	}
+	return forecast, forecast.NotModified, nil
+}
+
+// readOnlyForecastCache はキャッシュの予報を読むだけで、Set では何もしない
+type readOnlyForecastCache struct {
+	cache.ForecastCache
+}
+
+func (readOnlyForecastCache) Set(ctx context.Context, officeID string, forecast *jma.Forecast) error {
+	return nil
+}
+
+// cacheStats はキャッシュの予報を使ったオフィスの数と、JMA から取得し直した数を返す
+func (l *forecastLoader) cacheStats() (hits, misses int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.hits, l.misses
+}
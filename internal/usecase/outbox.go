@@ -0,0 +1,159 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/notifier"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/repository"
+	"github.com/Isshinfunada/weather-bot/internal/utils"
+)
+
+const (
+	maxDeliveryAttempts  = 5               // これだけ送れなければ failed にする
+	deliveryRetryBackoff = time.Minute     // 1回目の再送までの間隔。以降は倍にする
+	deliveryLease        = 5 * time.Minute // 送信中の行を他の dispatcher が取らない時間
+	dispatchBatchSize    = 100
+)
+
+// NotificationDispatcher は送れなかった通知を notification_history から再送する
+type NotificationDispatcher interface {
+	DispatchPending(ctx context.Context) (*DispatchResult, error)
+}
+
+// DispatchResult は1回の再送の結果
+type DispatchResult struct {
+	Claimed  int `json:"claimed"`  // 再送しようとした通知の数
+	Sent     int `json:"sent"`     // 送れた数
+	Retrying int `json:"retrying"` // 送れず、後で再送する数
+	Failed   int `json:"failed"`   // 再送の上限に達したか、送り先のユーザーがいない数
+}
+
+type notificationDispatcher struct {
+	notificationRepo repository.NotificationRepository
+	userRepo         repository.UserRepository
+	notifier         notifier.Notifier
+}
+
+func NewNotificationDispatcher(nr repository.NotificationRepository, ur repository.UserRepository, n notifier.Notifier) NotificationDispatcher {
+	return &notificationDispatcher{
+		notificationRepo: nr,
+		userRepo:         ur,
+		notifier:         n,
+	}
+}
+
+// DispatchPending は送信時刻を過ぎた pending の通知が無くなるまで送る
+// 取得した行は deliveryLease の間他の dispatcher に取られないので、複数のプロセスで動かしてもよい
+func (d *notificationDispatcher) DispatchPending(ctx context.Context) (*DispatchResult, error) {
+	result := &DispatchResult{}
+	for {
+		now := time.Now().In(utils.JST)
+		histories, err := d.notificationRepo.ClaimPendingDeliveries(ctx, now, now.Add(deliveryLease), dispatchBatchSize)
+		if err != nil {
+			return result, err
+		}
+		result.Claimed += len(histories)
+
+		for _, h := range histories {
+			if err := ctx.Err(); err != nil {
+				// 残りは lease が切れた後に再送する
+				return result, err
+			}
+			switch d.dispatch(ctx, h) {
+			case entity.DeliveryStatusSent:
+				result.Sent++
+			case entity.DeliveryStatusFailed:
+				result.Failed++
+			default:
+				result.Retrying++
+			}
+		}
+
+		if len(histories) < dispatchBatchSize {
+			return result, nil
+		}
+	}
+}
+
+// dispatch は1件の通知を送り、書き込んだ送信状態を返す
+func (d *notificationDispatcher) dispatch(ctx context.Context, h *entity.NotificationHistory) string {
+	user, err := d.userRepo.FindUserByID(ctx, h.UserID)
+	if err != nil {
+		d.markRetry(ctx, h, fmt.Errorf("failed to get user %d: %w", h.UserID, err))
+		return h.DeliveryStatus
+	}
+	// 退会したユーザーには送らない
+	if user == nil || !user.IsActive {
+		h.DeliveryStatus = entity.DeliveryStatusFailed
+		h.LastError = fmt.Sprintf("user %d not found or inactive", h.UserID)
+		h.NextAttemptAt = nil
+		d.update(ctx, h)
+		return h.DeliveryStatus
+	}
+
+	if err := deliverNotification(ctx, d.notificationRepo, d.notifier, user, h); err != nil {
+		fmt.Printf("failed to redeliver notification %d to user %d (attempt %d): %v\n", h.ID, h.UserID, h.DeliveryAttempts, err)
+	}
+	return h.DeliveryStatus
+}
+
+func (d *notificationDispatcher) markRetry(ctx context.Context, h *entity.NotificationHistory, err error) {
+	h.DeliveryAttempts++
+	markDeliveryFailed(h, err, time.Now().In(utils.JST))
+	d.update(ctx, h)
+}
+
+func (d *notificationDispatcher) update(ctx context.Context, h *entity.NotificationHistory) {
+	if err := d.notificationRepo.UpdateDelivery(context.WithoutCancel(ctx), h); err != nil {
+		fmt.Printf("failed to update delivery of notification %d: %v\n", h.ID, err)
+	}
+}
+
+// pendingHistory は送る文面を持つ pending の履歴にする
+// 送信中に dispatcher が同じ行を取らないよう、deliveryLease の間は再送しない
+func pendingHistory(history *entity.NotificationHistory, message string, now time.Time) {
+	leaseUntil := now.Add(deliveryLease)
+	history.Message = message
+	history.DeliveryStatus = entity.DeliveryStatusPending
+	history.NextAttemptAt = &leaseUntil
+}
+
+// deliverNotification は pending の履歴の文面を送り、結果を履歴に書き込む
+// 送れなかったときは送信のエラーを返し、履歴は再送待ちか failed になる
+func deliverNotification(ctx context.Context, nr repository.NotificationRepository, n notifier.Notifier, user *entity.User, history *entity.NotificationHistory) error {
+	sendErr := n.Notify(ctx, user, history.Message)
+
+	now := time.Now().In(utils.JST)
+	history.DeliveryAttempts++
+	if sendErr == nil {
+		history.DeliveryStatus = entity.DeliveryStatusSent
+		history.LastError = ""
+		history.NextAttemptAt = nil
+		history.DeliveredAt = &now
+	} else {
+		markDeliveryFailed(history, sendErr, now)
+	}
+
+	// ユーザーごとのタイムアウトを過ぎていても送信状態を残す
+	// 送れたのに書き込めなかった行は lease が切れた後に再送されることがある
+	if err := nr.UpdateDelivery(context.WithoutCancel(ctx), history); err != nil {
+		fmt.Printf("failed to update delivery of notification %d: %v\n", history.ID, err)
+	}
+	return sendErr
+}
+
+// markDeliveryFailed は送れなかった履歴を再送待ちにする。上限に達していれば failed にする
+func markDeliveryFailed(history *entity.NotificationHistory, err error, now time.Time) {
+	history.LastError = err.Error()
+	if history.DeliveryAttempts >= maxDeliveryAttempts {
+		history.DeliveryStatus = entity.DeliveryStatusFailed
+		history.NextAttemptAt = nil
+		return
+	}
+	next := now.Add(deliveryRetryBackoff << (history.DeliveryAttempts - 1))
+	history.DeliveryStatus = entity.DeliveryStatusPending
+	history.NextAttemptAt = &next
+}
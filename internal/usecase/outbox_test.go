@@ -0,0 +1,75 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatchPending(t *testing.T) {
+	ctx := context.Background()
+	user := &entity.User{ID: 1, IsActive: true}
+
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockNotificationRepo.On("ClaimPendingDeliveries", ctx, mock.Anything, mock.Anything, 100).Return([]*entity.NotificationHistory{
+		{ID: 10, UserID: 1, Message: "雨", DeliveryStatus: entity.DeliveryStatusPending, DeliveryAttempts: 1},
+		{ID: 11, UserID: 1, Message: "晴れ", DeliveryStatus: entity.DeliveryStatusPending, DeliveryAttempts: 2},
+		{ID: 12, UserID: 1, Message: "雪", DeliveryStatus: entity.DeliveryStatusPending, DeliveryAttempts: 4},
+		{ID: 13, UserID: 2, Message: "雨", DeliveryStatus: entity.DeliveryStatusPending, DeliveryAttempts: 1},
+	}, nil)
+	mockNotifier := new(MockNotifier)
+	mockNotifier.On("Notify", mock.Anything, user, "雨").Return(nil)
+	mockNotifier.On("Notify", mock.Anything, user, "晴れ").Return(errors.New("line api error"))
+	mockNotifier.On("Notify", mock.Anything, user, "雪").Return(errors.New("line api error"))
+
+	dispatcher := usecase.NewNotificationDispatcher(mockNotificationRepo, &StubUserRepo{user: user}, mockNotifier)
+	result, err := dispatcher.DispatchPending(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, &usecase.DispatchResult{Claimed: 4, Sent: 1, Retrying: 1, Failed: 2}, result)
+
+	updates := mockNotificationRepo.Updates()
+	require.Len(t, updates, 4)
+
+	assert.Equal(t, entity.DeliveryStatusSent, updates[0].DeliveryStatus)
+	assert.Equal(t, 2, updates[0].DeliveryAttempts)
+	assert.NotNil(t, updates[0].DeliveredAt)
+	assert.Nil(t, updates[0].NextAttemptAt)
+
+	// 再送の間隔は回数ごとに倍にする
+	assert.Equal(t, entity.DeliveryStatusPending, updates[1].DeliveryStatus)
+	assert.Equal(t, 3, updates[1].DeliveryAttempts)
+	assert.Equal(t, "line api error", updates[1].LastError)
+	require.NotNil(t, updates[1].NextAttemptAt)
+	assert.WithinDuration(t, time.Now().Add(4*time.Minute), *updates[1].NextAttemptAt, 10*time.Second)
+
+	// 上限に達したら再送しない
+	assert.Equal(t, entity.DeliveryStatusFailed, updates[2].DeliveryStatus)
+	assert.Equal(t, 5, updates[2].DeliveryAttempts)
+	assert.Nil(t, updates[2].NextAttemptAt)
+
+	// 送り先のユーザーがいない
+	assert.Equal(t, 13, updates[3].ID)
+	assert.Equal(t, entity.DeliveryStatusFailed, updates[3].DeliveryStatus)
+	mockNotifier.AssertNumberOfCalls(t, "Notify", 3)
+}
+
+func TestDispatchPending_ClaimError(t *testing.T) {
+	ctx := context.Background()
+
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockNotificationRepo.On("ClaimPendingDeliveries", ctx, mock.Anything, mock.Anything, 100).Return(nil, errors.New("db error"))
+	mockNotifier := new(MockNotifier)
+
+	dispatcher := usecase.NewNotificationDispatcher(mockNotificationRepo, &StubUserRepo{}, mockNotifier)
+	_, err := dispatcher.DispatchPending(ctx)
+	assert.Error(t, err)
+	mockNotifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything, mock.Anything)
+}
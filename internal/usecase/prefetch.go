@@ -0,0 +1,114 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/cache"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/repository"
+)
+
+// ForecastPrefetcher は通知の前に予報を取得しておき、通知の処理で JMA に取りに行かずに済むようにする
+type ForecastPrefetcher interface {
+	Prefetch(ctx context.Context) (*PrefetchResult, error)
+}
+
+// PrefetchResult は予報を取得しておいた結果
+type PrefetchResult struct {
+	Offices    int               `json:"offices"` // 有効なユーザーがいるオフィスの数
+	Fetched    int               `json:"fetched"` // 取得して保存できた数
+	Failed     int               `json:"failed"`
+	Failures   map[string]string `json:"failures"` // オフィスの ID → 失敗の理由
+	DurationMs int64             `json:"durationMs"`
+}
+
+// Err は失敗したオフィスのエラーを1つにまとめる。失敗が無ければ nil
+func (r *PrefetchResult) Err() error {
+	if r.Failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("failed to prefetch forecasts for %d of %d offices", r.Failed, r.Offices)
+}
+
+type forecastPrefetcher struct {
+	areaRepo        repository.AreaRepository
+	forecastFetcher jma.ForecastFetcher
+	forecastCache   cache.ForecastCache
+	snapshotRepo    repository.ForecastSnapshotRepository
+	batchConfig     BatchConfig
+}
+
+// NewForecastPrefetcher は取得した予報を fc と forecast_snapshots に保存する ForecastPrefetcher を返す
+// fc が nil ならスナップショットだけを保存する
+// オフィスを bc.Workers 並列で取得し、1オフィスの取得には bc.UserTimeout を上限にする
+func NewForecastPrefetcher(ar repository.AreaRepository, ff jma.ForecastFetcher, fc cache.ForecastCache, sr repository.ForecastSnapshotRepository, bc BatchConfig) ForecastPrefetcher {
+	return &forecastPrefetcher{
+		areaRepo:        ar,
+		forecastFetcher: ff,
+		forecastCache:   fc,
+		snapshotRepo:    sr,
+		batchConfig:     bc,
+	}
+}
+
+// Prefetch は有効なユーザーがいる全オフィスの予報を取得して保存する
+// オフィスごとの失敗は PrefetchResult に集め、error はオフィスの取得に失敗したときだけ返す
+func (p *forecastPrefetcher) Prefetch(ctx context.Context) (*PrefetchResult, error) {
+	startedAt := time.Now()
+	officeIDs, err := p.areaRepo.FindActiveOfficeIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PrefetchResult{Offices: len(officeIDs), Failures: map[string]string{}}
+	var mu sync.Mutex
+	record := func(officeID string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			result.Failed++
+			result.Failures[officeID] = err.Error()
+			return
+		}
+		result.Fetched++
+	}
+
+	runParallel(ctx, p.batchConfig, officeIDs,
+		func(ctx context.Context, officeID string) {
+			record(officeID, p.prefetchOffice(ctx, officeID))
+		},
+		record,
+	)
+
+	result.DurationMs = time.Since(startedAt).Milliseconds()
+	return result, nil
+}
+
+// prefetchOffice はキャッシュを見ずに JMA から予報を取得し、キャッシュとスナップショットに保存する
+func (p *forecastPrefetcher) prefetchOffice(ctx context.Context, officeID string) error {
+	forecast, err := p.forecastFetcher.FetchForecast(ctx, officeID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch weather data: %w", err)
+	}
+
+	var errs []error
+	if p.forecastCache != nil {
+		if err := p.forecastCache.Set(ctx, officeID, forecast); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	snapshot := &entity.ForecastSnapshot{
+		OfficeID:       officeID,
+		ReportDatetime: forecast.ReportDatetime(),
+		Data:           forecast.Raw,
+	}
+	if err := p.snapshotRepo.SaveSnapshot(ctx, snapshot); err != nil {
+		errs = append(errs, fmt.Errorf("failed to save forecast snapshot for office %s: %w", officeID, err))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,115 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/cache"
+	"github.com/Isshinfunada/weather-bot/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefetch(t *testing.T) {
+	ctx := context.Background()
+
+	mockAreaRepo := new(MockAreaRepo)
+	mockAreaRepo.On("FindActiveOfficeIDs", ctx).Return([]string{"office1", "office2", "office3"}, nil)
+	fetcher := &StubBatchFetcher{delay: 20 * time.Millisecond}
+	forecastCache := cache.NewMemoryForecastCache(0, time.Hour)
+	snapshotRepo := &StubSnapshotRepo{}
+
+	prefetcher := usecase.NewForecastPrefetcher(mockAreaRepo, fetcher, forecastCache, snapshotRepo, usecase.BatchConfig{Workers: 2})
+	result, err := prefetcher.Prefetch(ctx)
+	require.NoError(t, err)
+	require.NoError(t, result.Err())
+
+	assert.Equal(t, 3, result.Offices)
+	assert.Equal(t, 3, result.Fetched)
+	assert.Empty(t, result.Failures)
+	assert.Equal(t, 3, fetcher.calls)
+	assert.Equal(t, 2, fetcher.maxInFlight)
+	assert.Len(t, snapshotRepo.snapshots, 3)
+	for _, officeID := range []string{"office1", "office2", "office3"} {
+		cached, err := forecastCache.Get(ctx, officeID)
+		require.NoError(t, err)
+		assert.NotNil(t, cached, officeID)
+	}
+}
+
+func TestPrefetch_WarmsCacheForNotifications(t *testing.T) {
+	ctx := context.Background()
+
+	mockAreaRepo := new(MockAreaRepo)
+	mockAreaRepo.On("FindActiveOfficeIDs", ctx).Return([]string{"office1", "office2"}, nil)
+	fetcher := &StubBatchFetcher{}
+	forecastCache := cache.NewMemoryForecastCache(0, time.Hour)
+
+	prefetcher := usecase.NewForecastPrefetcher(mockAreaRepo, fetcher, forecastCache, &StubSnapshotRepo{}, usecase.BatchConfig{})
+	_, err := prefetcher.Prefetch(ctx)
+	require.NoError(t, err)
+
+	// 通知の処理はキャッシュの予報を使い、JMA には取りに行かない
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockAreaUC := new(MockAreaUC)
+	mockUserRepo := new(MockUserRepoForRange)
+	mockNotificationRepo := new(MockNotificationRepo)
+	users := []*entity.User{{ID: 1, SelectedAreaID: "area1"}, {ID: 2, SelectedAreaID: "area2"}}
+	mockUserRepo.On("FindUserByNotifyTimeRange", ctx, mock.Anything, mock.Anything).Return(users, nil)
+	for _, id := range []string{"1", "2"} {
+		mockAreaUC.On("GetHierarchy", mock.Anything, "area"+id).Return(&entity.HierarchyArea{
+			Office:  &entity.AreaOffice{ID: "office" + id},
+			Class10: &entity.AreaClass10{ID: "office" + id},
+		}, nil)
+	}
+	mockRuleRepo.On("GetRule", mock.Anything, "100").Return(&entity.WeatherRule{WeatherCode: "100", IsNotifyTrigger: false}, nil)
+	mockNotificationRepo.
+		On("InsertNotificationHistory", mock.Anything, mock.AnythingOfType("*entity.NotificationHistory")).
+		Return(nil)
+
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, mockUserRepo, mockAreaUC, fetcher, forecastCache, &StubSnapshotRepo{}, newNopNotifier(), usecase.BatchConfig{})
+	start, end := batchTimeRange()
+	result, err := weatherUC.ProcessWeatherForUsersInTimeRange(ctx, start, end, usecase.ProcessOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Evaluated)
+	assert.Equal(t, 2, result.ForecastCacheHits)
+	assert.Equal(t, 0, result.ForecastCacheMisses)
+	assert.Equal(t, 2, fetcher.calls)
+}
+
+func TestPrefetch_CollectsFailures(t *testing.T) {
+	ctx := context.Background()
+
+	mockAreaRepo := new(MockAreaRepo)
+	mockAreaRepo.On("FindActiveOfficeIDs", ctx).Return([]string{"130000", "140000"}, nil)
+	mockFetcher := new(MockForecastFetcher)
+	mockFetcher.On("FetchForecast", mock.Anything, "130000").Return(newTestForecast("130010", "100"), nil)
+	mockFetcher.On("FetchForecast", mock.Anything, "140000").Return(nil, errors.New("unexpected status"))
+	snapshotRepo := &StubSnapshotRepo{}
+
+	// キャッシュが無ければスナップショットだけを保存する
+	prefetcher := usecase.NewForecastPrefetcher(mockAreaRepo, mockFetcher, nil, snapshotRepo, usecase.BatchConfig{})
+	result, err := prefetcher.Prefetch(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, result.Fetched)
+	assert.Equal(t, 1, result.Failed)
+	assert.Contains(t, result.Failures["140000"], "unexpected status")
+	assert.Error(t, result.Err())
+	require.Len(t, snapshotRepo.snapshots, 1)
+	assert.Equal(t, "130000", snapshotRepo.snapshots[0].OfficeID)
+}
+
+func TestPrefetch_FindOfficesError(t *testing.T) {
+	mockAreaRepo := new(MockAreaRepo)
+	mockAreaRepo.On("FindActiveOfficeIDs", mock.Anything).Return(nil, errors.New("db error"))
+
+	prefetcher := usecase.NewForecastPrefetcher(mockAreaRepo, new(MockForecastFetcher), nil, &StubSnapshotRepo{}, usecase.BatchConfig{})
+	result, err := prefetcher.Prefetch(context.Background())
+	assert.Error(t, err)
+	assert.Nil(t, result)
+}
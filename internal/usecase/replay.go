@@ -0,0 +1,189 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+	"github.com/Isshinfunada/weather-bot/internal/utils"
+)
+
+// ReplayReport は保存済みの予報で判定し直した結果
+type ReplayReport struct {
+	From      time.Time    `json:"from"`
+	To        time.Time    `json:"to"`
+	Replayed  int          `json:"replayed"`  // 判定し直した履歴の数
+	Unchanged int          `json:"unchanged"` // 元の判定と同じだった数
+	Changed   int          `json:"changed"`   // 元の判定と異なった数
+	Skipped   int          `json:"skipped"`   // 判定し直せなかった数
+	Diffs     []ReplayDiff `json:"diffs"`
+	Skips     []ReplaySkip `json:"skips"`
+}
+
+// ReplayDiff は元の判定と異なった履歴
+type ReplayDiff struct {
+	HistoryID        int       `json:"historyId"`
+	UserID           int       `json:"userId"`
+	NotificationTime time.Time `json:"notificationTime"`
+	TargetDate       string    `json:"targetDate"`
+	Original         bool      `json:"original"` // 元の is_notify_trigger
+	Replayed         bool      `json:"replayed"` // 今のルールとパーサーでの判定
+	OriginalCodes    []string  `json:"originalCodes"`
+	WeatherCodes     []string  `json:"weatherCodes"`
+	MatchedCodes     []string  `json:"matchedCodes"`
+}
+
+// ReplaySkip は判定し直せなかった履歴と理由
+type ReplaySkip struct {
+	HistoryID int    `json:"historyId"`
+	UserID    int    `json:"userId"`
+	Reason    string `json:"reason"`
+}
+
+// ReplayEvaluations は通知時刻が [from, to) の天気の判定を、保存済みの予報と今のルールで判定し直す
+// 予報はスナップショット、無ければ weather_data から読み込む
+// 地域や時間帯などのユーザー設定は今の値を使うので、設定を変えたユーザーの差分も含まれる
+func (u *weatherUsecase) ReplayEvaluations(ctx context.Context, from, to time.Time) (*ReplayReport, error) {
+	histories, err := u.notificationRepo.FindEvaluationsInRange(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReplayReport{From: from, To: to, Diffs: []ReplayDiff{}, Skips: []ReplaySkip{}}
+	r := &replayer{
+		u:         u,
+		forecasts: make(map[int]*jma.Forecast),
+		users:     make(map[int]*replayUser),
+	}
+	for _, h := range histories {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		decision, targetDate, err := r.replay(ctx, h)
+		if err != nil {
+			report.Skipped++
+			report.Skips = append(report.Skips, ReplaySkip{HistoryID: h.ID, UserID: h.UserID, Reason: err.Error()})
+			continue
+		}
+
+		report.Replayed++
+		if decision.Notify == h.IsNotifyTrigger {
+			report.Unchanged++
+			continue
+		}
+		report.Changed++
+		report.Diffs = append(report.Diffs, ReplayDiff{
+			HistoryID:        h.ID,
+			UserID:           h.UserID,
+			NotificationTime: h.NotificationTime,
+			TargetDate:       targetDate.Format("2006-01-02"),
+			Original:         h.IsNotifyTrigger,
+			Replayed:         decision.Notify,
+			OriginalCodes:    h.WeatherCodes,
+			WeatherCodes:     decision.WeatherCodes,
+			MatchedCodes:     decision.MatchedCodes,
+		})
+	}
+	return report, nil
+}
+
+// replayer は1回の再判定の間だけ予報とユーザーを保持する
+type replayer struct {
+	u         *weatherUsecase
+	forecasts map[int]*jma.Forecast // スナップショットID → 予報
+	users     map[int]*replayUser
+}
+
+type replayUser struct {
+	user      *entity.User
+	class10ID string
+	rules     *userRuleSet
+}
+
+func (r *replayer) replay(ctx context.Context, h *entity.NotificationHistory) (*weatherDecision, time.Time, error) {
+	ru, err := r.user(ctx, h.UserID)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	forecast, err := r.forecast(ctx, h)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	// target_date の無い古い履歴は通知した日の予報を判定していた
+	targetDate := h.TargetDate
+	if targetDate.IsZero() {
+		t := h.NotificationTime.In(utils.JST)
+		targetDate = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, utils.JST)
+	} else {
+		targetDate = time.Date(targetDate.Year(), targetDate.Month(), targetDate.Day(), 0, 0, 0, 0, utils.JST)
+	}
+
+	// 地域を変えたユーザーは予報に今の地域が含まれない
+	if len(forecast.WeatherCodeBlocks(ru.class10ID, targetDate)) == 0 {
+		return nil, time.Time{}, fmt.Errorf("no forecast for area %s on %s", ru.class10ID, targetDate.Format("2006-01-02"))
+	}
+
+	return r.u.evaluate(ctx, ru.user, ru.rules, forecast, ru.class10ID, targetDate), targetDate, nil
+}
+
+func (r *replayer) user(ctx context.Context, userID int) (*replayUser, error) {
+	if ru, ok := r.users[userID]; ok {
+		return ru, nil
+	}
+
+	user, err := r.u.userRepo.FindUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user %d: %w", userID, err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %d not found", userID)
+	}
+	hierarchy, err := r.u.areaUC.GetHierarchy(ctx, fmt.Sprint(user.SelectedAreaID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hierarchy for user %d: %w", userID, err)
+	}
+	if hierarchy == nil {
+		return nil, fmt.Errorf("no hierarchy found %s for user %d", user.SelectedAreaID, userID)
+	}
+	rules, err := r.u.loadUserRuleSet(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ru := &replayUser{user: user, class10ID: hierarchy.Class10.ID, rules: rules}
+	r.users[userID] = ru
+	return ru, nil
+}
+
+// forecast は履歴の判定に使った予報を読み込む
+func (r *replayer) forecast(ctx context.Context, h *entity.NotificationHistory) (*jma.Forecast, error) {
+	if h.ForecastSnapshotID == nil {
+		if len(h.WeatherData) == 0 {
+			return nil, fmt.Errorf("no stored forecast")
+		}
+		return jma.ParseForecast(h.WeatherData)
+	}
+
+	id := *h.ForecastSnapshotID
+	if forecast, ok := r.forecasts[id]; ok {
+		return forecast, nil
+	}
+	snapshot, err := r.u.snapshotRepo.FindSnapshotByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		return nil, fmt.Errorf("forecast snapshot %d not found", id)
+	}
+	forecast, err := jma.ParseForecast(snapshot.Data)
+	if err != nil {
+		return nil, err
+	}
+	r.forecasts[id] = forecast
+	return forecast, nil
+}
@@ -0,0 +1,115 @@
+package usecase_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/usecase"
+	"github.com/Isshinfunada/weather-bot/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// testForecastPayload は newTestForecast を保存済みの JSON にする
+func testForecastPayload(t *testing.T, class10ID string, codes ...string) []byte {
+	body, err := json.Marshal(newTestForecast(class10ID, codes...).Reports)
+	require.NoError(t, err)
+	return body
+}
+
+func TestReplayEvaluations(t *testing.T) {
+	ctx := context.Background()
+
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockAreaUC := new(MockAreaUC)
+
+	now := time.Now().In(utils.JST)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, utils.JST)
+	from, to := today, today.AddDate(0, 0, 1)
+
+	snapshotRepo := &StubSnapshotRepo{}
+	require.NoError(t, snapshotRepo.SaveSnapshot(ctx, &entity.ForecastSnapshot{Data: testForecastPayload(t, "130010", "300")}))
+	snapshotID, missingID := 1, 99
+
+	histories := []*entity.NotificationHistory{
+		// 当時は通知対象外だったが、今のルールでは通知する
+		{ID: 1, UserID: 1, NotificationTime: now, TargetDate: today, IsNotifyTrigger: false, WeatherCodes: []string{"300"}, ForecastSnapshotID: &snapshotID},
+		{ID: 2, UserID: 1, NotificationTime: now, TargetDate: today, IsNotifyTrigger: true, WeatherCodes: []string{"300"}, ForecastSnapshotID: &snapshotID},
+		// スナップショット導入前の行は weather_data を使い、target_date が無ければ通知した日を判定する
+		{ID: 3, UserID: 1, NotificationTime: now, IsNotifyTrigger: false, WeatherCodes: []string{"100"}, WeatherData: testForecastPayload(t, "130010", "100")},
+		{ID: 4, UserID: 1, NotificationTime: now, TargetDate: today},
+		{ID: 5, UserID: 2, NotificationTime: now, TargetDate: today, ForecastSnapshotID: &snapshotID},
+		{ID: 6, UserID: 1, NotificationTime: now, TargetDate: today, ForecastSnapshotID: &missingID},
+	}
+	mockNotificationRepo.On("FindEvaluationsInRange", ctx, from, to).Return(histories, nil)
+	mockAreaUC.On("GetHierarchy", ctx, "1310100").Return(&entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "130000"},
+		Class10: &entity.AreaClass10{ID: "130010"},
+	}, nil).Once()
+	mockRuleRepo.On("GetRule", ctx, "100").Return(&entity.WeatherRule{WeatherCode: "100", IsNotifyTrigger: false}, nil)
+	mockRuleRepo.On("GetRule", ctx, "300").Return(&entity.WeatherRule{WeatherCode: "300", IsNotifyTrigger: true}, nil)
+
+	user := &entity.User{ID: 1, SelectedAreaID: "1310100"}
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, &StubUserRepo{user: user}, mockAreaUC, new(MockForecastFetcher), nil, snapshotRepo, new(MockNotifier), usecase.BatchConfig{})
+
+	report, err := weatherUC.ReplayEvaluations(ctx, from, to)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, report.Replayed)
+	assert.Equal(t, 2, report.Unchanged)
+	assert.Equal(t, 1, report.Changed)
+	require.Len(t, report.Diffs, 1)
+	diff := report.Diffs[0]
+	assert.Equal(t, 1, diff.HistoryID)
+	assert.False(t, diff.Original)
+	assert.True(t, diff.Replayed)
+	assert.Equal(t, today.Format("2006-01-02"), diff.TargetDate)
+	assert.Equal(t, []string{"300"}, diff.MatchedCodes)
+
+	assert.Equal(t, 3, report.Skipped)
+	require.Len(t, report.Skips, 3)
+	assert.Equal(t, 4, report.Skips[0].HistoryID)
+	assert.Contains(t, report.Skips[0].Reason, "no stored forecast")
+	assert.Equal(t, 5, report.Skips[1].HistoryID)
+	assert.Contains(t, report.Skips[1].Reason, "user 2 not found")
+	assert.Equal(t, 6, report.Skips[2].HistoryID)
+	assert.Contains(t, report.Skips[2].Reason, "snapshot 99 not found")
+
+	// 階層情報はユーザーごとに1回だけ引く
+	mockAreaUC.AssertExpectations(t)
+	mockNotificationRepo.AssertNotCalled(t, "InsertNotificationHistory", mock.Anything, mock.Anything)
+}
+
+func TestReplayEvaluations_AreaChanged(t *testing.T) {
+	ctx := context.Background()
+
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockAreaUC := new(MockAreaUC)
+
+	now := time.Now().In(utils.JST)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, utils.JST)
+
+	// 予報は大阪のものだが、ユーザーは東京に地域を変えている
+	histories := []*entity.NotificationHistory{
+		{ID: 1, UserID: 1, NotificationTime: now, TargetDate: today, WeatherData: testForecastPayload(t, "270000", "300")},
+	}
+	mockNotificationRepo.On("FindEvaluationsInRange", ctx, mock.Anything, mock.Anything).Return(histories, nil)
+	mockAreaUC.On("GetHierarchy", ctx, "1310100").Return(&entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "130000"},
+		Class10: &entity.AreaClass10{ID: "130010"},
+	}, nil)
+
+	user := &entity.User{ID: 1, SelectedAreaID: "1310100"}
+	weatherUC := usecase.NewWeatherUsecase(new(MockWeatherRuleRepo), &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, &StubUserRepo{user: user}, mockAreaUC, new(MockForecastFetcher), nil, &StubSnapshotRepo{}, new(MockNotifier), usecase.BatchConfig{})
+
+	report, err := weatherUC.ReplayEvaluations(ctx, today, today.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Replayed)
+	require.Len(t, report.Skips, 1)
+	assert.Contains(t, report.Skips[0].Reason, "no forecast for area 130010")
+}
@@ -0,0 +1,154 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+)
+
+// UserRules はユーザーの通知ルールの上書き一覧
+type UserRules struct {
+	UserID    int        `json:"userId"`
+	Overrides []UserRule `json:"overrides"`
+}
+
+// UserRule は1件の上書き。WeatherCode か Category のどちらか一方を指定する
+type UserRule struct {
+	WeatherCode     string `json:"weatherCode,omitempty"`
+	Category        string `json:"category,omitempty"`
+	IsNotifyTrigger bool   `json:"isNotifyTrigger"`
+}
+
+// ListUserRules はユーザーの上書き一覧を返す。ユーザーが居なければ nil
+func (u *weatherUsecase) ListUserRules(ctx context.Context, userID int) (*UserRules, error) {
+	user, err := u.userRepo.FindUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user %d: %w", userID, err)
+	}
+	if user == nil {
+		return nil, nil
+	}
+	return u.userRules(ctx, userID)
+}
+
+// SetUserRule はユーザーの上書きを登録・更新し、更新後の一覧を返す。ユーザーが居なければ nil
+func (u *weatherUsecase) SetUserRule(ctx context.Context, userID int, rule UserRule) (*UserRules, error) {
+	if (rule.WeatherCode == "") == (rule.Category == "") {
+		return nil, fmt.Errorf("either weather code or category is required")
+	}
+
+	user, err := u.userRepo.FindUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user %d: %w", userID, err)
+	}
+	if user == nil {
+		return nil, nil
+	}
+
+	if err := u.userRuleRepo.SaveUserWeatherRule(ctx, &entity.UserWeatherRule{
+		UserID:          userID,
+		WeatherCode:     rule.WeatherCode,
+		Category:        rule.Category,
+		IsNotifyTrigger: rule.IsNotifyTrigger,
+	}); err != nil {
+		return nil, err
+	}
+	return u.userRules(ctx, userID)
+}
+
+// ResetUserRules はユーザーの上書きを削除して共通のルールに戻し、残りの一覧を返す
+// weatherCode か category を指定するとその上書きだけ、どちらも空なら全て削除する。ユーザーが居なければ nil
+func (u *weatherUsecase) ResetUserRules(ctx context.Context, userID int, weatherCode, category string) (*UserRules, error) {
+	user, err := u.userRepo.FindUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user %d: %w", userID, err)
+	}
+	if user == nil {
+		return nil, nil
+	}
+
+	if err := u.userRuleRepo.DeleteUserWeatherRules(ctx, userID, weatherCode, category); err != nil {
+		return nil, err
+	}
+	return u.userRules(ctx, userID)
+}
+
+func (u *weatherUsecase) userRules(ctx context.Context, userID int) (*UserRules, error) {
+	overrides, err := u.userRuleRepo.FindUserWeatherRules(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &UserRules{UserID: userID, Overrides: make([]UserRule, 0, len(overrides))}
+	for _, o := range overrides {
+		rules.Overrides = append(rules.Overrides, UserRule{
+			WeatherCode:     o.WeatherCode,
+			Category:        o.Category,
+			IsNotifyTrigger: o.IsNotifyTrigger,
+		})
+	}
+	return rules, nil
+}
+
+// userRuleSet はユーザーの上書きを天気コードとカテゴリで引けるようにしたもの
+type userRuleSet struct {
+	byCode     map[string]bool
+	byCategory map[string]bool
+}
+
+func (u *weatherUsecase) loadUserRuleSet(ctx context.Context, userID int) (*userRuleSet, error) {
+	overrides, err := u.userRuleRepo.FindUserWeatherRules(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get weather rules for user %d: %w", userID, err)
+	}
+
+	set := &userRuleSet{byCode: make(map[string]bool), byCategory: make(map[string]bool)}
+	for _, o := range overrides {
+		switch {
+		case o.WeatherCode != "":
+			set.byCode[o.WeatherCode] = o.IsNotifyTrigger
+		case o.Category != "":
+			set.byCategory[o.Category] = o.IsNotifyTrigger
+		}
+	}
+	return set, nil
+}
+
+// lookup はユーザーの上書きを返す。天気コードの上書きを最優先にする
+// カテゴリは当てはまるものを1つでも購読していれば通知し、停止しているものだけなら通知しない
+func (s *userRuleSet) lookup(rule *entity.WeatherRule) (trigger bool, ok bool) {
+	if s == nil {
+		return false, false
+	}
+	if trigger, ok := s.byCode[rule.WeatherCode]; ok {
+		return trigger, true
+	}
+	for _, category := range rule.Categories() {
+		if t, found := s.byCategory[category]; found {
+			if t {
+				return true, true
+			}
+			ok = true
+		}
+	}
+	return false, ok
+}
+
+// isNotifyTrigger は天気コードのルールと、そのコードが通知対象かを返す
+// ユーザーの上書きがあればそれを使い、無ければ共通のルールに従う
+// 共通のルールに無いコードでも、天気コードの上書きがあればルール無しで判定する
+func (u *weatherUsecase) isNotifyTrigger(ctx context.Context, weatherCode string, rules *userRuleSet) (*entity.WeatherRule, bool, error) {
+	rule, err := u.weatherRuleRepo.GetRule(ctx, weatherCode)
+	if err != nil {
+		if trigger, ok := rules.lookup(&entity.WeatherRule{WeatherCode: weatherCode}); ok {
+			return nil, trigger, nil
+		}
+		return nil, false, err
+	}
+
+	if trigger, ok := rules.lookup(rule); ok {
+		return rule, trigger, nil
+	}
+	return rule, rule.IsNotifyTrigger, nil
+}
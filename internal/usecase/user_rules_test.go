@@ -0,0 +1,216 @@
+package usecase_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// StubUserRuleRepo はユーザーの上書きをメモリに保持するスタブです
+type StubUserRuleRepo struct {
+	mu    sync.Mutex
+	rules []*entity.UserWeatherRule
+}
+
+func (s *StubUserRuleRepo) FindUserWeatherRules(ctx context.Context, userID int) ([]*entity.UserWeatherRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rules []*entity.UserWeatherRule
+	for _, r := range s.rules {
+		if r.UserID == userID {
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+func (s *StubUserRuleRepo) SaveUserWeatherRule(ctx context.Context, rule *entity.UserWeatherRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.rules {
+		if r.UserID == rule.UserID && r.WeatherCode == rule.WeatherCode && r.Category == rule.Category {
+			r.IsNotifyTrigger = rule.IsNotifyTrigger
+			return nil
+		}
+	}
+	s.rules = append(s.rules, rule)
+	return nil
+}
+
+func (s *StubUserRuleRepo) DeleteUserWeatherRules(ctx context.Context, userID int, weatherCode, category string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.rules[:0]
+	for _, r := range s.rules {
+		matched := r.UserID == userID &&
+			(weatherCode == "" || r.WeatherCode == weatherCode) &&
+			(category == "" || r.Category == category)
+		if !matched {
+			kept = append(kept, r)
+		}
+	}
+	s.rules = kept
+	return nil
+}
+
+func TestProcessWeatherForUser_UserRules(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       string
+		overrides  []*entity.UserWeatherRule
+		wantNotify bool
+	}{
+		{name: "global rule", code: "200", wantNotify: false},
+		{name: "code override", code: "200", overrides: []*entity.UserWeatherRule{
+			{UserID: 1, WeatherCode: "200", IsNotifyTrigger: true},
+		}, wantNotify: true},
+		{name: "category override", code: "300", overrides: []*entity.UserWeatherRule{
+			{UserID: 1, Category: entity.WeatherCategoryRain, IsNotifyTrigger: false},
+		}, wantNotify: false},
+		{name: "code override wins over category", code: "300", overrides: []*entity.UserWeatherRule{
+			{UserID: 1, Category: entity.WeatherCategoryRain, IsNotifyTrigger: false},
+			{UserID: 1, WeatherCode: "300", IsNotifyTrigger: true},
+		}, wantNotify: true},
+		{name: "cloudy category", code: "200", overrides: []*entity.UserWeatherRule{
+			{UserID: 1, Category: entity.WeatherCategoryCloudy, IsNotifyTrigger: true},
+		}, wantNotify: true},
+		{name: "fog subscription", code: "209", overrides: []*entity.UserWeatherRule{
+			{UserID: 1, Category: entity.WeatherCategoryFog, IsNotifyTrigger: true},
+		}, wantNotify: true},
+		// 雨を停止していても雷を購読していれば雷雨は通知する
+		{name: "thunder subscription wins over muted rain", code: "350", overrides: []*entity.UserWeatherRule{
+			{UserID: 1, Category: entity.WeatherCategoryRain, IsNotifyTrigger: false},
+			{UserID: 1, Category: entity.WeatherCategoryThunder, IsNotifyTrigger: true},
+		}, wantNotify: true},
+		{name: "muted rain and thunder", code: "350", overrides: []*entity.UserWeatherRule{
+			{UserID: 1, Category: entity.WeatherCategoryRain, IsNotifyTrigger: false},
+			{UserID: 1, Category: entity.WeatherCategoryThunder, IsNotifyTrigger: false},
+		}, wantNotify: false},
+		{name: "other user's override", code: "200", overrides: []*entity.UserWeatherRule{
+			{UserID: 2, WeatherCode: "200", IsNotifyTrigger: true},
+		}, wantNotify: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			mockRuleRepo := new(MockWeatherRuleRepo)
+			mockNotificationRepo := new(MockNotificationRepo)
+			mockAreaUC := new(MockAreaUC)
+			mockFetcher := new(MockForecastFetcher)
+
+			hierarchy := &entity.HierarchyArea{
+				Office:  &entity.AreaOffice{ID: "testOffice"},
+				Class10: &entity.AreaClass10{ID: "testClass10"},
+			}
+			mockAreaUC.On("GetHierarchy", ctx, mock.Anything).Return(hierarchy, nil)
+			mockFetcher.On("FetchForecast", mock.Anything, "testOffice").Return(newTestForecast("testClass10", tt.code), nil)
+			mockRuleRepo.On("GetRule", ctx, "200").Return(&entity.WeatherRule{WeatherCode: "200", IsNotifyTrigger: false}, nil).Maybe()
+			mockRuleRepo.On("GetRule", ctx, "300").Return(&entity.WeatherRule{WeatherCode: "300", IsNotifyTrigger: true, Precipitation: entity.PrecipitationRain}, nil).Maybe()
+			mockRuleRepo.On("GetRule", ctx, "209").Return(&entity.WeatherRule{WeatherCode: "209", IsNotifyTrigger: false, HasFog: true}, nil).Maybe()
+			mockRuleRepo.On("GetRule", ctx, "350").Return(&entity.WeatherRule{WeatherCode: "350", IsNotifyTrigger: true, Precipitation: entity.PrecipitationRain, HasThunder: true}, nil).Maybe()
+
+			inserted := expectInsertHistory(mockNotificationRepo)
+
+			ruleRepo := &StubUserRuleRepo{rules: tt.overrides}
+			weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, ruleRepo, mockNotificationRepo, &StubDeliveryRepo{}, &DummyUserRepo{}, mockAreaUC, mockFetcher, nil, &StubSnapshotRepo{}, newNopNotifier(), usecase.BatchConfig{})
+			_, err := weatherUC.ProcessWeatherForUser(ctx, &entity.User{ID: 1, SelectedAreaID: "1234567"}, usecase.ProcessOptions{})
+			require.NoError(t, err)
+
+			history := inserted.next(t)
+			assert.Equal(t, tt.wantNotify, history.IsNotifyTrigger)
+		})
+	}
+}
+
+func TestProcessWeatherForUser_MostSevereCode(t *testing.T) {
+	ctx := context.Background()
+
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockAreaUC := new(MockAreaUC)
+	mockFetcher := new(MockForecastFetcher)
+	mockNotifier := new(MockNotifier)
+
+	hierarchy := &entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "testOffice"},
+		Class10: &entity.AreaClass10{ID: "testClass10"},
+	}
+	mockAreaUC.On("GetHierarchy", ctx, mock.Anything).Return(hierarchy, nil)
+	// 最初のコードより後のコードの方が深刻
+	mockFetcher.On("FetchForecast", mock.Anything, "testOffice").Return(newTestForecast("testClass10", "202", "350", "300"), nil)
+	mockRuleRepo.On("GetRule", ctx, "202").Return(&entity.WeatherRule{WeatherCode: "202", WeatherDescription: "曇一時雨", IsNotifyTrigger: true, Severity: 1}, nil)
+	mockRuleRepo.On("GetRule", ctx, "350").Return(&entity.WeatherRule{WeatherCode: "350", WeatherDescription: "雨で雷を伴う", IsNotifyTrigger: true, Severity: 3}, nil)
+	mockRuleRepo.On("GetRule", ctx, "300").Return(&entity.WeatherRule{WeatherCode: "300", WeatherDescription: "雨", IsNotifyTrigger: true, Severity: 2}, nil)
+	mockNotificationRepo.
+		On("InsertNotificationHistory", mock.Anything, mock.AnythingOfType("*entity.NotificationHistory")).
+		Return(nil)
+
+	user := &entity.User{ID: 1, SelectedAreaID: "1234567"}
+	mockNotifier.On("Notify", ctx, user, mock.MatchedBy(func(message string) bool {
+		return strings.Contains(message, "「雨で雷を伴う」の予報です")
+	})).Return(nil).Once()
+
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, &DummyUserRepo{}, mockAreaUC, mockFetcher, nil, &StubSnapshotRepo{}, mockNotifier, usecase.BatchConfig{})
+	_, err := weatherUC.ProcessWeatherForUser(ctx, user, usecase.ProcessOptions{})
+	require.NoError(t, err)
+
+	mockNotifier.AssertExpectations(t)
+	mockRuleRepo.AssertExpectations(t)
+}
+
+func TestUserRules_SetAndReset(t *testing.T) {
+	ctx := context.Background()
+
+	user := &entity.User{ID: 1}
+	ruleRepo := &StubUserRuleRepo{}
+	weatherUC := usecase.NewWeatherUsecase(new(MockWeatherRuleRepo), ruleRepo, new(MockNotificationRepo), &StubDeliveryRepo{}, &StubUserRepo{user: user}, new(MockAreaUC), new(MockForecastFetcher), nil, &StubSnapshotRepo{}, newNopNotifier(), usecase.BatchConfig{})
+
+	rules, err := weatherUC.SetUserRule(ctx, 1, usecase.UserRule{WeatherCode: "200", IsNotifyTrigger: true})
+	require.NoError(t, err)
+	require.Len(t, rules.Overrides, 1)
+
+	rules, err = weatherUC.SetUserRule(ctx, 1, usecase.UserRule{Category: entity.WeatherCategorySnow, IsNotifyTrigger: false})
+	require.NoError(t, err)
+	require.Len(t, rules.Overrides, 2)
+
+	// 同じ対象は上書きする
+	rules, err = weatherUC.SetUserRule(ctx, 1, usecase.UserRule{WeatherCode: "200", IsNotifyTrigger: false})
+	require.NoError(t, err)
+	require.Len(t, rules.Overrides, 2)
+	assert.False(t, rules.Overrides[0].IsNotifyTrigger)
+
+	rules, err = weatherUC.ResetUserRules(ctx, 1, "200", "")
+	require.NoError(t, err)
+	require.Len(t, rules.Overrides, 1)
+	assert.Equal(t, entity.WeatherCategorySnow, rules.Overrides[0].Category)
+
+	rules, err = weatherUC.ResetUserRules(ctx, 1, "", "")
+	require.NoError(t, err)
+	assert.Empty(t, rules.Overrides)
+
+	_, err = weatherUC.SetUserRule(ctx, 1, usecase.UserRule{WeatherCode: "200", Category: entity.WeatherCategoryRain})
+	assert.Error(t, err)
+}
+
+func TestUserRules_UserNotFound(t *testing.T) {
+	ctx := context.Background()
+
+	weatherUC := usecase.NewWeatherUsecase(new(MockWeatherRuleRepo), &StubUserRuleRepo{}, new(MockNotificationRepo), &StubDeliveryRepo{}, &StubUserRepo{}, new(MockAreaUC), new(MockForecastFetcher), nil, &StubSnapshotRepo{}, newNopNotifier(), usecase.BatchConfig{})
+
+	rules, err := weatherUC.ListUserRules(ctx, 99)
+	require.NoError(t, err)
+	assert.Nil(t, rules)
+
+	rules, err = weatherUC.SetUserRule(ctx, 99, usecase.UserRule{WeatherCode: "200", IsNotifyTrigger: true})
+	require.NoError(t, err)
+	assert.Nil(t, rules)
+}
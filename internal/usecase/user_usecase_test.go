@@ -57,6 +57,22 @@ func (m *MockUserRepo) FindUserByNotifyTimeRange(ctx context.Context, startTime,
 	return nil, args.Error(1)
 }
 
+func (m *MockUserRepo) FindActiveUsersByOfficeID(ctx context.Context, officeID string) ([]*entity.User, error) {
+	args := m.Called(ctx, officeID)
+	if u := args.Get(0); u != nil {
+		return u.([]*entity.User), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockUserRepo) FindActiveUsersByNotifyMode(ctx context.Context, notifyMode string) ([]*entity.User, error) {
+	args := m.Called(ctx, notifyMode)
+	if u := args.Get(0); u != nil {
+		return u.([]*entity.User), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 // UserUsecase の生成ヘルパー
 func setupUserUsecaseTest() (*MockUserRepo, usecase.UserUsecase) {
 	mockRepo := new(MockUserRepo)
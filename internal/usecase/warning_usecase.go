@@ -0,0 +1,205 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/notifier"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/repository"
+	"github.com/Isshinfunada/weather-bot/internal/utils"
+)
+
+type WarningUsecase interface {
+	ProcessWarnings(ctx context.Context) error
+	ProcessWarningsForOffice(ctx context.Context, officeID string) error
+	PollWarnings(ctx context.Context) error
+}
+
+type warningUsecase struct {
+	areaRepo         repository.AreaRepository
+	userRepo         repository.UserRepository
+	warningRepo      repository.WarningRepository
+	notificationRepo repository.NotificationRepository
+	warningFetcher   jma.WarningFetcher
+	notifier         notifier.Notifier
+}
+
+func NewWarningUsecase(ar repository.AreaRepository, ur repository.UserRepository, wr repository.WarningRepository, nr repository.NotificationRepository, wf jma.WarningFetcher, n notifier.Notifier) WarningUsecase {
+	return &warningUsecase{
+		areaRepo:         ar,
+		userRepo:         ur,
+		warningRepo:      wr,
+		notificationRepo: nr,
+		warningFetcher:   wf,
+		notifier:         n,
+	}
+}
+
+// ProcessWarnings は有効なユーザーがいる全てのオフィスの警報・注意報を処理する
+func (u *warningUsecase) ProcessWarnings(ctx context.Context) error {
+	officeIDs, err := u.areaRepo.FindActiveOfficeIDs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to find active offices: %w", err)
+	}
+
+	for _, officeID := range officeIDs {
+		if err := u.ProcessWarningsForOffice(ctx, officeID); err != nil {
+			fmt.Printf("Error processing warnings for office %s: %v\n", officeID, err)
+		}
+	}
+	return nil
+}
+
+// ProcessWarningsForOffice はオフィスの警報・注意報を取得し、
+// 選択エリア（class20）に新しく発表されたものがあるユーザーへ通知する
+func (u *warningUsecase) ProcessWarningsForOffice(ctx context.Context, officeID string) error {
+	report, err := u.warningFetcher.FetchWarning(ctx, officeID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch warnings for office %s: %w", officeID, err)
+	}
+
+	_, err = u.processReport(ctx, officeID, report)
+	return err
+}
+
+// PollWarnings は通知時刻に関係なく、前回から発表が更新されたオフィスだけを処理する
+// 全ユーザーの処理に成功したときだけ発表時刻を記録し、失敗があれば次回もう一度差分を取る
+func (u *warningUsecase) PollWarnings(ctx context.Context) error {
+	officeIDs, err := u.areaRepo.FindActiveOfficeIDs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to find active offices: %w", err)
+	}
+
+	for _, officeID := range officeIDs {
+		if err := u.pollOffice(ctx, officeID); err != nil {
+			fmt.Printf("Error polling warnings for office %s: %v\n", officeID, err)
+		}
+	}
+	return nil
+}
+
+func (u *warningUsecase) pollOffice(ctx context.Context, officeID string) error {
+	report, err := u.warningFetcher.FetchWarning(ctx, officeID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch warnings for office %s: %w", officeID, err)
+	}
+
+	last, err := u.warningRepo.FindLastReportDatetime(ctx, officeID)
+	if err != nil {
+		return err
+	}
+	if last != nil && !report.ReportDatetime.After(*last) {
+		return nil
+	}
+
+	failed, err := u.processReport(ctx, officeID, report)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to process warnings for %d users", failed)
+	}
+	return u.warningRepo.SaveLastReportDatetime(ctx, officeID, report.ReportDatetime)
+}
+
+// processReport はオフィス配下のユーザーごとに警報・注意報の差分を処理し、失敗したユーザー数を返す
+func (u *warningUsecase) processReport(ctx context.Context, officeID string, report *jma.WarningReport) (int, error) {
+	users, err := u.userRepo.FindActiveUsersByOfficeID(ctx, officeID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find users for office %s: %w", officeID, err)
+	}
+
+	now := time.Now().In(utils.JST)
+	failed := 0
+	for _, user := range users {
+		if err := u.processUserWarnings(ctx, user, report, now); err != nil {
+			fmt.Printf("Error processing warnings for user %d: %v\n", user.ID, err)
+			failed++
+		}
+	}
+	return failed, nil
+}
+
+// processUserWarnings は発表中の警報・注意報と通知済みのものを比べ、
+// 新しいものだけ履歴に残して通知する。発表中でなくなったものはエピソードを閉じる
+func (u *warningUsecase) processUserWarnings(ctx context.Context, user *entity.User, report *jma.WarningReport, now time.Time) error {
+	active := report.ActiveWarnings(user.SelectedAreaID)
+	activeCodes := make(map[string]bool, len(active))
+	for _, w := range active {
+		activeCodes[w.Code] = true
+	}
+
+	notified, err := u.warningRepo.FindActiveUserWarnings(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	notifiedCodes := make(map[string]bool, len(notified))
+	for _, w := range notified {
+		if activeCodes[w.WarningCode] {
+			notifiedCodes[w.WarningCode] = true
+			continue
+		}
+		if err := u.warningRepo.ClearUserWarning(ctx, w.ID, now); err != nil {
+			return err
+		}
+	}
+
+	var newCodes []string
+	for _, w := range active {
+		if notifiedCodes[w.Code] {
+			continue
+		}
+		inserted, err := u.warningRepo.InsertUserWarning(ctx, &entity.UserWarning{
+			UserID:         user.ID,
+			AreaCode:       user.SelectedAreaID,
+			WarningCode:    w.Code,
+			ReportDatetime: report.ReportDatetime,
+			NotifiedAt:     now,
+		})
+		if err != nil {
+			return err
+		}
+		// 他の処理が先に記録していれば通知しない
+		if inserted {
+			newCodes = append(newCodes, w.Code)
+		}
+	}
+	if len(newCodes) == 0 {
+		return nil
+	}
+
+	// 送る前に pending の履歴を残すので、送信に失敗してもディスパッチャーが再送する
+	history := &entity.NotificationHistory{
+		UserID:           user.ID,
+		NotificationType: entity.NotificationTypeWarning,
+		NotificationTime: now,
+		IsNotifyTrigger:  true,
+		WarningCodes:     newCodes,
+	}
+	pendingHistory(history, warningMessage(newCodes, report.HeadlineText), now)
+	if err := u.notificationRepo.InsertNotificationHistory(ctx, history); err != nil {
+		return fmt.Errorf("failed to save warning notification history for user %d: %w", user.ID, err)
+	}
+
+	if err := deliverNotification(ctx, u.notificationRepo, u.notifier, user, history); err != nil {
+		fmt.Printf("User %d: 警報・注意報の通知に失敗したので再送します: %v\n", user.ID, err)
+	}
+	return nil
+}
+
+// warningMessage は新たに発表された警報・注意報の通知文を組み立てる
+func warningMessage(codes []string, headline string) string {
+	names := make([]string, len(codes))
+	for i, code := range codes {
+		names[i] = jma.WarningName(code)
+	}
+	message := fmt.Sprintf("%sが発表されました。", strings.Join(names, "、"))
+	if headline != "" {
+		message += "\n" + headline
+	}
+	return message
+}
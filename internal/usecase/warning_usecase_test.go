@@ -0,0 +1,325 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+	"github.com/Isshinfunada/weather-bot/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockWarningFetcher struct{ mock.Mock }
+
+func (m *MockWarningFetcher) FetchWarning(ctx context.Context, officeID string) (*jma.WarningReport, error) {
+	args := m.Called(ctx, officeID)
+	var report *jma.WarningReport
+	if args.Get(0) != nil {
+		report = args.Get(0).(*jma.WarningReport)
+	}
+	return report, args.Error(1)
+}
+
+type MockWarningRepo struct{ mock.Mock }
+
+func (m *MockWarningRepo) FindActiveUserWarnings(ctx context.Context, userID int) ([]*entity.UserWarning, error) {
+	args := m.Called(ctx, userID)
+	var warnings []*entity.UserWarning
+	if args.Get(0) != nil {
+		warnings = args.Get(0).([]*entity.UserWarning)
+	}
+	return warnings, args.Error(1)
+}
+
+func (m *MockWarningRepo) InsertUserWarning(ctx context.Context, warning *entity.UserWarning) (bool, error) {
+	args := m.Called(ctx, warning)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWarningRepo) ClearUserWarning(ctx context.Context, id int, clearedAt time.Time) error {
+	args := m.Called(ctx, id, clearedAt)
+	return args.Error(0)
+}
+
+func (m *MockWarningRepo) FindLastReportDatetime(ctx context.Context, officeID string) (*time.Time, error) {
+	args := m.Called(ctx, officeID)
+	var last *time.Time
+	if args.Get(0) != nil {
+		last = args.Get(0).(*time.Time)
+	}
+	return last, args.Error(1)
+}
+
+func (m *MockWarningRepo) SaveLastReportDatetime(ctx context.Context, officeID string, reportDatetime time.Time) error {
+	args := m.Called(ctx, officeID, reportDatetime)
+	return args.Error(0)
+}
+
+type MockNotifier struct{ mock.Mock }
+
+func (m *MockNotifier) Notify(ctx context.Context, user *entity.User, message string) error {
+	args := m.Called(ctx, user, message)
+	return args.Error(0)
+}
+
+func loadFixtureWarning(t *testing.T) *jma.WarningReport {
+	body, err := os.ReadFile("../interfaces/jma/testdata/warning_130000.json")
+	require.NoError(t, err)
+	report, err := jma.ParseWarning(body)
+	require.NoError(t, err)
+	return report
+}
+
+// warningCode は InsertUserWarning に渡された警報コードに一致する matcher
+func warningCode(code string) interface{} {
+	return mock.MatchedBy(func(w *entity.UserWarning) bool { return w.WarningCode == code })
+}
+
+func TestProcessWarningsForOffice(t *testing.T) {
+	ctx := context.Background()
+
+	mockFetcher := new(MockWarningFetcher)
+	mockUserRepo := new(MockUserRepo)
+	mockWarningRepo := new(MockWarningRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockNotifier := new(MockNotifier)
+
+	mockFetcher.On("FetchWarning", ctx, "130000").Return(loadFixtureWarning(t), nil)
+	mockUserRepo.On("FindActiveUsersByOfficeID", ctx, "130000").Return([]*entity.User{
+		{ID: 1, SelectedAreaID: "1310100"},
+		{ID: 2, SelectedAreaID: "1336100"},
+	}, nil)
+
+	// User 1: 雷注意報は通知済み、大雨警報が新たに発表
+	mockWarningRepo.On("FindActiveUserWarnings", ctx, 1).Return([]*entity.UserWarning{
+		{ID: 10, UserID: 1, WarningCode: "14"},
+	}, nil)
+	mockWarningRepo.On("InsertUserWarning", ctx, warningCode("03")).Return(true, nil).Once()
+
+	// User 2: 発表なしになったので通知済みの大雨注意報を解除
+	mockWarningRepo.On("FindActiveUserWarnings", ctx, 2).Return([]*entity.UserWarning{
+		{ID: 20, UserID: 2, WarningCode: "10"},
+	}, nil)
+	mockWarningRepo.On("ClearUserWarning", ctx, 20, mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+	mockNotifier.On("Notify", ctx, mock.MatchedBy(func(u *entity.User) bool { return u.ID == 1 }), mock.MatchedBy(func(msg string) bool {
+		return strings.HasPrefix(msg, "大雨警報が発表されました。")
+	})).Return(nil).Once()
+
+	var history *entity.NotificationHistory
+	mockNotificationRepo.
+		On("InsertNotificationHistory", ctx, mock.AnythingOfType("*entity.NotificationHistory")).
+		Run(func(args mock.Arguments) { history = args.Get(1).(*entity.NotificationHistory) }).
+		Return(nil).Once()
+
+	warningUC := usecase.NewWarningUsecase(new(MockAreaRepo), mockUserRepo, mockWarningRepo, mockNotificationRepo, mockFetcher, mockNotifier)
+
+	err := warningUC.ProcessWarningsForOffice(ctx, "130000")
+	require.NoError(t, err)
+
+	require.NotNil(t, history)
+	assert.Equal(t, 1, history.UserID)
+	assert.Equal(t, entity.NotificationTypeWarning, history.NotificationType)
+	assert.True(t, history.IsNotifyTrigger)
+	assert.Equal(t, []string{"03"}, history.WarningCodes)
+	assert.Equal(t, entity.DeliveryStatusSent, history.DeliveryStatus)
+
+	mockWarningRepo.AssertExpectations(t)
+	mockNotifier.AssertExpectations(t)
+	mockNotificationRepo.AssertExpectations(t)
+}
+
+func TestProcessWarningsForOffice_NotifyFailureLeavesPendingHistory(t *testing.T) {
+	ctx := context.Background()
+
+	mockFetcher := new(MockWarningFetcher)
+	mockUserRepo := new(MockUserRepo)
+	mockWarningRepo := new(MockWarningRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockNotifier := new(MockNotifier)
+
+	mockFetcher.On("FetchWarning", ctx, "130000").Return(loadFixtureWarning(t), nil)
+	mockUserRepo.On("FindActiveUsersByOfficeID", ctx, "130000").Return([]*entity.User{
+		{ID: 1, SelectedAreaID: "1310100"},
+	}, nil)
+	mockWarningRepo.On("FindActiveUserWarnings", ctx, 1).Return(nil, nil)
+	mockWarningRepo.On("InsertUserWarning", ctx, mock.Anything).Return(true, nil)
+	mockNotifier.On("Notify", ctx, mock.Anything, mock.Anything).Return(errors.New("line api error")).Once()
+
+	// 送る前の状態を残すため、渡された履歴をコピーしておく
+	var inserted entity.NotificationHistory
+	mockNotificationRepo.
+		On("InsertNotificationHistory", ctx, mock.AnythingOfType("*entity.NotificationHistory")).
+		Run(func(args mock.Arguments) { inserted = *args.Get(1).(*entity.NotificationHistory) }).
+		Return(nil).Once()
+
+	warningUC := usecase.NewWarningUsecase(new(MockAreaRepo), mockUserRepo, mockWarningRepo, mockNotificationRepo, mockFetcher, mockNotifier)
+
+	// 送信の失敗はディスパッチャーが再送するので、ユーザーの処理は失敗にしない
+	err := warningUC.ProcessWarningsForOffice(ctx, "130000")
+	require.NoError(t, err)
+
+	assert.Equal(t, entity.NotificationTypeWarning, inserted.NotificationType)
+	assert.Equal(t, entity.DeliveryStatusPending, inserted.DeliveryStatus)
+	assert.True(t, strings.HasPrefix(inserted.Message, "大雨警報"))
+	assert.NotNil(t, inserted.NextAttemptAt)
+
+	updates := mockNotificationRepo.Updates()
+	require.Len(t, updates, 1)
+	assert.Equal(t, entity.DeliveryStatusPending, updates[0].DeliveryStatus)
+	assert.Equal(t, 1, updates[0].DeliveryAttempts)
+	assert.Equal(t, "line api error", updates[0].LastError)
+
+	mockNotifier.AssertExpectations(t)
+	mockNotificationRepo.AssertExpectations(t)
+}
+
+func TestProcessWarningsForOffice_AlreadyNotified(t *testing.T) {
+	ctx := context.Background()
+
+	mockFetcher := new(MockWarningFetcher)
+	mockUserRepo := new(MockUserRepo)
+	mockWarningRepo := new(MockWarningRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockNotifier := new(MockNotifier)
+
+	mockFetcher.On("FetchWarning", ctx, "130000").Return(loadFixtureWarning(t), nil)
+	mockUserRepo.On("FindActiveUsersByOfficeID", ctx, "130000").Return([]*entity.User{
+		{ID: 1, SelectedAreaID: "1310200"},
+	}, nil)
+	mockWarningRepo.On("FindActiveUserWarnings", ctx, 1).Return([]*entity.UserWarning{
+		{ID: 10, UserID: 1, WarningCode: "10"},
+	}, nil)
+	// 同時に動いた別の処理が先に記録した場合も通知しない
+	mockWarningRepo.On("InsertUserWarning", ctx, warningCode("14")).Return(false, nil).Once()
+
+	warningUC := usecase.NewWarningUsecase(new(MockAreaRepo), mockUserRepo, mockWarningRepo, mockNotificationRepo, mockFetcher, mockNotifier)
+
+	err := warningUC.ProcessWarningsForOffice(ctx, "130000")
+	require.NoError(t, err)
+
+	mockWarningRepo.AssertExpectations(t)
+	mockNotifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything, mock.Anything)
+	mockNotificationRepo.AssertNotCalled(t, "InsertNotificationHistory", mock.Anything, mock.Anything)
+}
+
+func TestProcessWarningsForOffice_FetchError(t *testing.T) {
+	ctx := context.Background()
+
+	mockFetcher := new(MockWarningFetcher)
+	mockFetcher.On("FetchWarning", ctx, "130000").Return(nil, errors.New("unavailable"))
+
+	warningUC := usecase.NewWarningUsecase(new(MockAreaRepo), new(MockUserRepo), new(MockWarningRepo), new(MockNotificationRepo), mockFetcher, new(MockNotifier))
+
+	err := warningUC.ProcessWarningsForOffice(ctx, "130000")
+	assert.Error(t, err)
+}
+
+func TestProcessWarnings(t *testing.T) {
+	ctx := context.Background()
+
+	mockAreaRepo := new(MockAreaRepo)
+	mockFetcher := new(MockWarningFetcher)
+	mockUserRepo := new(MockUserRepo)
+
+	mockAreaRepo.On("FindActiveOfficeIDs", ctx).Return([]string{"130000", "270000"}, nil)
+	// 1つのオフィスが失敗しても他のオフィスは処理する
+	mockFetcher.On("FetchWarning", ctx, "130000").Return(nil, errors.New("unavailable")).Once()
+	mockFetcher.On("FetchWarning", ctx, "270000").Return(&jma.WarningReport{}, nil).Once()
+	mockUserRepo.On("FindActiveUsersByOfficeID", ctx, "270000").Return([]*entity.User{}, nil).Once()
+
+	warningUC := usecase.NewWarningUsecase(mockAreaRepo, mockUserRepo, new(MockWarningRepo), new(MockNotificationRepo), mockFetcher, new(MockNotifier))
+
+	err := warningUC.ProcessWarnings(ctx)
+	require.NoError(t, err)
+	mockFetcher.AssertExpectations(t)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestPollWarnings_SkipsUnchangedReport(t *testing.T) {
+	ctx := context.Background()
+
+	report := loadFixtureWarning(t)
+	last := report.ReportDatetime
+
+	mockAreaRepo := new(MockAreaRepo)
+	mockFetcher := new(MockWarningFetcher)
+	mockUserRepo := new(MockUserRepo)
+	mockWarningRepo := new(MockWarningRepo)
+
+	mockAreaRepo.On("FindActiveOfficeIDs", ctx).Return([]string{"130000"}, nil)
+	mockFetcher.On("FetchWarning", ctx, "130000").Return(report, nil)
+	mockWarningRepo.On("FindLastReportDatetime", ctx, "130000").Return(&last, nil)
+
+	warningUC := usecase.NewWarningUsecase(mockAreaRepo, mockUserRepo, mockWarningRepo, new(MockNotificationRepo), mockFetcher, new(MockNotifier))
+
+	err := warningUC.PollWarnings(ctx)
+	require.NoError(t, err)
+	// 前回と同じ発表ならユーザーを引かない
+	mockUserRepo.AssertNotCalled(t, "FindActiveUsersByOfficeID", mock.Anything, mock.Anything)
+	mockWarningRepo.AssertNotCalled(t, "SaveLastReportDatetime", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPollWarnings_ProcessesNewReport(t *testing.T) {
+	ctx := context.Background()
+
+	report := loadFixtureWarning(t)
+	last := report.ReportDatetime.Add(-time.Hour)
+
+	mockAreaRepo := new(MockAreaRepo)
+	mockFetcher := new(MockWarningFetcher)
+	mockUserRepo := new(MockUserRepo)
+	mockWarningRepo := new(MockWarningRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockNotifier := new(MockNotifier)
+
+	mockAreaRepo.On("FindActiveOfficeIDs", ctx).Return([]string{"130000"}, nil)
+	mockFetcher.On("FetchWarning", ctx, "130000").Return(report, nil)
+	mockWarningRepo.On("FindLastReportDatetime", ctx, "130000").Return(&last, nil)
+	mockUserRepo.On("FindActiveUsersByOfficeID", ctx, "130000").Return([]*entity.User{
+		{ID: 1, SelectedAreaID: "1310100"},
+	}, nil)
+	mockWarningRepo.On("FindActiveUserWarnings", ctx, 1).Return(nil, nil)
+	mockWarningRepo.On("InsertUserWarning", ctx, mock.Anything).Return(true, nil).Twice()
+	mockNotifier.On("Notify", ctx, mock.Anything, mock.Anything).Return(nil).Once()
+	mockNotificationRepo.On("InsertNotificationHistory", ctx, mock.Anything).Return(nil).Once()
+	mockWarningRepo.On("SaveLastReportDatetime", ctx, "130000", report.ReportDatetime).Return(nil).Once()
+
+	warningUC := usecase.NewWarningUsecase(mockAreaRepo, mockUserRepo, mockWarningRepo, mockNotificationRepo, mockFetcher, mockNotifier)
+
+	err := warningUC.PollWarnings(ctx)
+	require.NoError(t, err)
+	mockWarningRepo.AssertExpectations(t)
+	mockNotifier.AssertExpectations(t)
+}
+
+func TestPollWarnings_KeepsStateOnUserFailure(t *testing.T) {
+	ctx := context.Background()
+
+	mockAreaRepo := new(MockAreaRepo)
+	mockFetcher := new(MockWarningFetcher)
+	mockUserRepo := new(MockUserRepo)
+	mockWarningRepo := new(MockWarningRepo)
+
+	mockAreaRepo.On("FindActiveOfficeIDs", ctx).Return([]string{"130000"}, nil)
+	mockFetcher.On("FetchWarning", ctx, "130000").Return(loadFixtureWarning(t), nil)
+	mockWarningRepo.On("FindLastReportDatetime", ctx, "130000").Return(nil, nil)
+	mockUserRepo.On("FindActiveUsersByOfficeID", ctx, "130000").Return([]*entity.User{
+		{ID: 1, SelectedAreaID: "1310100"},
+	}, nil)
+	mockWarningRepo.On("FindActiveUserWarnings", ctx, 1).Return(nil, errors.New("db error"))
+
+	warningUC := usecase.NewWarningUsecase(mockAreaRepo, mockUserRepo, mockWarningRepo, new(MockNotificationRepo), mockFetcher, new(MockNotifier))
+
+	err := warningUC.PollWarnings(ctx)
+	require.NoError(t, err)
+	// 失敗したユーザーがいれば次回もう一度処理する
+	mockWarningRepo.AssertNotCalled(t, "SaveLastReportDatetime", mock.Anything, mock.Anything, mock.Anything)
+}
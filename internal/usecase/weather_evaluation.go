@@ -0,0 +1,184 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+)
+
+// eveningFrom 以降の通知を夕方として扱う
+// auto モードでは翌日の予報を対象にし、日曜の夕方には週間予報も通知する
+const eveningFrom = 17 * time.Hour
+
+// resolveTargetDate はユーザーの設定から予報の対象日（0時）を決める
+// 短期予報は翌々日まで含むので、翌日もそのまま同じ予報から判定できる
+func resolveTargetDate(user *entity.User, now time.Time) time.Time {
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+
+	switch user.TargetDay {
+	case entity.TargetDayTomorrow:
+		return today.AddDate(0, 0, 1)
+	case entity.TargetDayAuto:
+		if isEveningNotify(user) {
+			return today.AddDate(0, 0, 1)
+		}
+	}
+	return today
+}
+
+// isEveningNotify はユーザーの通知時刻が夕方以降か判定する
+func isEveningNotify(user *entity.User) bool {
+	return timeOfDay(user.NotifyTime) >= eveningFrom
+}
+
+// timeOfDay は t の0時からの経過時間（分単位）を返す
+func timeOfDay(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// weatherDecision は1ユーザー分の通知判定結果
+type weatherDecision struct {
+	Notify       bool
+	WeatherCodes []string
+	MatchedCodes []string // 通知対象になったコード
+	MatchedPops  []entity.PopBlock
+	MostSevere   *entity.WeatherRule // 通知対象のコードのうち最も深刻なもの
+}
+
+// evaluate は予報と通知ルールからユーザーへの通知要否を判定する
+// 時間帯を設定しているユーザーは、その時間帯に重なるブロックだけを天気コードと降水確率の判定に使う
+func (u *weatherUsecase) evaluate(ctx context.Context, user *entity.User, rules *userRuleSet, forecast *jma.Forecast, class10ID string, targetDate time.Time) *weatherDecision {
+	windows := windowIntervals(user.TimeWindows, targetDate)
+	decision := &weatherDecision{}
+
+	for _, b := range forecast.WeatherCodeBlocks(class10ID, targetDate) {
+		if windows != nil && !overlapsAny(b.Start, b.End, windows) {
+			continue
+		}
+		decision.WeatherCodes = append(decision.WeatherCodes, b.Code)
+	}
+
+	// 天気コードに基づき通知トリガー設定（ユーザーの上書きを優先）
+	// 通知では最も深刻なコードの名前を出すので、全てのコードを判定する
+	for _, code := range decision.WeatherCodes {
+		rule, trigger, err := u.isNotifyTrigger(ctx, code, rules)
+		if err != nil {
+			fmt.Printf("Error retrieving rule for code %s: %v\n", code, err)
+			continue
+		}
+		if !trigger {
+			continue
+		}
+		decision.Notify = true
+		decision.MatchedCodes = append(decision.MatchedCodes, code)
+		if rule != nil && (decision.MostSevere == nil || rule.Severity > decision.MostSevere.Severity) {
+			decision.MostSevere = rule
+		}
+	}
+
+	// 降水確率の閾値を超えるブロックがあれば通知
+	// 閾値が未設定なら、時間帯を設定していても降水確率では通知しない
+	if threshold := user.PopThreshold; threshold != nil {
+		for _, pop := range forecast.Pops(class10ID, targetDate) {
+			if windows != nil && !overlapsAny(pop.Start, pop.End, windows) {
+				continue
+			}
+			if pop.Value >= *threshold {
+				decision.MatchedPops = append(decision.MatchedPops, entity.PopBlock{
+					Start: pop.Start,
+					End:   pop.End,
+					Pop:   pop.Value,
+				})
+			}
+		}
+		if len(decision.MatchedPops) > 0 {
+			decision.Notify = true
+		}
+	}
+
+	return decision
+}
+
+// resolveNotification は判定結果と直近の判定から、通知するかと雨の予報が無くなった通知かを決める
+// on_change のユーザーは雨でない→雨に変わったときだけ、notify_all_clear なら雨→雨でないに変わったときも通知する
+func resolveNotification(user *entity.User, decision *weatherDecision, prev *entity.NotificationHistory) (notify, allClear bool) {
+	if user.NotifyMode != entity.NotifyModeOnChange {
+		return decision.Notify, false
+	}
+
+	wasRain := prev != nil && prev.IsNotifyTrigger
+	switch {
+	case decision.Notify && !wasRain:
+		return true, false
+	case !decision.Notify && wasRain && user.NotifyAllClear:
+		return true, true
+	}
+	return false, false
+}
+
+// rainMessage は雨の予報の通知文を組み立てる
+// 通知対象のコードがあれば、最も深刻なコードの天気を名前に出す
+func rainMessage(targetDate time.Time, mostSevere *entity.WeatherRule, pops []entity.PopBlock) string {
+	weather := "雨"
+	if mostSevere != nil && mostSevere.WeatherDescription != "" {
+		weather = fmt.Sprintf("「%s」", mostSevere.WeatherDescription)
+	}
+	message := fmt.Sprintf("%sは%sの予報です。", formatDate(targetDate), weather)
+	for _, p := range pops {
+		message += fmt.Sprintf("\n%s〜%s 降水確率 %d%%", p.Start.Format("15:04"), p.End.Format("15:04"), p.Pop)
+	}
+	return message
+}
+
+// allClearMessage は雨の予報が無くなったときの通知文を組み立てる
+func allClearMessage(targetDate time.Time) string {
+	return fmt.Sprintf("%sの雨の予報は無くなりました。", formatDate(targetDate))
+}
+
+func formatDate(t time.Time) string {
+	return fmt.Sprintf("%d月%d日", t.Month(), t.Day())
+}
+
+type interval struct {
+	start time.Time
+	end   time.Time
+}
+
+// windowIntervals はユーザーの時間帯を対象日の時刻に変換する。時間帯が無ければ nil
+func windowIntervals(windows []entity.TimeWindow, date time.Time) []interval {
+	if len(windows) == 0 {
+		return nil
+	}
+
+	y, m, d := date.Date()
+	intervals := make([]interval, 0, len(windows))
+	for _, w := range windows {
+		start, err := time.Parse("15:04", w.Start)
+		if err != nil {
+			continue
+		}
+		end, err := time.Parse("15:04", w.End)
+		if err != nil {
+			continue
+		}
+		intervals = append(intervals, interval{
+			start: time.Date(y, m, d, start.Hour(), start.Minute(), 0, 0, date.Location()),
+			end:   time.Date(y, m, d, end.Hour(), end.Minute(), 0, 0, date.Location()),
+		})
+	}
+	return intervals
+}
+
+// overlapsAny は [start, end) がいずれかの時間帯と重なるか判定する
+func overlapsAny(start, end time.Time, intervals []interval) bool {
+	for _, iv := range intervals {
+		if start.Before(iv.end) && iv.start.Before(end) {
+			return true
+		}
+	}
+	return false
+}
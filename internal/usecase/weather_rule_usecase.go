@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/repository"
+)
+
+// WeatherRuleUsecase は weather_notification_rules の管理用
+type WeatherRuleUsecase interface {
+	ListRules(ctx context.Context) ([]*entity.WeatherRule, error)
+	CreateRule(ctx context.Context, rule *entity.WeatherRule) (bool, error)
+	UpdateRule(ctx context.Context, rule *entity.WeatherRule) (bool, error)
+	DeleteRule(ctx context.Context, weatherCode string) (bool, error)
+}
+
+type weatherRuleUsecase struct {
+	weatherRuleRepo repository.WeatherRuleRepository
+}
+
+// NewWeatherRuleUsecase は weatherUsecase と同じリポジトリを渡すと、変更が再起動なしで通知判定に反映される
+func NewWeatherRuleUsecase(wr repository.WeatherRuleRepository) WeatherRuleUsecase {
+	return &weatherRuleUsecase{weatherRuleRepo: wr}
+}
+
+func (u *weatherRuleUsecase) ListRules(ctx context.Context) ([]*entity.WeatherRule, error) {
+	return u.weatherRuleRepo.ListRules(ctx)
+}
+
+// CreateRule はルールを登録する。同じ天気コードが既にあれば false
+func (u *weatherRuleUsecase) CreateRule(ctx context.Context, rule *entity.WeatherRule) (bool, error) {
+	if rule.WeatherCode == "" {
+		return false, fmt.Errorf("weather code is required")
+	}
+	return u.weatherRuleRepo.CreateRule(ctx, withRuleDefaults(rule))
+}
+
+// UpdateRule はルールを更新する。天気コードが無ければ false
+func (u *weatherRuleUsecase) UpdateRule(ctx context.Context, rule *entity.WeatherRule) (bool, error) {
+	if rule.WeatherCode == "" {
+		return false, fmt.Errorf("weather code is required")
+	}
+	return u.weatherRuleRepo.UpdateRule(ctx, withRuleDefaults(rule))
+}
+
+// DeleteRule はルールを削除する。天気コードが無ければ false
+func (u *weatherRuleUsecase) DeleteRule(ctx context.Context, weatherCode string) (bool, error) {
+	if weatherCode == "" {
+		return false, fmt.Errorf("weather code is required")
+	}
+	return u.weatherRuleRepo.DeleteRule(ctx, weatherCode)
+}
+
+// withRuleDefaults は未指定の降水の種類・強さを「なし」にする
+func withRuleDefaults(rule *entity.WeatherRule) *entity.WeatherRule {
+	if rule.Precipitation == "" {
+		rule.Precipitation = entity.PrecipitationNone
+	}
+	if rule.Intensity == "" {
+		rule.Intensity = entity.IntensityNone
+	}
+	return rule
+}
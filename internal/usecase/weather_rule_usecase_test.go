@@ -0,0 +1,50 @@
+package usecase_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeatherRuleUsecase_CreateRuleDefaults(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockWeatherRuleRepo)
+	uc := usecase.NewWeatherRuleUsecase(mockRepo)
+
+	// 降水の種類・強さが未指定なら「なし」で登録する
+	mockRepo.On("CreateRule", ctx, mock.MatchedBy(func(r *entity.WeatherRule) bool {
+		return r.WeatherCode == "209" && r.Precipitation == entity.PrecipitationNone && r.Intensity == entity.IntensityNone
+	})).Return(true, nil)
+
+	created, err := uc.CreateRule(ctx, &entity.WeatherRule{WeatherCode: "209", WeatherDescription: "霧", HasFog: true})
+	require.NoError(t, err)
+	assert.True(t, created)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWeatherRuleUsecase_UpdateAndDelete(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockWeatherRuleRepo)
+	uc := usecase.NewWeatherRuleUsecase(mockRepo)
+
+	rule := &entity.WeatherRule{WeatherCode: "200", WeatherDescription: "曇", IsNotifyTrigger: true, Precipitation: entity.PrecipitationNone, Intensity: entity.IntensityNone}
+	mockRepo.On("UpdateRule", ctx, rule).Return(false, nil)
+	mockRepo.On("DeleteRule", ctx, "200").Return(true, nil)
+
+	updated, err := uc.UpdateRule(ctx, rule)
+	require.NoError(t, err)
+	assert.False(t, updated)
+
+	deleted, err := uc.DeleteRule(ctx, "200")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+
+	_, err = uc.DeleteRule(ctx, "")
+	assert.Error(t, err)
+	mockRepo.AssertExpectations(t)
+}
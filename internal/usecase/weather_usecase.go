@@ -2,190 +2,329 @@ package usecase
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"strings"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/cache"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/notifier"
 	"github.com/Isshinfunada/weather-bot/internal/interfaces/repository"
 	"github.com/Isshinfunada/weather-bot/internal/utils"
 )
 
+// deliveryClaimLease は処理中の記録を、通知の履歴が無くても他の実行が処理し直さない時間
+// ユーザーごとのタイムアウトの2倍の方が長ければそちらを使う
+const deliveryClaimLease = 10 * time.Minute
+
 type WeatherUsecase interface {
-	ProcessWeatherForUser(ctx context.Context, user *entity.User) error
-	ProcessWeatherForUsersInTimeRange(ctx context.Context, start, end time.Time) error
+	ProcessWeatherForUser(ctx context.Context, user *entity.User, opts ProcessOptions) (*UserDecision, error)
+	ProcessWeatherForUsersInTimeRange(ctx context.Context, start, end time.Time, opts ProcessOptions) (*BatchResult, error)
+	GetWeeklySummary(ctx context.Context, userID int) (*WeeklySummary, error)
+	RecheckWeatherForUsers(ctx context.Context) (*BatchResult, error)
+	ListUserRules(ctx context.Context, userID int) (*UserRules, error)
+	SetUserRule(ctx context.Context, userID int, rule UserRule) (*UserRules, error)
+	ResetUserRules(ctx context.Context, userID int, weatherCode, category string) (*UserRules, error)
+	ReplayEvaluations(ctx context.Context, from, to time.Time) (*ReplayReport, error)
+}
+
+// ProcessOptions は天気の処理の実行方法
+type ProcessOptions struct {
+	// DryRun なら予報の取得と判定だけを行い、通知も履歴・スナップショットの保存もしない
+	DryRun bool
+}
+
+// UserDecision は1ユーザー分の判定結果
+type UserDecision struct {
+	UserID          int               `json:"userId"`
+	TargetDate      string            `json:"targetDate"`      // "2006-01-02"
+	IsNotifyTrigger bool              `json:"isNotifyTrigger"` // 予報が通知対象か
+	Notify          bool              `json:"notify"`          // 通知するか。on_change なら直近の判定と比べた結果
+	AllClear        bool              `json:"allClear"`        // 雨の予報が無くなった通知か
+	WeatherCodes    []string          `json:"weatherCodes"`
+	MatchedCodes    []string          `json:"matchedCodes"` // 通知対象になった天気コード
+	MatchedPops     []entity.PopBlock `json:"matchedPops"`
+	Message         string            `json:"message,omitempty"` // 通知する文面
 }
 
 type weatherUsecase struct {
 	weatherRuleRepo  repository.WeatherRuleRepository
+	userRuleRepo     repository.UserWeatherRuleRepository
 	notificationRepo repository.NotificationRepository
+	deliveryRepo     repository.DeliveryRepository
 	userRepo         repository.UserRepository
 	areaUC           AreaUseCase
+	forecastFetcher  jma.ForecastFetcher
+	forecastCache    cache.ForecastCache
+	snapshotRepo     repository.ForecastSnapshotRepository
+	notifier         notifier.Notifier
+	batchConfig      BatchConfig
 }
 
-func NewWeatherUsecase(wr repository.WeatherRuleRepository, nr repository.NotificationRepository, ur repository.UserRepository, auc AreaUseCase) WeatherUsecase {
+func NewWeatherUsecase(wr repository.WeatherRuleRepository, urr repository.UserWeatherRuleRepository, nr repository.NotificationRepository, dr repository.DeliveryRepository, ur repository.UserRepository, auc AreaUseCase, ff jma.ForecastFetcher, fc cache.ForecastCache, sr repository.ForecastSnapshotRepository, n notifier.Notifier, bc BatchConfig) WeatherUsecase {
 	return &weatherUsecase{
 		weatherRuleRepo:  wr,
+		userRuleRepo:     urr,
 		notificationRepo: nr,
+		deliveryRepo:     dr,
 		userRepo:         ur,
 		areaUC:           auc,
+		forecastFetcher:  ff,
+		forecastCache:    fc,
+		snapshotRepo:     sr,
+		notifier:         n,
+		batchConfig:      bc,
 	}
 }
 
-func (u *weatherUsecase) ProcessWeatherForUser(ctx context.Context, user *entity.User) error {
-	// ユーザーの選択エリアから改装情報を取得
-	hierarchy, err := u.areaUC.GetHierarchy(ctx, fmt.Sprint(user.SelectedAreaID))
-	if err != nil {
-		return fmt.Errorf("failed to get hierarchy for user %d: %w", user.ID, err)
-	}
-	if hierarchy == nil {
-		return fmt.Errorf("no hierarchy found %s for user %d", user.SelectedAreaID, user.ID)
+func (u *weatherUsecase) ProcessWeatherForUser(ctx context.Context, user *entity.User, opts ProcessOptions) (*UserDecision, error) {
+	decision, _, err := u.processWeatherForUser(ctx, user, u.newLoader(ctx, opts), entity.NotificationTypeDaily, opts)
+	return decision, err
+}
+
+// newLoader は実行ごとの forecastLoader を作る
+// ドライランではスナップショットを保存せず、他の実行やレプリカと共有するキャッシュにも書き込まない
+func (u *weatherUsecase) newLoader(ctx context.Context, opts ProcessOptions) *forecastLoader {
+	if opts.DryRun {
+		var forecastCache cache.ForecastCache
+		if u.forecastCache != nil {
+			forecastCache = readOnlyForecastCache{u.forecastCache}
+		}
+		return newForecastLoader(ctx, u.forecastFetcher, forecastCache, nil, u.batchConfig.userTimeout())
 	}
+	return newForecastLoader(ctx, u.forecastFetcher, u.forecastCache, u.snapshotRepo, u.batchConfig.userTimeout())
+}
 
-	areaOfficeID := hierarchy.Office.ID
-	class10ID := hierarchy.Class10
+// processWeatherForUser は loader 経由で予報を取得してユーザーの通知判定を行う
+// バッチ処理では実行中のユーザー間で同じ loader を共有する
+// 日々の通知はユーザー・対象日ごとに1回だけ処理し、処理済みなら outcomeDuplicate を返す
+func (u *weatherUsecase) processWeatherForUser(ctx context.Context, user *entity.User, loader *forecastLoader, notificationType string, opts ProcessOptions) (*UserDecision, userOutcome, error) {
+	// 対象日を取得
+	// 過去データはレスポンス内に無いので、当日か翌日をユーザーの設定から決める
+	now := time.Now().In(utils.JST)
+	targetDate := resolveTargetDate(user, now)
 
-	// JMAエンドポイントから天気データを取得
-	url := fmt.Sprintf("https://www.jma.go.jp/bosai/forecast/data/forecast/%s.json", areaOfficeID)
-	resp, err := http.Get(url)
+	// recheck は on_change のユーザーの変化を拾うため、1日に何度でも判定する
+	if notificationType != entity.NotificationTypeDaily || opts.DryRun {
+		return u.decideAndNotify(ctx, user, loader, notificationType, now, targetDate, opts)
+	}
+
+	claimed, err := u.claimDelivery(ctx, user.ID, targetDate, notificationType, now)
 	if err != nil {
-		return fmt.Errorf("failed to fetch weather data: %w", err)
+		return nil, outcomeNotEvaluated, err
+	}
+	if !claimed {
+		fmt.Printf("User %d: %s の通知は処理済み\n", user.ID, targetDate.Format("2006-01-02"))
+		return nil, outcomeDuplicate, nil
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	decision, outcome, err := u.decideAndNotify(ctx, user, loader, notificationType, now, targetDate, opts)
 	if err != nil {
-		return fmt.Errorf("failed to fetch weather data: %w", err)
+		u.releaseDelivery(user.ID, targetDate, notificationType)
 	}
+	return decision, outcome, err
+}
 
-	// JSONレスポンスをパースし、対象エリアの天気コードを抽出
-	var data []map[string]interface{}
-	if err := json.Unmarshal(body, &data); err != nil {
-		return fmt.Errorf("failed to parse JSON: %w", err)
+// decideAndNotify は予報を判定し、必要なら通知する
+// 判定できたときは、履歴の保存に失敗しても判定結果を返す
+// 通知に失敗したときは履歴を再送待ちにして outcomeQueued を返す
+func (u *weatherUsecase) decideAndNotify(ctx context.Context, user *entity.User, loader *forecastLoader, notificationType string, now, targetDate time.Time, opts ProcessOptions) (*UserDecision, userOutcome, error) {
+	hierarchy, loaded, err := u.loadForecastForUser(ctx, user, loader)
+	if err != nil {
+		return nil, outcomeNotEvaluated, err
 	}
+	class10ID := hierarchy.Class10
+	forecast := loaded.Forecast
+	snapshotID := loaded.SnapshotID
 
-	// 対象日を取得
-	// 過去データはレスポンス内に無いし、当日にこそ意味あると思っているので一旦現在の日付
-	targetDate := time.Now().In(utils.JST).Format("2006-01-02")
-
-	var weatherCodes []string
-	// JSONデータから対象日の天気コードを抽出
-	for _, forecast := range data {
-		timeSeries, ok := forecast["timeSeries"].([]interface{})
-		if !ok {
-			continue
-		}
-		for _, ts := range timeSeries {
-			tsMap, ok := ts.(map[string]interface{})
-			if !ok {
-				continue
-			}
+	rules, err := u.loadUserRuleSet(ctx, user.ID)
+	if err != nil {
+		return nil, outcomeNotEvaluated, err
+	}
 
-			// timeDefines内に対象日が含まれているか確認
-			timeDefines, ok := tsMap["timeDefines"].([]interface{})
-			if !ok {
-				continue
-			}
-			includesTargetDate := false
-			for _, td := range timeDefines {
-				if tdStr, ok := td.(string); ok {
-					if strings.HasPrefix(tdStr, targetDate) {
-						includesTargetDate = true
-						break
-					}
-				}
-			}
-			if !includesTargetDate {
-				continue
-			}
+	// 対象エリアの対象日の予報から通知要否を判定
+	decision := u.evaluate(ctx, user, rules, forecast, class10ID.ID, targetDate)
 
-			// 対象日を含むtimeSeriesからエリア情報を抽出
-			areas, ok := tsMap["areas"].([]interface{})
-			if !ok {
-				continue
-			}
-			for _, area := range areas {
-				areaMap, ok := area.(map[string]interface{})
-				if !ok {
-					continue
-				}
-				a, ok := areaMap["area"].(map[string]interface{})
-				if !ok {
-					continue
-				}
-				code, ok := a["code"].(string)
-				if !ok {
-					continue
-				}
-				if code == class10ID.ID {
-					if wc, ok := areaMap["weatherCodes"].([]interface{}); ok {
-						for _, w := range wc {
-							if ws, ok := w.(string); ok {
-								weatherCodes = append(weatherCodes, ws)
-							}
-						}
-					}
-				}
-			}
+	// on_change のユーザーは同じ対象日の直近の判定と比べる
+	var prev *entity.NotificationHistory
+	if user.NotifyMode == entity.NotifyModeOnChange {
+		prev, err = u.notificationRepo.FindLatestEvaluation(ctx, user.ID, targetDate)
+		if err != nil {
+			return nil, outcomeNotEvaluated, fmt.Errorf("failed to get latest evaluation for user %d: %w", user.ID, err)
 		}
 	}
+	notify, allClear := resolveNotification(user, decision, prev)
 
-	// 天気コードに基づき通知トリガー設定
-	notify := false
-	for _, code := range weatherCodes {
-		rule, err := u.weatherRuleRepo.GetRule(ctx, code)
-		if err != nil {
-			fmt.Printf("Error retrieving rule for code %s: %v\n", code, err)
-			continue
-		}
-		fmt.Printf("Retrieved rule for code %s: %+v\n", code, rule)
-		if rule.IsNotifyTrigger {
-			notify = true
-			break
-		}
+	result := &UserDecision{
+		UserID:          user.ID,
+		TargetDate:      targetDate.Format("2006-01-02"),
+		IsNotifyTrigger: decision.Notify,
+		Notify:          notify,
+		AllClear:        allClear,
+		WeatherCodes:    decision.WeatherCodes,
+		MatchedCodes:    decision.MatchedCodes,
+		MatchedPops:     decision.MatchedPops,
+	}
+	switch {
+	case notify && allClear:
+		result.Message = allClearMessage(targetDate)
+	case notify:
+		result.Message = rainMessage(targetDate, decision.MostSevere, decision.MatchedPops)
+	}
+
+	outcome := outcomeSkipped
+	if notify {
+		outcome = outcomeNotified
+	}
+	if opts.DryRun {
+		return result, outcome, nil
 	}
 
 	// notification_historyに記載
+	// 判定と送る文面を送る前に書き込み、送れなかった通知は dispatcher が再送する
 	history := &entity.NotificationHistory{
-		UserID:           user.ID,
-		NotificationTime: time.Now().In(utils.JST),
-		WeatherData:      body,
-		IsNotifyTrigger:  notify,
-		WeatherCodes:     weatherCodes,
+		UserID:             user.ID,
+		NotificationType:   notificationType,
+		NotificationTime:   now,
+		TargetDate:         targetDate,
+		IsNotifyTrigger:    decision.Notify,
+		WeatherCodes:       decision.WeatherCodes,
+		MatchedPops:        decision.MatchedPops,
+		ForecastSnapshotID: &snapshotID,
+	}
+	if notify {
+		pendingHistory(history, result.Message, now)
+	}
+	if err := u.notificationRepo.InsertNotificationHistory(ctx, history); err != nil {
+		return result, outcomeEvaluated, fmt.Errorf("failed to save notification history for user %d: %w", user.ID, err)
 	}
 
-	go func(hist *entity.NotificationHistory) {
-		// HTTPリクエストのコンテキストに依存せずに処理を継続
-		if err := u.notificationRepo.InsertNotificationHistory(context.Background(), hist); err != nil {
-			fmt.Printf("failed to insert notification history for user %d: %v\n", hist.UserID, err)
-		}
-	}(history)
+	if !notify {
+		fmt.Printf("User %d: 通知不要\n", user.ID)
+		return result, outcome, nil
+	}
+	if err := deliverNotification(ctx, u.notificationRepo, u.notifier, user, history); err != nil {
+		fmt.Printf("User %d: 通知に失敗したので再送します: %v\n", user.ID, err)
+		return result, outcomeQueued, nil
+	}
+	return result, outcome, nil
+}
 
-	// コンソール出力
-	if notify {
-		fmt.Printf("User %d: 通知を送信します。天気コード: %v\n", user.ID, weatherCodes)
-	} else {
-		fmt.Printf("User %d: 通知不要", user.ID)
+// loadForecastForUser はユーザーの選択エリアの階層情報と、そのオフィスの予報を取得する
+func (u *weatherUsecase) loadForecastForUser(ctx context.Context, user *entity.User, loader *forecastLoader) (*entity.HierarchyArea, *loadedForecast, error) {
+	// ユーザーの選択エリアから改装情報を取得
+	hierarchy, err := u.areaUC.GetHierarchy(ctx, fmt.Sprint(user.SelectedAreaID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get hierarchy for user %d: %w", user.ID, err)
+	}
+	if hierarchy == nil {
+		return nil, nil, fmt.Errorf("no hierarchy found %s for user %d", user.SelectedAreaID, user.ID)
+	}
+
+	// JMAエンドポイントから天気データを取得
+	loaded, err := loader.Load(ctx, hierarchy.Office.ID)
+	if err != nil {
+		return nil, nil, err
 	}
+	return hierarchy, loaded, nil
+}
 
-	return nil
+// claimDelivery はユーザー・対象日・通知種別の組を now に処理中として記録する
+// 記録したまま履歴を書く前にプロセスが落ちたときは、deliveryClaimLease が過ぎれば後の実行で記録し直す
+func (u *weatherUsecase) claimDelivery(ctx context.Context, userID int, targetDate time.Time, notificationType string, now time.Time) (bool, error) {
+	lease := max(deliveryClaimLease, 2*u.batchConfig.userTimeout())
+	return u.deliveryRepo.ClaimDelivery(ctx, userID, targetDate, notificationType, now, now.Add(-lease))
 }
 
-func (u *weatherUsecase) ProcessWeatherForUsersInTimeRange(ctx context.Context, start, end time.Time) error {
+// releaseDelivery は処理に失敗したユーザーの記録を消し、後の実行で処理し直せるようにする
+func (u *weatherUsecase) releaseDelivery(userID int, targetDate time.Time, notificationType string) {
+	// ユーザーごとのタイムアウトを過ぎていても消せるように、リクエストのコンテキストは使わない
+	if err := u.deliveryRepo.ReleaseDelivery(context.Background(), userID, targetDate, notificationType); err != nil {
+		fmt.Printf("failed to release delivery for user %d: %v\n", userID, err)
+	}
+}
+
+// ProcessWeatherForUsersInTimeRange は通知時刻が範囲内のユーザーを処理し、結果を返す
+// ユーザーごとの失敗は BatchResult に集め、error はユーザーの取得に失敗したときだけ返す
+// ドライランでは各ユーザーの判定結果も返し、週間予報は処理しない
+func (u *weatherUsecase) ProcessWeatherForUsersInTimeRange(ctx context.Context, start, end time.Time, opts ProcessOptions) (*BatchResult, error) {
 	// 指定時間帯のユーザーを取得
 	users, err := u.userRepo.FindUserByNotifyTimeRange(ctx, start, end)
 	if err != nil {
-		return fmt.Errorf("failed to find users by notify time range: %w", err)
+		return nil, fmt.Errorf("failed to find users by notify time range: %w", err)
+	}
+
+	// 同じオフィスの予報は実行中に1回だけ取得する
+	loader := u.newLoader(ctx, opts)
+
+	now := time.Now().In(utils.JST)
+
+	var (
+		mu        sync.Mutex
+		decisions []*UserDecision
+	)
+
+	// 各ユーザーに対して天気情報処理を並列に実行する
+	// 件数は日々の通知で数え、週間予報は失敗だけを結果に含める
+	result := runForUsers(ctx, u.batchConfig, users, func(ctx context.Context, user *entity.User) (userOutcome, error) {
+		var errs []error
+		decision, outcome, err := u.processWeatherForUser(ctx, user, loader, entity.NotificationTypeDaily, opts)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if opts.DryRun {
+			if decision != nil {
+				mu.Lock()
+				decisions = append(decisions, decision)
+				mu.Unlock()
+			}
+			return outcome, err
+		}
+		if isWeekAheadTime(user, now) {
+			if err := u.processWeeklyForUser(ctx, user, loader); err != nil {
+				errs = append(errs, fmt.Errorf("weekly forecast: %w", err))
+			}
+		}
+		return outcome, errors.Join(errs...)
+	})
+
+	result.ForecastCacheHits, result.ForecastCacheMisses = loader.cacheStats()
+
+	if opts.DryRun {
+		sort.Slice(decisions, func(i, j int) bool { return decisions[i].UserID < decisions[j].UserID })
+		result.DryRun = true
+		result.Decisions = decisions
+	}
+	return result, nil
+}
+
+// RecheckWeatherForUsers は on_change のユーザーについて、今日の通知時刻を過ぎていれば予報を再判定する
+// 朝の予報から雨に変わった場合などに日中でも通知する
+// ユーザーごとの失敗は BatchResult に集め、error はユーザーの取得に失敗したときだけ返す
+func (u *weatherUsecase) RecheckWeatherForUsers(ctx context.Context) (*BatchResult, error) {
+	users, err := u.userRepo.FindActiveUsersByNotifyMode(ctx, entity.NotifyModeOnChange)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find on_change users: %w", err)
 	}
 
-	// 各ユーザーに対して天気情報処理実行
+	loader := newForecastLoader(ctx, u.forecastFetcher, u.forecastCache, u.snapshotRepo, u.batchConfig.userTimeout())
+	now := time.Now().In(utils.JST)
+
+	// 通知時刻前のユーザーは通常の通知で判定する
+	var targets []*entity.User
 	for _, user := range users {
-		if err := u.ProcessWeatherForUser(ctx, user); err != nil {
-			fmt.Printf("Error processing weather for user %d: %v\n", user.ID, err)
+		if timeOfDay(now) >= timeOfDay(user.NotifyTime) {
+			targets = append(targets, user)
 		}
 	}
-	return nil
+
+	result := runForUsers(ctx, u.batchConfig, targets, func(ctx context.Context, user *entity.User) (userOutcome, error) {
+		_, outcome, err := u.processWeatherForUser(ctx, user, loader, entity.NotificationTypeRecheck, ProcessOptions{})
+		return outcome, err
+	})
+	result.ForecastCacheHits, result.ForecastCacheMisses = loader.cacheStats()
+	return result, nil
 }
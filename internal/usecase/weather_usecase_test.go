@@ -1,20 +1,24 @@
 package usecase_test
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/cache"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
 	"github.com/Isshinfunada/weather-bot/internal/usecase"
 	"github.com/Isshinfunada/weather-bot/internal/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // モックの定義
@@ -30,13 +34,177 @@ func (m *MockWeatherRuleRepo) GetRule(ctx context.Context, weatherCode string) (
 	return rule, args.Error(1)
 }
 
-type MockNotificationRepo struct{ mock.Mock }
+func (m *MockWeatherRuleRepo) ListRules(ctx context.Context) ([]*entity.WeatherRule, error) {
+	args := m.Called(ctx)
+	var rules []*entity.WeatherRule
+	if args.Get(0) != nil {
+		rules = args.Get(0).([]*entity.WeatherRule)
+	}
+	return rules, args.Error(1)
+}
+
+func (m *MockWeatherRuleRepo) CreateRule(ctx context.Context, rule *entity.WeatherRule) (bool, error) {
+	args := m.Called(ctx, rule)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWeatherRuleRepo) UpdateRule(ctx context.Context, rule *entity.WeatherRule) (bool, error) {
+	args := m.Called(ctx, rule)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWeatherRuleRepo) DeleteRule(ctx context.Context, weatherCode string) (bool, error) {
+	args := m.Called(ctx, weatherCode)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockNotificationRepo struct {
+	mock.Mock
+
+	mu      sync.Mutex
+	updates []entity.NotificationHistory // UpdateDelivery に渡された送信状態
+}
 
 func (m *MockNotificationRepo) InsertNotificationHistory(ctx context.Context, history *entity.NotificationHistory) error {
 	args := m.Called(ctx, history)
 	return args.Error(0)
 }
 
+func (m *MockNotificationRepo) FindNotificationHistoriesBySnapshotID(ctx context.Context, snapshotID int) ([]*entity.NotificationHistory, error) {
+	args := m.Called(ctx, snapshotID)
+	var histories []*entity.NotificationHistory
+	if args.Get(0) != nil {
+		histories = args.Get(0).([]*entity.NotificationHistory)
+	}
+	return histories, args.Error(1)
+}
+
+func (m *MockNotificationRepo) FindLatestEvaluation(ctx context.Context, userID int, targetDate time.Time) (*entity.NotificationHistory, error) {
+	args := m.Called(ctx, userID, targetDate)
+	var history *entity.NotificationHistory
+	if args.Get(0) != nil {
+		history = args.Get(0).(*entity.NotificationHistory)
+	}
+	return history, args.Error(1)
+}
+
+func (m *MockNotificationRepo) FindEvaluationsInRange(ctx context.Context, from, to time.Time) ([]*entity.NotificationHistory, error) {
+	args := m.Called(ctx, from, to)
+	var histories []*entity.NotificationHistory
+	if args.Get(0) != nil {
+		histories = args.Get(0).([]*entity.NotificationHistory)
+	}
+	return histories, args.Error(1)
+}
+
+func (m *MockNotificationRepo) ClaimPendingDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*entity.NotificationHistory, error) {
+	args := m.Called(ctx, now, leaseUntil, limit)
+	var histories []*entity.NotificationHistory
+	if args.Get(0) != nil {
+		histories = args.Get(0).([]*entity.NotificationHistory)
+	}
+	return histories, args.Error(1)
+}
+
+// UpdateDelivery は期待値を設定しなくても呼べるよう、渡された送信状態を記録するだけにする
+func (m *MockNotificationRepo) UpdateDelivery(ctx context.Context, history *entity.NotificationHistory) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updates = append(m.updates, *history)
+	return nil
+}
+
+// Updates は UpdateDelivery に渡された送信状態を呼ばれた順に返す
+func (m *MockNotificationRepo) Updates() []entity.NotificationHistory {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]entity.NotificationHistory(nil), m.updates...)
+}
+
+// insertedHistories は InsertNotificationHistory に渡された履歴を呼ばれた順に保持する
+type insertedHistories struct {
+	mu        sync.Mutex
+	histories []*entity.NotificationHistory
+}
+
+// expectInsertHistory は InsertNotificationHistory を受け付け、渡された履歴を記録する
+func expectInsertHistory(m *MockNotificationRepo) *insertedHistories {
+	inserted := &insertedHistories{}
+	m.On("InsertNotificationHistory", mock.Anything, mock.AnythingOfType("*entity.NotificationHistory")).
+		Run(func(args mock.Arguments) {
+			inserted.mu.Lock()
+			defer inserted.mu.Unlock()
+			inserted.histories = append(inserted.histories, args.Get(1).(*entity.NotificationHistory))
+		}).
+		Return(nil)
+	return inserted
+}
+
+// next は保存された順に履歴を1件返す。保存されていなければテストを失敗させる
+func (h *insertedHistories) next(t *testing.T) *entity.NotificationHistory {
+	t.Helper()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	require.NotEmpty(t, h.histories, "notification history was not inserted")
+	history := h.histories[0]
+	h.histories = h.histories[1:]
+	return history
+}
+
+// StubDeliveryRepo は処理済みの組をメモリに保持するスタブです
+type StubDeliveryRepo struct {
+	mu        sync.Mutex
+	delivered map[string]bool
+}
+
+func deliveryKey(userID int, targetDate time.Time, notificationType string) string {
+	return fmt.Sprintf("%d/%s/%s", userID, targetDate.Format("2006-01-02"), notificationType)
+}
+
+func (s *StubDeliveryRepo) ClaimDelivery(ctx context.Context, userID int, targetDate time.Time, notificationType string, claimedAt, staleBefore time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.delivered == nil {
+		s.delivered = make(map[string]bool)
+	}
+	key := deliveryKey(userID, targetDate, notificationType)
+	if s.delivered[key] {
+		return false, nil
+	}
+	s.delivered[key] = true
+	return true, nil
+}
+
+func (s *StubDeliveryRepo) ReleaseDelivery(ctx context.Context, userID int, targetDate time.Time, notificationType string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.delivered, deliveryKey(userID, targetDate, notificationType))
+	return nil
+}
+
+// StubSnapshotRepo は保存されたスナップショットに連番のIDを振るスタブです
+type StubSnapshotRepo struct {
+	mu        sync.Mutex
+	snapshots []*entity.ForecastSnapshot
+}
+
+func (s *StubSnapshotRepo) SaveSnapshot(ctx context.Context, snapshot *entity.ForecastSnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots = append(s.snapshots, snapshot)
+	snapshot.ID = len(s.snapshots)
+	return nil
+}
+
+func (s *StubSnapshotRepo) FindSnapshotByID(ctx context.Context, id int) (*entity.ForecastSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id <= 0 || id > len(s.snapshots) {
+		return nil, nil
+	}
+	return s.snapshots[id-1], nil
+}
+
 type MockAreaUC struct{ mock.Mock }
 
 func (m *MockAreaUC) GetHierarchy(ctx context.Context, class20ID string) (*entity.HierarchyArea, error) {
@@ -65,12 +233,56 @@ func (d *DummyUserRepo) DeleteUser(ctx context.Context, userID int) error
 func (d *DummyUserRepo) FindUserByNotifyTimeRange(ctx context.Context, start, end time.Time) ([]*entity.User, error) {
 	return nil, nil
 }
+func (d *DummyUserRepo) FindActiveUsersByOfficeID(ctx context.Context, officeID string) ([]*entity.User, error) {
+	return nil, nil
+}
+func (d *DummyUserRepo) FindActiveUsersByNotifyMode(ctx context.Context, notifyMode string) ([]*entity.User, error) {
+	return nil, nil
+}
+
+type MockForecastFetcher struct{ mock.Mock }
+
+func (m *MockForecastFetcher) FetchForecast(ctx context.Context, officeID string) (*jma.Forecast, error) {
+	args := m.Called(ctx, officeID)
+	var forecast *jma.Forecast
+	if args.Get(0) != nil {
+		forecast = args.Get(0).(*jma.Forecast)
+	}
+	return forecast, args.Error(1)
+}
 
-// HTTPリクエストをモックするためのRoundTripper定義
-type roundTripperFunc func(req *http.Request) (*http.Response, error)
+// newTestForecast は当日の timeDefines に天気コードを並べた短期予報を生成する
+func newTestForecast(class10ID string, codes ...string) *jma.Forecast {
+	return newTestForecastAt(time.Now().In(utils.JST), class10ID, codes...)
+}
 
-func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
-	return f(req)
+// newTestForecastAt は reportDatetime に発表した、その日の timeDefines に天気コードを並べた短期予報を生成する
+// 判定の結果を見ないテストは固定の日付を渡し、日付が変わる時刻に実行しても結果が変わらないようにする
+func newTestForecastAt(reportDatetime time.Time, class10ID string, codes ...string) *jma.Forecast {
+	d := reportDatetime.In(utils.JST)
+	timeDefines := make([]time.Time, len(codes))
+	for i := range codes {
+		timeDefines[i] = time.Date(d.Year(), d.Month(), d.Day(), 0, i, 0, 0, utils.JST)
+	}
+	return &jma.Forecast{
+		Reports: []jma.Report{
+			{
+				ReportDatetime: reportDatetime,
+				TimeSeries: []jma.TimeSeries{
+					{
+						TimeDefines: timeDefines,
+						Areas: []jma.AreaForecast{
+							{
+								Area:         jma.Area{Code: class10ID, Name: "TestArea"},
+								WeatherCodes: codes,
+							},
+						},
+					},
+				},
+			},
+		},
+		Raw: []byte(`[]`),
+	}
 }
 
 // MockUserRepoForRange: FindUsersByNotifyTimeRangeをモックするための構造体
@@ -87,6 +299,17 @@ func (m *MockUserRepoForRange) FindUserByLINEUserID(ctx context.Context, LINEUse
 }
 func (m *MockUserRepoForRange) UpdateUser(ctx context.Context, user *entity.User) error { return nil }
 func (m *MockUserRepoForRange) DeleteUser(ctx context.Context, userID int) error        { return nil }
+func (m *MockUserRepoForRange) FindActiveUsersByOfficeID(ctx context.Context, officeID string) ([]*entity.User, error) {
+	return nil, nil
+}
+func (m *MockUserRepoForRange) FindActiveUsersByNotifyMode(ctx context.Context, notifyMode string) ([]*entity.User, error) {
+	args := m.Called(ctx, notifyMode)
+	var users []*entity.User
+	if val := args.Get(0); val != nil {
+		users = val.([]*entity.User)
+	}
+	return users, args.Error(1)
+}
 func (m *MockUserRepoForRange) FindUserByNotifyTimeRange(ctx context.Context, start, end time.Time) ([]*entity.User, error) {
 	args := m.Called(ctx, start, end)
 	var users []*entity.User
@@ -96,6 +319,13 @@ func (m *MockUserRepoForRange) FindUserByNotifyTimeRange(ctx context.Context, st
 	return users, args.Error(1)
 }
 
+// newNopNotifier は通知内容を確認しないテスト用の Notifier を返す
+func newNopNotifier() *MockNotifier {
+	n := new(MockNotifier)
+	n.On("Notify", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	return n
+}
+
 func TestProcessWeatherForUser(t *testing.T) {
 	ctx := context.Background()
 
@@ -122,55 +352,286 @@ func TestProcessWeatherForUser(t *testing.T) {
 		On("InsertNotificationHistory", mock.Anything, mock.AnythingOfType("*entity.NotificationHistory")).
 		Return(nil)
 
-	// 対象日を決める
-	targetDate := time.Now().In(utils.JST).Format("2006-01-02")
-
-	// 偽のJSONレスポンスを作成（対象日を含むtimeDefinesを追加）
-	fakeResponse := []map[string]interface{}{
-		{
-			"timeSeries": []interface{}{
-				map[string]interface{}{
-					"timeDefines": []interface{}{targetDate + "T11:00:00+09:00"},
-					"areas": []interface{}{
-						map[string]interface{}{
-							"area": map[string]interface{}{
-								"code": "testClass10",
-								"name": "TestArea",
-							},
-							"weatherCodes": []interface{}{"123", "456"},
-						},
-					},
-				},
-			},
-		},
-	}
-	responseBody, err := json.Marshal(fakeResponse)
-	assert.NoError(t, err)
-
-	originalTransport := http.DefaultTransport
-	http.DefaultTransport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
-		return &http.Response{
-			StatusCode: 200,
-			Body:       io.NopCloser(bytes.NewReader(responseBody)),
-			Header:     make(http.Header),
-		}, nil
-	})
-	defer func() { http.DefaultTransport = originalTransport }()
+	// 対象日の天気コードを含む予報を返す
+	mockFetcher := new(MockForecastFetcher)
+	mockFetcher.
+		On("FetchForecast", mock.Anything, "testOffice").
+		Return(newTestForecast("testClass10", "123", "456"), nil)
 
-	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, mockNotificationRepo, dummyUserRepo, mockAreaUC)
+	mockNotifier := new(MockNotifier)
 	user := &entity.User{
 		ID:             1,
 		SelectedAreaID: "1234567",
 	}
+	mockNotifier.On("Notify", ctx, user, mock.MatchedBy(func(message string) bool {
+		return strings.Contains(message, "雨の予報です")
+	})).Return(nil).Once()
 
-	err = weatherUC.ProcessWeatherForUser(ctx, user)
-	assert.NoError(t, err)
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, dummyUserRepo, mockAreaUC, mockFetcher, nil, &StubSnapshotRepo{}, mockNotifier, usecase.BatchConfig{})
 
-	time.Sleep(100 * time.Millisecond)
+	_, err := weatherUC.ProcessWeatherForUser(ctx, user, usecase.ProcessOptions{})
+	assert.NoError(t, err)
 
 	mockAreaUC.AssertExpectations(t)
+	mockFetcher.AssertExpectations(t)
 	mockRuleRepo.AssertExpectations(t)
 	mockNotificationRepo.AssertExpectations(t)
+	mockNotifier.AssertExpectations(t)
+}
+
+func TestProcessWeatherForUser_PopThreshold(t *testing.T) {
+	ctx := context.Background()
+
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockAreaUC := new(MockAreaUC)
+	mockFetcher := new(MockForecastFetcher)
+
+	hierarchy := &entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "testOffice"},
+		Class10: &entity.AreaClass10{ID: "testClass10"},
+	}
+	mockAreaUC.On("GetHierarchy", ctx, mock.Anything).Return(hierarchy, nil)
+
+	// 天気コードは晴れだが、12-18時の降水確率が60%
+	forecast := newTestForecast("testClass10", "100")
+	now := time.Now().In(utils.JST)
+	forecast.Reports[0].TimeSeries = append(forecast.Reports[0].TimeSeries, jma.TimeSeries{
+		TimeDefines: []time.Time{
+			time.Date(now.Year(), now.Month(), now.Day(), 6, 0, 0, 0, utils.JST),
+			time.Date(now.Year(), now.Month(), now.Day(), 12, 0, 0, 0, utils.JST),
+			time.Date(now.Year(), now.Month(), now.Day(), 18, 0, 0, 0, utils.JST),
+		},
+		Areas: []jma.AreaForecast{
+			{Area: jma.Area{Code: "testClass10"}, Pops: []string{"20", "60", "40"}},
+		},
+	})
+	mockFetcher.On("FetchForecast", mock.Anything, "testOffice").Return(forecast, nil)
+	mockRuleRepo.On("GetRule", ctx, "100").Return(&entity.WeatherRule{WeatherCode: "100", IsNotifyTrigger: false}, nil)
+
+	inserted := expectInsertHistory(mockNotificationRepo)
+
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, &DummyUserRepo{}, mockAreaUC, mockFetcher, nil, &StubSnapshotRepo{}, newNopNotifier(), usecase.BatchConfig{})
+
+	threshold := 50
+	_, err := weatherUC.ProcessWeatherForUser(ctx, &entity.User{ID: 1, SelectedAreaID: "1234567", PopThreshold: &threshold}, usecase.ProcessOptions{})
+	require.NoError(t, err)
+
+	history := inserted.next(t)
+	assert.True(t, history.IsNotifyTrigger)
+	require.Len(t, history.MatchedPops, 1)
+	assert.Equal(t, 60, history.MatchedPops[0].Pop)
+	assert.Equal(t, 12, history.MatchedPops[0].Start.Hour())
+
+	// 閾値を設定していないユーザーは天気コードだけで判定する
+	_, err = weatherUC.ProcessWeatherForUser(ctx, &entity.User{ID: 2, SelectedAreaID: "1234567"}, usecase.ProcessOptions{})
+	require.NoError(t, err)
+
+	history = inserted.next(t)
+	assert.False(t, history.IsNotifyTrigger)
+	assert.Empty(t, history.MatchedPops)
+}
+
+func TestProcessWeatherForUser_TimeWindows(t *testing.T) {
+	ctx := context.Background()
+
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockAreaUC := new(MockAreaUC)
+	mockFetcher := new(MockForecastFetcher)
+
+	hierarchy := &entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "testOffice"},
+		Class10: &entity.AreaClass10{ID: "testClass10"},
+	}
+	mockAreaUC.On("GetHierarchy", ctx, mock.Anything).Return(hierarchy, nil)
+
+	// 朝は降水確率10%、夜だけ70%
+	forecast := newTestForecast("testClass10", "100")
+	now := time.Now().In(utils.JST)
+	at := func(hour int) time.Time {
+		return time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, utils.JST)
+	}
+	forecast.Reports[0].TimeSeries = append(forecast.Reports[0].TimeSeries, jma.TimeSeries{
+		TimeDefines: []time.Time{at(0), at(6), at(12), at(18)},
+		Areas: []jma.AreaForecast{
+			{Area: jma.Area{Code: "testClass10"}, Pops: []string{"0", "10", "20", "70"}},
+		},
+	})
+	mockFetcher.On("FetchForecast", mock.Anything, "testOffice").Return(forecast, nil)
+	mockRuleRepo.On("GetRule", ctx, "100").Return(&entity.WeatherRule{WeatherCode: "100", IsNotifyTrigger: false}, nil)
+
+	inserted := expectInsertHistory(mockNotificationRepo)
+
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, &DummyUserRepo{}, mockAreaUC, mockFetcher, nil, &StubSnapshotRepo{}, newNopNotifier(), usecase.BatchConfig{})
+
+	// 朝の通勤時間帯だけなら通知しない
+	morning := &entity.User{ID: 1, SelectedAreaID: "1234567", TimeWindows: []entity.TimeWindow{{Start: "07:00", End: "09:00"}}}
+	_, err := weatherUC.ProcessWeatherForUser(ctx, morning, usecase.ProcessOptions{})
+	require.NoError(t, err)
+	history := inserted.next(t)
+	assert.False(t, history.IsNotifyTrigger)
+	assert.Empty(t, history.MatchedPops)
+
+	// 帰宅時間帯が夜のブロックに重なれば通知する
+	threshold := 50
+	commuter := &entity.User{ID: 2, SelectedAreaID: "1234567", PopThreshold: &threshold, TimeWindows: []entity.TimeWindow{
+		{Start: "07:00", End: "09:00"}, {Start: "18:00", End: "20:00"},
+	}}
+	_, err = weatherUC.ProcessWeatherForUser(ctx, commuter, usecase.ProcessOptions{})
+	require.NoError(t, err)
+	history = inserted.next(t)
+	assert.True(t, history.IsNotifyTrigger)
+	require.Len(t, history.MatchedPops, 1)
+	assert.Equal(t, 70, history.MatchedPops[0].Pop)
+
+	// 降水確率の閾値が未設定なら、時間帯が重なっても降水確率では通知しない
+	noThreshold := &entity.User{ID: 3, SelectedAreaID: "1234567", TimeWindows: []entity.TimeWindow{{Start: "18:00", End: "20:00"}}}
+	_, err = weatherUC.ProcessWeatherForUser(ctx, noThreshold, usecase.ProcessOptions{})
+	require.NoError(t, err)
+	history = inserted.next(t)
+	assert.False(t, history.IsNotifyTrigger)
+	assert.Empty(t, history.MatchedPops)
+}
+
+func TestProcessWeatherForUser_TargetDay(t *testing.T) {
+	ctx := context.Background()
+
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockAreaUC := new(MockAreaUC)
+	mockFetcher := new(MockForecastFetcher)
+
+	hierarchy := &entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "testOffice"},
+		Class10: &entity.AreaClass10{ID: "testClass10"},
+	}
+	mockAreaUC.On("GetHierarchy", ctx, mock.Anything).Return(hierarchy, nil)
+
+	// 今日は晴れ、明日は雨
+	now := time.Now().In(utils.JST)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, utils.JST)
+	tomorrow := today.AddDate(0, 0, 1)
+	forecast := &jma.Forecast{Reports: []jma.Report{{
+		ReportDatetime: now,
+		TimeSeries: []jma.TimeSeries{{
+			TimeDefines: []time.Time{today, tomorrow},
+			Areas: []jma.AreaForecast{
+				{Area: jma.Area{Code: "testClass10"}, WeatherCodes: []string{"100", "300"}},
+			},
+		}},
+	}}}
+	mockFetcher.On("FetchForecast", mock.Anything, "testOffice").Return(forecast, nil)
+	mockRuleRepo.On("GetRule", ctx, "100").Return(&entity.WeatherRule{WeatherCode: "100", IsNotifyTrigger: false}, nil)
+	mockRuleRepo.On("GetRule", ctx, "300").Return(&entity.WeatherRule{WeatherCode: "300", IsNotifyTrigger: true}, nil)
+
+	inserted := expectInsertHistory(mockNotificationRepo)
+
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, &DummyUserRepo{}, mockAreaUC, mockFetcher, nil, &StubSnapshotRepo{}, newNopNotifier(), usecase.BatchConfig{})
+
+	cases := []struct {
+		name       string
+		targetDay  string
+		notifyTime time.Time
+		wantDate   time.Time
+		wantCodes  []string
+		wantNotify bool
+	}{
+		{"default is today", "", time.Date(0, 1, 1, 21, 0, 0, 0, utils.JST), today, []string{"100"}, false},
+		{"tomorrow", entity.TargetDayTomorrow, time.Date(0, 1, 1, 7, 0, 0, 0, utils.JST), tomorrow, []string{"300"}, true},
+		{"auto in the morning", entity.TargetDayAuto, time.Date(0, 1, 1, 7, 0, 0, 0, utils.JST), today, []string{"100"}, false},
+		{"auto in the evening", entity.TargetDayAuto, time.Date(0, 1, 1, 21, 0, 0, 0, utils.JST), tomorrow, []string{"300"}, true},
+	}
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 同じユーザー・対象日は1回しか処理しないので、ケースごとに別のユーザーにする
+			user := &entity.User{ID: i + 1, SelectedAreaID: "1234567", TargetDay: tc.targetDay, NotifyTime: tc.notifyTime}
+			_, err := weatherUC.ProcessWeatherForUser(ctx, user, usecase.ProcessOptions{})
+			require.NoError(t, err)
+
+			history := inserted.next(t)
+			assert.True(t, history.TargetDate.Equal(tc.wantDate))
+			assert.Equal(t, tc.wantCodes, history.WeatherCodes)
+			assert.Equal(t, tc.wantNotify, history.IsNotifyTrigger)
+		})
+	}
+}
+
+func TestProcessWeatherForUser_FetchError(t *testing.T) {
+	ctx := context.Background()
+
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockAreaUC := new(MockAreaUC)
+	mockFetcher := new(MockForecastFetcher)
+
+	hierarchy := &entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "testOffice"},
+		Class10: &entity.AreaClass10{ID: "testClass10"},
+	}
+	mockAreaUC.On("GetHierarchy", ctx, mock.Anything).Return(hierarchy, nil)
+	mockFetcher.On("FetchForecast", mock.Anything, "testOffice").Return(nil, errors.New("connection refused"))
+
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, &DummyUserRepo{}, mockAreaUC, mockFetcher, nil, &StubSnapshotRepo{}, newNopNotifier(), usecase.BatchConfig{})
+	_, err := weatherUC.ProcessWeatherForUser(ctx, &entity.User{ID: 1, SelectedAreaID: "1234567"}, usecase.ProcessOptions{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to fetch weather data")
+
+	mockRuleRepo.AssertNotCalled(t, "GetRule", mock.Anything, mock.Anything)
+	mockNotificationRepo.AssertNotCalled(t, "InsertNotificationHistory", mock.Anything, mock.Anything)
+}
+
+func TestProcessWeatherForUser_WithJMAClient(t *testing.T) {
+	ctx := context.Background()
+
+	// JMA の代わりにローカルのフィクスチャサーバーから予報を返す
+	now := time.Now().In(utils.JST)
+	fixture := fmt.Sprintf(`[{
+		"reportDatetime": "%[1]sT05:00:00+09:00",
+		"timeSeries": [{
+			"timeDefines": ["%[1]sT05:00:00+09:00"],
+			"areas": [{"area": {"name": "TestArea", "code": "testClass10"}, "weatherCodes": ["300"]}]
+		}]
+	}]`, now.Format("2006-01-02"))
+
+	var requestedPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		w.Write([]byte(fixture))
+	}))
+	defer srv.Close()
+
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockAreaUC := new(MockAreaUC)
+
+	hierarchy := &entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "testOffice"},
+		Class10: &entity.AreaClass10{ID: "testClass10"},
+	}
+	mockAreaUC.On("GetHierarchy", ctx, mock.Anything).Return(hierarchy, nil)
+	mockRuleRepo.On("GetRule", ctx, "300").Return(&entity.WeatherRule{WeatherCode: "300", IsNotifyTrigger: true}, nil)
+
+	inserted := expectInsertHistory(mockNotificationRepo)
+
+	client := jma.NewClient(jma.Config{BaseURL: srv.URL, HTTPClient: srv.Client()})
+	snapshotRepo := &StubSnapshotRepo{}
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, &DummyUserRepo{}, mockAreaUC, client, nil, snapshotRepo, newNopNotifier(), usecase.BatchConfig{})
+
+	_, err := weatherUC.ProcessWeatherForUser(ctx, &entity.User{ID: 1, SelectedAreaID: "1234567"}, usecase.ProcessOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "/forecast/data/forecast/testOffice.json", requestedPath)
+
+	history := inserted.next(t)
+	assert.True(t, history.IsNotifyTrigger)
+	assert.Equal(t, []string{"300"}, history.WeatherCodes)
+	// 予報本体は履歴ではなくスナップショット側に保存される
+	assert.Empty(t, history.WeatherData)
+	require.NotNil(t, history.ForecastSnapshotID)
+	snapshot, err := snapshotRepo.FindSnapshotByID(ctx, *history.ForecastSnapshotID)
+	require.NoError(t, err)
+	assert.Equal(t, "testOffice", snapshot.OfficeID)
+	assert.JSONEq(t, fixture, string(snapshot.Data))
 }
 
 func TestProcessWeatherForUsersInTimeRange(t *testing.T) {
@@ -182,7 +643,9 @@ func TestProcessWeatherForUsersInTimeRange(t *testing.T) {
 	mockAreaUC := new(MockAreaUC)
 	mockUserRepo := new(MockUserRepoForRange)
 
-	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, mockNotificationRepo, mockUserRepo, mockAreaUC)
+	mockFetcher := new(MockForecastFetcher)
+
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, mockUserRepo, mockAreaUC, mockFetcher, nil, &StubSnapshotRepo{}, newNopNotifier(), usecase.BatchConfig{})
 
 	startTime := time.Date(0, 1, 1, 8, 0, 0, 0, utils.JST)
 	endTime := time.Date(0, 1, 1, 9, 0, 0, 0, utils.JST)
@@ -202,58 +665,351 @@ func TestProcessWeatherForUsersInTimeRange(t *testing.T) {
 		Class10: &entity.AreaClass10{ID: "testClass10"},
 	}
 	mockAreaUC.
-		On("GetHierarchy", ctx, fmt.Sprint(user.SelectedAreaID)).
+		On("GetHierarchy", mock.Anything, fmt.Sprint(user.SelectedAreaID)).
 		Return(hierarchy, nil)
 
-	mockRuleRepo.On("GetRule", ctx, "123").Return(&entity.WeatherRule{WeatherCode: "123", IsNotifyTrigger: false}, nil)
-	mockRuleRepo.On("GetRule", ctx, "456").Return(&entity.WeatherRule{WeatherCode: "456", IsNotifyTrigger: true}, nil)
+	mockRuleRepo.On("GetRule", mock.Anything, "123").Return(&entity.WeatherRule{WeatherCode: "123", IsNotifyTrigger: false}, nil)
+	mockRuleRepo.On("GetRule", mock.Anything, "456").Return(&entity.WeatherRule{WeatherCode: "456", IsNotifyTrigger: true}, nil)
 
 	mockNotificationRepo.
 		On("InsertNotificationHistory", mock.Anything, mock.AnythingOfType("*entity.NotificationHistory")).
 		Return(nil)
 
-	// 対象日を決める
-	targetDate := time.Now().In(utils.JST).Format("2006-01-02")
-
-	// 偽のJSONレスポンスを作成（対象日を含むtimeDefinesを追加）
-	fakeResponse := []map[string]interface{}{
-		{
-			"timeSeries": []interface{}{
-				map[string]interface{}{
-					"timeDefines": []interface{}{targetDate + "T11:00:00+09:00"},
-					"areas": []interface{}{
-						map[string]interface{}{
-							"area": map[string]interface{}{
-								"code": "testClass10",
-								"name": "TestArea",
-							},
-							"weatherCodes": []interface{}{"123", "456"},
-						},
-					},
-				},
-			},
-		},
-	}
-	responseBody, err := json.Marshal(fakeResponse)
+	mockFetcher.
+		On("FetchForecast", mock.Anything, "testOffice").
+		Return(newTestForecast("testClass10", "123", "456"), nil)
+
+	result, err := weatherUC.ProcessWeatherForUsersInTimeRange(ctx, startTime, endTime, usecase.ProcessOptions{})
 	assert.NoError(t, err)
+	assert.Empty(t, result.Failures)
 
-	originalTransport := http.DefaultTransport
-	http.DefaultTransport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
-		return &http.Response{
-			StatusCode: 200,
-			Body:       io.NopCloser(bytes.NewReader(responseBody)),
-			Header:     make(http.Header),
-		}, nil
-	})
-	defer func() { http.DefaultTransport = originalTransport }()
+	mockUserRepo.AssertExpectations(t)
+	mockAreaUC.AssertExpectations(t)
+	mockFetcher.AssertExpectations(t)
+	mockRuleRepo.AssertExpectations(t)
+	mockNotificationRepo.AssertExpectations(t)
+}
+
+func TestProcessWeatherForUsersInTimeRange_FetchesEachOfficeOnce(t *testing.T) {
+	ctx := context.Background()
+
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockAreaUC := new(MockAreaUC)
+	mockUserRepo := new(MockUserRepoForRange)
+	mockFetcher := new(MockForecastFetcher)
+
+	startTime := time.Date(0, 1, 1, 8, 0, 0, 0, utils.JST)
+	endTime := time.Date(0, 1, 1, 9, 0, 0, 0, utils.JST)
 
-	err = weatherUC.ProcessWeatherForUsersInTimeRange(ctx, startTime, endTime)
+	// 東京の3ユーザーと大阪の1ユーザー
+	users := []*entity.User{
+		{ID: 1, SelectedAreaID: "1310100"},
+		{ID: 2, SelectedAreaID: "1310200"},
+		{ID: 3, SelectedAreaID: "1310300"},
+		{ID: 4, SelectedAreaID: "2710000"},
+	}
+	mockUserRepo.On("FindUserByNotifyTimeRange", ctx, startTime, endTime).Return(users, nil)
+
+	tokyo := &entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "130000"},
+		Class10: &entity.AreaClass10{ID: "130010"},
+	}
+	osaka := &entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "270000"},
+		Class10: &entity.AreaClass10{ID: "270000"},
+	}
+	mockAreaUC.On("GetHierarchy", mock.Anything, "1310100").Return(tokyo, nil)
+	mockAreaUC.On("GetHierarchy", mock.Anything, "1310200").Return(tokyo, nil)
+	mockAreaUC.On("GetHierarchy", mock.Anything, "1310300").Return(tokyo, nil)
+	mockAreaUC.On("GetHierarchy", mock.Anything, "2710000").Return(osaka, nil)
+
+	reportDatetime := time.Date(2024, 6, 10, 5, 0, 0, 0, utils.JST)
+	mockFetcher.On("FetchForecast", mock.Anything, "130000").Return(newTestForecastAt(reportDatetime, "130010", "100"), nil).Once()
+	mockFetcher.On("FetchForecast", mock.Anything, "270000").Return(newTestForecastAt(reportDatetime, "270000", "100"), nil).Once()
+
+	mockRuleRepo.On("GetRule", mock.Anything, "100").Return(&entity.WeatherRule{WeatherCode: "100", IsNotifyTrigger: false}, nil)
+	mockNotificationRepo.
+		On("InsertNotificationHistory", mock.Anything, mock.AnythingOfType("*entity.NotificationHistory")).
+		Return(nil)
+
+	snapshotRepo := &StubSnapshotRepo{}
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, mockUserRepo, mockAreaUC, mockFetcher, nil, snapshotRepo, newNopNotifier(), usecase.BatchConfig{})
+	result, err := weatherUC.ProcessWeatherForUsersInTimeRange(ctx, startTime, endTime, usecase.ProcessOptions{})
 	assert.NoError(t, err)
+	assert.Empty(t, result.Failures)
+
+	mockFetcher.AssertNumberOfCalls(t, "FetchForecast", 2)
+	assert.Len(t, snapshotRepo.snapshots, 2)
+	mockFetcher.AssertExpectations(t)
+}
+
+// gatedForecastFetcher は release が閉じられるまで予報を返さない。待っている間に ctx が終われば ctx のエラーを返す
+type gatedForecastFetcher struct {
+	release  chan struct{}
+	forecast *jma.Forecast
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *gatedForecastFetcher) FetchForecast(ctx context.Context, officeID string) (*jma.Forecast, error) {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+	// 終わった ctx で呼ばれたら、release が閉じられていても失敗させる
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	select {
+	case <-f.release:
+		return f.forecast, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (f *gatedForecastFetcher) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func TestProcessWeatherForUsersInTimeRange_SharedFetchOutlivesFirstUser(t *testing.T) {
+	ctx := context.Background()
+
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockAreaUC := new(MockAreaUC)
+	mockUserRepo := new(MockUserRepoForRange)
+
+	startTime := time.Date(0, 1, 1, 8, 0, 0, 0, utils.JST)
+	endTime := time.Date(0, 1, 1, 9, 0, 0, 0, utils.JST)
+
+	users := []*entity.User{
+		{ID: 1, SelectedAreaID: "1310100"},
+		{ID: 2, SelectedAreaID: "1310200"},
+	}
+	mockUserRepo.On("FindUserByNotifyTimeRange", ctx, startTime, endTime).Return(users, nil)
+
+	tokyo := &entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "130000"},
+		Class10: &entity.AreaClass10{ID: "130010"},
+	}
+	fetcher := &gatedForecastFetcher{release: make(chan struct{}), forecast: newTestForecast("130010", "100")}
+	// ユーザー1はタイムアウトしてから予報の取得を始める
+	// 取得がユーザー1の ctx で行われていれば、始めた時点で失敗する
+	mockAreaUC.On("GetHierarchy", mock.Anything, "1310100").Return(tokyo, nil).
+		Run(func(args mock.Arguments) { <-args.Get(0).(context.Context).Done() })
+	// 取得はユーザー2が処理を始めるまで終わらせない
+	mockAreaUC.On("GetHierarchy", mock.Anything, "1310200").Return(tokyo, nil).
+		Run(func(mock.Arguments) { close(fetcher.release) })
+
+	mockRuleRepo.On("GetRule", mock.Anything, "100").Return(&entity.WeatherRule{WeatherCode: "100", IsNotifyTrigger: false}, nil)
+	mockNotificationRepo.
+		On("InsertNotificationHistory", mock.Anything, mock.AnythingOfType("*entity.NotificationHistory")).
+		Return(nil)
 
-	time.Sleep(100 * time.Millisecond)
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, mockUserRepo, mockAreaUC, fetcher, nil, &StubSnapshotRepo{}, newNopNotifier(),
+		usecase.BatchConfig{Workers: 1, UserTimeout: 50 * time.Millisecond, MaxFailureRate: 1})
+	result, err := weatherUC.ProcessWeatherForUsersInTimeRange(ctx, startTime, endTime, usecase.ProcessOptions{})
+	require.NoError(t, err)
+
+	// ユーザー1のタイムアウトで取得を止めず、ユーザー2は同じ取得の結果を使う
+	require.Len(t, result.Failures, 1)
+	assert.Equal(t, 1, result.Failures[0].UserID)
+	assert.ErrorIs(t, result.Failures[0], context.DeadlineExceeded)
+	assert.Equal(t, 1, result.Evaluated)
+	assert.Equal(t, 1, fetcher.Calls())
+}
+
+func TestProcessWeatherForUser_OnChange(t *testing.T) {
+	tests := []struct {
+		name         string
+		code         string
+		prev         *entity.NotificationHistory
+		allClear     bool
+		wantNotify   bool
+		wantContains string
+	}{
+		{name: "sunny to rain", code: "300", prev: &entity.NotificationHistory{IsNotifyTrigger: false}, wantNotify: true, wantContains: "雨の予報です"},
+		{name: "first evaluation", code: "300", prev: nil, wantNotify: true, wantContains: "雨の予報です"},
+		{name: "rain continues", code: "300", prev: &entity.NotificationHistory{IsNotifyTrigger: true}, wantNotify: false},
+		{name: "rain to clear", code: "100", prev: &entity.NotificationHistory{IsNotifyTrigger: true}, wantNotify: false},
+		{name: "rain to clear with all clear", code: "100", prev: &entity.NotificationHistory{IsNotifyTrigger: true}, allClear: true, wantNotify: true, wantContains: "雨の予報は無くなりました"},
+		{name: "sunny continues", code: "100", prev: &entity.NotificationHistory{IsNotifyTrigger: false}, allClear: true, wantNotify: false},
+	}
 
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			mockRuleRepo := new(MockWeatherRuleRepo)
+			mockNotificationRepo := new(MockNotificationRepo)
+			mockAreaUC := new(MockAreaUC)
+			mockFetcher := new(MockForecastFetcher)
+			mockNotifier := new(MockNotifier)
+
+			hierarchy := &entity.HierarchyArea{
+				Office:  &entity.AreaOffice{ID: "testOffice"},
+				Class10: &entity.AreaClass10{ID: "testClass10"},
+			}
+			mockAreaUC.On("GetHierarchy", ctx, mock.Anything).Return(hierarchy, nil)
+			mockFetcher.On("FetchForecast", mock.Anything, "testOffice").Return(newTestForecast("testClass10", tt.code), nil)
+			mockRuleRepo.On("GetRule", ctx, "100").Return(&entity.WeatherRule{WeatherCode: "100", IsNotifyTrigger: false}, nil)
+			mockRuleRepo.On("GetRule", ctx, "300").Return(&entity.WeatherRule{WeatherCode: "300", IsNotifyTrigger: true}, nil)
+			now := time.Now().In(utils.JST)
+			today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, utils.JST)
+			mockNotificationRepo.On("FindLatestEvaluation", ctx, 1, today).Return(tt.prev, nil)
+
+			inserted := expectInsertHistory(mockNotificationRepo)
+
+			user := &entity.User{
+				ID:             1,
+				SelectedAreaID: "1234567",
+				NotifyMode:     entity.NotifyModeOnChange,
+				NotifyAllClear: tt.allClear,
+			}
+			if tt.wantNotify {
+				mockNotifier.On("Notify", ctx, user, mock.MatchedBy(func(message string) bool {
+					return strings.Contains(message, tt.wantContains)
+				})).Return(nil).Once()
+			}
+
+			weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, &DummyUserRepo{}, mockAreaUC, mockFetcher, nil, &StubSnapshotRepo{}, mockNotifier, usecase.BatchConfig{})
+			_, err := weatherUC.ProcessWeatherForUser(ctx, user, usecase.ProcessOptions{})
+			require.NoError(t, err)
+
+			// 通知の有無に関わらず、次回の比較のために判定結果を残す
+			history := inserted.next(t)
+			assert.Equal(t, entity.NotificationTypeDaily, history.NotificationType)
+			assert.Equal(t, tt.code == "300", history.IsNotifyTrigger)
+
+			mockNotificationRepo.AssertExpectations(t)
+			mockNotifier.AssertExpectations(t)
+			if !tt.wantNotify {
+				mockNotifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestRecheckWeatherForUsers(t *testing.T) {
+	ctx := context.Background()
+
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockAreaUC := new(MockAreaUC)
+	mockUserRepo := new(MockUserRepoForRange)
+	mockFetcher := new(MockForecastFetcher)
+	mockNotifier := new(MockNotifier)
+
+	// 通知時刻を過ぎたユーザーと、まだ通知時刻前のユーザー
+	notified := &entity.User{ID: 1, SelectedAreaID: "1310100", NotifyMode: entity.NotifyModeOnChange, NotifyTime: time.Date(0, 1, 1, 0, 0, 0, 0, utils.JST)}
+	pending := &entity.User{ID: 2, SelectedAreaID: "1310100", NotifyMode: entity.NotifyModeOnChange, NotifyTime: time.Date(0, 1, 1, 23, 59, 0, 0, utils.JST)}
+	mockUserRepo.On("FindActiveUsersByNotifyMode", ctx, entity.NotifyModeOnChange).Return([]*entity.User{notified, pending}, nil)
+
+	hierarchy := &entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "130000"},
+		Class10: &entity.AreaClass10{ID: "130010"},
+	}
+	mockAreaUC.On("GetHierarchy", mock.Anything, "1310100").Return(hierarchy, nil)
+	mockFetcher.On("FetchForecast", mock.Anything, "130000").Return(newTestForecast("130010", "300"), nil).Once()
+	mockRuleRepo.On("GetRule", mock.Anything, "300").Return(&entity.WeatherRule{WeatherCode: "300", IsNotifyTrigger: true}, nil)
+
+	// 朝は晴れの判定だった
+	now := time.Now().In(utils.JST)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, utils.JST)
+	mockNotificationRepo.On("FindLatestEvaluation", mock.Anything, 1, today).Return(&entity.NotificationHistory{IsNotifyTrigger: false}, nil)
+	inserted := expectInsertHistory(mockNotificationRepo)
+	mockNotifier.On("Notify", mock.Anything, notified, mock.AnythingOfType("string")).Return(nil).Once()
+
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, mockUserRepo, mockAreaUC, mockFetcher, nil, &StubSnapshotRepo{}, mockNotifier, usecase.BatchConfig{})
+	result, err := weatherUC.RecheckWeatherForUsers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Matched)
+	assert.Equal(t, 1, result.Notified)
+	assert.Empty(t, result.Failures)
+
+	history := inserted.next(t)
+	assert.Equal(t, 1, history.UserID)
+	assert.Equal(t, entity.NotificationTypeRecheck, history.NotificationType)
+	assert.True(t, history.IsNotifyTrigger)
+
+	mockNotificationRepo.AssertNotCalled(t, "FindLatestEvaluation", mock.Anything, 2, mock.Anything)
+	mockNotifier.AssertExpectations(t)
 	mockUserRepo.AssertExpectations(t)
-	mockAreaUC.AssertExpectations(t)
-	mockRuleRepo.AssertExpectations(t)
+}
+
+func TestProcessWeatherForUser_OnChangeComparesSameTargetDate(t *testing.T) {
+	ctx := context.Background()
+
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockAreaUC := new(MockAreaUC)
+	mockFetcher := new(MockForecastFetcher)
+
+	mockAreaUC.On("GetHierarchy", ctx, mock.Anything).Return(&entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "testOffice"},
+		Class10: &entity.AreaClass10{ID: "testClass10"},
+	}, nil)
+	mockFetcher.On("FetchForecast", mock.Anything, "testOffice").Return(newTestForecast("testClass10"), nil)
+
+	// 翌日分を通知するユーザーは、当日分の判定ではなく翌日分の直近の判定と比べる
+	now := time.Now().In(utils.JST)
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, utils.JST)
+	mockNotificationRepo.On("FindLatestEvaluation", ctx, 1, tomorrow).Return(nil, nil).Once()
+
+	weatherUC := usecase.NewWeatherUsecase(new(MockWeatherRuleRepo), &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, &DummyUserRepo{}, mockAreaUC, mockFetcher, nil, &StubSnapshotRepo{}, newNopNotifier(), usecase.BatchConfig{})
+
+	user := &entity.User{ID: 1, SelectedAreaID: "1234567", NotifyMode: entity.NotifyModeOnChange, TargetDay: entity.TargetDayTomorrow}
+	decision, err := weatherUC.ProcessWeatherForUser(ctx, user, usecase.ProcessOptions{DryRun: true})
+	require.NoError(t, err)
+	require.NotNil(t, decision)
+	assert.Equal(t, tomorrow.Format("2006-01-02"), decision.TargetDate)
 	mockNotificationRepo.AssertExpectations(t)
 }
+
+func TestProcessWeatherForUser_DryRun(t *testing.T) {
+	ctx := context.Background()
+
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockNotificationRepo := new(MockNotificationRepo)
+	mockAreaUC := new(MockAreaUC)
+	mockFetcher := new(MockForecastFetcher)
+	mockNotifier := new(MockNotifier)
+
+	hierarchy := &entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "testOffice"},
+		Class10: &entity.AreaClass10{ID: "testClass10"},
+	}
+	mockAreaUC.On("GetHierarchy", ctx, mock.Anything).Return(hierarchy, nil)
+	mockFetcher.On("FetchForecast", mock.Anything, "testOffice").Return(newTestForecast("testClass10", "100", "300"), nil)
+	mockRuleRepo.On("GetRule", ctx, "100").Return(&entity.WeatherRule{WeatherCode: "100", IsNotifyTrigger: false}, nil)
+	mockRuleRepo.On("GetRule", ctx, "300").Return(&entity.WeatherRule{WeatherCode: "300", WeatherDescription: "雨", IsNotifyTrigger: true}, nil)
+	// on_change の比較のための読み込みは行う
+	mockNotificationRepo.On("FindLatestEvaluation", ctx, 1, mock.AnythingOfType("time.Time")).Return(&entity.NotificationHistory{IsNotifyTrigger: false}, nil)
+
+	snapshotRepo := &StubSnapshotRepo{}
+	forecastCache := cache.NewMemoryForecastCache(0, time.Hour)
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, mockNotificationRepo, &StubDeliveryRepo{}, &DummyUserRepo{}, mockAreaUC, mockFetcher, forecastCache, snapshotRepo, mockNotifier, usecase.BatchConfig{})
+
+	user := &entity.User{ID: 1, SelectedAreaID: "1234567", NotifyMode: entity.NotifyModeOnChange}
+	decision, err := weatherUC.ProcessWeatherForUser(ctx, user, usecase.ProcessOptions{DryRun: true})
+	require.NoError(t, err)
+
+	require.NotNil(t, decision)
+	assert.Equal(t, 1, decision.UserID)
+	assert.True(t, decision.IsNotifyTrigger)
+	assert.True(t, decision.Notify)
+	assert.Equal(t, []string{"100", "300"}, decision.WeatherCodes)
+	assert.Equal(t, []string{"300"}, decision.MatchedCodes)
+	assert.Contains(t, decision.Message, "「雨」の予報です")
+
+	// 通知も履歴・スナップショット・共有のキャッシュの保存もしない
+	mockNotifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything, mock.Anything)
+	mockNotificationRepo.AssertNotCalled(t, "InsertNotificationHistory", mock.Anything, mock.Anything)
+	assert.Empty(t, snapshotRepo.snapshots)
+	cached, err := forecastCache.Get(ctx, "testOffice")
+	require.NoError(t, err)
+	assert.Nil(t, cached)
+}
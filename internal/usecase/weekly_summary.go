@@ -0,0 +1,212 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+	"github.com/Isshinfunada/weather-bot/internal/utils"
+)
+
+// WeeklySummary はユーザーの地域の週間予報
+type WeeklySummary struct {
+	UserID         int                `json:"userId"`
+	AreaCode       string             `json:"areaCode"`
+	AreaName       string             `json:"areaName"`
+	ReportDatetime time.Time          `json:"reportDatetime"`
+	Days           []WeeklySummaryDay `json:"days"`
+}
+
+// WeeklySummaryDay は週間予報の1日分。未発表の値は null
+type WeeklySummaryDay struct {
+	Date               string `json:"date"` // "2006-01-02"
+	WeatherCode        string `json:"weatherCode"`
+	WeatherDescription string `json:"weatherDescription,omitempty"` // 天気コードのルールの説明。ルールが無ければ空
+	Pop                *int   `json:"pop"`
+	Reliability        string `json:"reliability,omitempty"` // A/B/C。先の日ほど発表される
+	TempMin            *int   `json:"tempMin"`
+	TempMax            *int   `json:"tempMax"`
+	IsNotifyTrigger    bool   `json:"isNotifyTrigger"`
+}
+
+// NotifyDays は通知対象になる日の数を返す
+func (s *WeeklySummary) NotifyDays() int {
+	n := 0
+	for _, d := range s.Days {
+		if d.IsNotifyTrigger {
+			n++
+		}
+	}
+	return n
+}
+
+// GetWeeklySummary はユーザーの地域の週間予報をまとめて返す。ユーザーが居なければ nil
+func (u *weatherUsecase) GetWeeklySummary(ctx context.Context, userID int) (*WeeklySummary, error) {
+	user, err := u.userRepo.FindUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user %d: %w", userID, err)
+	}
+	if user == nil {
+		return nil, nil
+	}
+
+	// 読むだけなのでスナップショットは保存しない
+	summary, _, err := u.weeklySummary(ctx, user, newForecastLoader(ctx, u.forecastFetcher, u.forecastCache, nil, u.batchConfig.userTimeout()))
+	return summary, err
+}
+
+// weeklySummary は週間予報を取得し、日ごとに通知ルールを当てはめる
+// 週間予報のエリアは class10 → 府県の順に探す
+func (u *weatherUsecase) weeklySummary(ctx context.Context, user *entity.User, loader *forecastLoader) (*WeeklySummary, *loadedForecast, error) {
+	hierarchy, loaded, err := u.loadForecastForUser(ctx, user, loader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	weekly := loaded.Forecast.Weekly()
+	if weekly == nil {
+		return nil, nil, fmt.Errorf("no weekly forecast for office %s", hierarchy.Office.ID)
+	}
+
+	area, days := loaded.Forecast.WeeklyForecast(hierarchy.Class10.ID, hierarchy.Office.ID)
+	summary := &WeeklySummary{
+		UserID:         user.ID,
+		AreaCode:       area.Code,
+		AreaName:       area.Name,
+		ReportDatetime: weekly.ReportDatetime,
+		Days:           make([]WeeklySummaryDay, 0, len(days)),
+	}
+
+	rules, err := u.loadUserRuleSet(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	codeRules := make(map[string]weeklyCodeRule)
+	for _, d := range days {
+		codeRule := u.weeklyCodeRule(ctx, rules, d.WeatherCode, codeRules)
+		summary.Days = append(summary.Days, WeeklySummaryDay{
+			Date:               d.Date.In(utils.JST).Format("2006-01-02"),
+			WeatherCode:        d.WeatherCode,
+			WeatherDescription: codeRule.description,
+			Pop:                d.Pop,
+			Reliability:        d.Reliability,
+			TempMin:            d.TempMin,
+			TempMax:            d.TempMax,
+			IsNotifyTrigger:    isWeeklyTrigger(user, d, codeRule),
+		})
+	}
+	return summary, loaded, nil
+}
+
+// weeklyCodeRule は週間予報の天気コードに通知ルールを当てはめた結果
+type weeklyCodeRule struct {
+	description string
+	trigger     bool
+}
+
+// weeklyCodeRule は天気コードの通知ルールを引く。同じ天気コードは codeRules に覚えておく
+func (u *weatherUsecase) weeklyCodeRule(ctx context.Context, rules *userRuleSet, weatherCode string, codeRules map[string]weeklyCodeRule) weeklyCodeRule {
+	if weatherCode == "" {
+		return weeklyCodeRule{}
+	}
+	if codeRule, ok := codeRules[weatherCode]; ok {
+		return codeRule
+	}
+
+	rule, trigger, err := u.isNotifyTrigger(ctx, weatherCode, rules)
+	if err != nil {
+		fmt.Printf("Error retrieving rule for code %s: %v\n", weatherCode, err)
+		return weeklyCodeRule{}
+	}
+	codeRule := weeklyCodeRule{trigger: trigger}
+	if rule != nil {
+		codeRule.description = rule.WeatherDescription
+	}
+	codeRules[weatherCode] = codeRule
+	return codeRule
+}
+
+// isWeeklyTrigger は1日分の週間予報が通知ルールか降水確率の閾値に当たるか判定する
+func isWeeklyTrigger(user *entity.User, day jma.WeeklyDay, codeRule weeklyCodeRule) bool {
+	if user.PopThreshold != nil && day.Pop != nil && *day.Pop >= *user.PopThreshold {
+		return true
+	}
+	return codeRule.trigger
+}
+
+// isWeekAheadTime は週間予報を通知するタイミング（日曜の夕方の通知）か判定する
+func isWeekAheadTime(user *entity.User, now time.Time) bool {
+	return user.WeeklyNotify && now.Weekday() == time.Sunday && isEveningNotify(user)
+}
+
+// processWeeklyForUser は週間予報を通知し、履歴を週間予報として保存する
+// 同じ日に既に処理していれば何もしない
+func (u *weatherUsecase) processWeeklyForUser(ctx context.Context, user *entity.User, loader *forecastLoader) (err error) {
+	now := time.Now().In(utils.JST)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, utils.JST)
+	claimed, err := u.claimDelivery(ctx, user.ID, today, entity.NotificationTypeWeekly, now)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		fmt.Printf("User %d: %s の週間予報は処理済み\n", user.ID, today.Format("2006-01-02"))
+		return nil
+	}
+	defer func() {
+		if err != nil {
+			u.releaseDelivery(user.ID, today, entity.NotificationTypeWeekly)
+		}
+	}()
+
+	summary, loaded, err := u.weeklySummary(ctx, user, loader)
+	if err != nil {
+		return err
+	}
+
+	history := &entity.NotificationHistory{
+		UserID:             user.ID,
+		NotificationType:   entity.NotificationTypeWeekly,
+		NotificationTime:   now,
+		IsNotifyTrigger:    summary.NotifyDays() > 0,
+		ForecastSnapshotID: &loaded.SnapshotID,
+	}
+	for i, d := range summary.Days {
+		if i == 0 {
+			history.TargetDate, _ = time.ParseInLocation("2006-01-02", d.Date, utils.JST)
+		}
+		history.WeatherCodes = append(history.WeatherCodes, d.WeatherCode)
+	}
+	pendingHistory(history, weeklyMessage(summary), now)
+	if err := u.notificationRepo.InsertNotificationHistory(ctx, history); err != nil {
+		return fmt.Errorf("failed to save weekly notification history for user %d: %w", user.ID, err)
+	}
+
+	if err := deliverNotification(ctx, u.notificationRepo, u.notifier, user, history); err != nil {
+		fmt.Printf("User %d: 週間予報の通知に失敗したので再送します: %v\n", user.ID, err)
+	}
+	return nil
+}
+
+// weeklyMessage は週間予報の通知文を組み立てる
+func weeklyMessage(summary *WeeklySummary) string {
+	message := fmt.Sprintf("%sの週間予報です。雨の日: %d日", summary.AreaName, summary.NotifyDays())
+	for _, d := range summary.Days {
+		// 説明の無い天気コードはコードのまま出す
+		weather := d.WeatherDescription
+		if weather == "" {
+			weather = fmt.Sprintf("天気コード %s", d.WeatherCode)
+		}
+		message += fmt.Sprintf("\n%s %s 降水確率: %s 信頼度: %s", d.Date, weather, formatOptionalInt(d.Pop), d.Reliability)
+	}
+	return message
+}
+
+func formatOptionalInt(v *int) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprint(*v)
+}
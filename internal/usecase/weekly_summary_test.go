@@ -0,0 +1,111 @@
+package usecase_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/Isshinfunada/weather-bot/internal/entity"
+	"github.com/Isshinfunada/weather-bot/internal/interfaces/jma"
+	"github.com/Isshinfunada/weather-bot/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// StubUserRepo は FindUserByID で固定のユーザーを返す
+type StubUserRepo struct {
+	DummyUserRepo
+	user *entity.User
+}
+
+func (s *StubUserRepo) FindUserByID(ctx context.Context, userID int) (*entity.User, error) {
+	if s.user == nil || s.user.ID != userID {
+		return nil, nil
+	}
+	return s.user, nil
+}
+
+func loadFixtureForecast(t *testing.T) *jma.Forecast {
+	body, err := os.ReadFile("../interfaces/jma/testdata/forecast_130000.json")
+	require.NoError(t, err)
+	forecast, err := jma.ParseForecast(body)
+	require.NoError(t, err)
+	return forecast
+}
+
+func TestGetWeeklySummary(t *testing.T) {
+	ctx := context.Background()
+
+	threshold := 50
+	user := &entity.User{ID: 1, SelectedAreaID: "1310100", PopThreshold: &threshold}
+
+	mockRuleRepo := new(MockWeatherRuleRepo)
+	mockAreaUC := new(MockAreaUC)
+	mockFetcher := new(MockForecastFetcher)
+
+	mockAreaUC.On("GetHierarchy", ctx, "1310100").Return(&entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "130000"},
+		Class10: &entity.AreaClass10{ID: "130010"},
+	}, nil)
+	mockFetcher.On("FetchForecast", mock.Anything, "130000").Return(loadFixtureForecast(t), nil)
+	mockRuleRepo.On("GetRule", ctx, "313").Return(&entity.WeatherRule{WeatherCode: "313", WeatherDescription: "雨のち曇", IsNotifyTrigger: true}, nil).Once()
+	// 天気の説明を出すため全てのコードのルールを引くが、同じコードのルールは1回だけ引く
+	for _, code := range []string{"201", "101", "200", "300", "202"} {
+		mockRuleRepo.On("GetRule", ctx, code).Return(&entity.WeatherRule{WeatherCode: code}, nil).Once()
+	}
+
+	snapshotRepo := &StubSnapshotRepo{}
+	weatherUC := usecase.NewWeatherUsecase(mockRuleRepo, &StubUserRuleRepo{}, new(MockNotificationRepo), &StubDeliveryRepo{}, &StubUserRepo{user: user}, mockAreaUC, mockFetcher, nil, snapshotRepo, newNopNotifier(), usecase.BatchConfig{})
+
+	summary, err := weatherUC.GetWeeklySummary(ctx, 1)
+	require.NoError(t, err)
+	require.NotNil(t, summary)
+	// 読むだけなのでスナップショットは保存しない
+	assert.Empty(t, snapshotRepo.snapshots)
+	assert.Equal(t, "130010", summary.AreaCode)
+	assert.Equal(t, "東京地方", summary.AreaName)
+	require.Len(t, summary.Days, 7)
+
+	assert.Equal(t, "2024-06-11", summary.Days[0].Date)
+	assert.Equal(t, "雨のち曇", summary.Days[0].WeatherDescription)
+	assert.Nil(t, summary.Days[0].Pop)
+
+	var triggers []string
+	for _, d := range summary.Days {
+		if d.IsNotifyTrigger {
+			triggers = append(triggers, d.Date)
+		}
+	}
+	// 313 はルール、300・202 は降水確率が閾値に当たる
+	assert.Equal(t, []string{"2024-06-11", "2024-06-15", "2024-06-16"}, triggers)
+	assert.Equal(t, 3, summary.NotifyDays())
+	mockRuleRepo.AssertExpectations(t)
+}
+
+func TestGetWeeklySummary_UserNotFound(t *testing.T) {
+	weatherUC := usecase.NewWeatherUsecase(new(MockWeatherRuleRepo), &StubUserRuleRepo{}, new(MockNotificationRepo), &StubDeliveryRepo{}, &StubUserRepo{}, new(MockAreaUC), new(MockForecastFetcher), nil, &StubSnapshotRepo{}, newNopNotifier(), usecase.BatchConfig{})
+
+	summary, err := weatherUC.GetWeeklySummary(context.Background(), 99)
+	assert.NoError(t, err)
+	assert.Nil(t, summary)
+}
+
+func TestGetWeeklySummary_NoWeeklyForecast(t *testing.T) {
+	ctx := context.Background()
+	user := &entity.User{ID: 1, SelectedAreaID: "1310100"}
+
+	mockAreaUC := new(MockAreaUC)
+	mockFetcher := new(MockForecastFetcher)
+	mockAreaUC.On("GetHierarchy", ctx, mock.Anything).Return(&entity.HierarchyArea{
+		Office:  &entity.AreaOffice{ID: "130000"},
+		Class10: &entity.AreaClass10{ID: "130010"},
+	}, nil)
+	mockFetcher.On("FetchForecast", mock.Anything, "130000").Return(newTestForecast("130010", "100"), nil)
+
+	weatherUC := usecase.NewWeatherUsecase(new(MockWeatherRuleRepo), &StubUserRuleRepo{}, new(MockNotificationRepo), &StubDeliveryRepo{}, &StubUserRepo{user: user}, mockAreaUC, mockFetcher, nil, &StubSnapshotRepo{}, newNopNotifier(), usecase.BatchConfig{})
+
+	summary, err := weatherUC.GetWeeklySummary(ctx, 1)
+	assert.Error(t, err)
+	assert.Nil(t, summary)
+}